@@ -89,6 +89,17 @@ func ExampleHexStringToIP() {
 	// Output: 192.168.1.1
 }
 
+func ExampleHostMaskForCount() {
+	hm, _ := HostMaskForCount(56, 4096)
+	hmlen, _ := hm.Size()
+	n := NewNet6(net.ParseIP("2001:db8::"), 56, hmlen)
+	fmt.Println(hmlen)
+	fmt.Println(n.Count())
+	// Output:
+	// 60
+	// 4096
+}
+
 func ExampleIncrementIP4By() {
 	ip := net.ParseIP("192.168.1.1")
 	fmt.Println(IncrementIP4By(ip, 255))
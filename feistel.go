@@ -0,0 +1,72 @@
+package iplib
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+)
+
+// feistelRounds is the number of rounds applied by feistelPermutation. Four
+// rounds of a balanced Feistel network are enough to make the output look
+// unrelated to the input, which is all that is asked of it here
+const feistelRounds = 4
+
+// feistelPermutation is a keyed, format-preserving permutation of the
+// integers [0, size). A balanced Feistel network permutes the smallest even
+// power-of-two domain covering size, and cycle-walking folds that back onto
+// [0, size): any output that lands outside the range is fed back through the
+// network until it falls inside it. Since the network is a bijection this
+// visits every value in the range exactly once as the input is walked from 0
+// to size-1, without needing to store anything but the keys
+type feistelPermutation struct {
+	size     uint64
+	halfBits uint
+	keys     [feistelRounds]uint64
+}
+
+// newFeistelPermutation returns a permutation of [0, size) keyed with
+// material read from r
+func newFeistelPermutation(r io.Reader, size uint64) (feistelPermutation, error) {
+	p := feistelPermutation{size: size}
+	if size > 1 {
+		p.halfBits = uint(bits.Len64(size-1)+1) / 2
+	}
+
+	buf := make([]byte, 8*feistelRounds)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return p, err
+	}
+	for i := range p.keys {
+		p.keys[i] = binary.BigEndian.Uint64(buf[8*i:])
+	}
+	return p, nil
+}
+
+// permute returns the value that x, which must be less than size, maps to
+func (p feistelPermutation) permute(x uint64) uint64 {
+	for {
+		x = p.encrypt(x)
+		if x < p.size {
+			return x
+		}
+	}
+}
+
+// encrypt runs x through the Feistel network over the full 2*halfBits domain
+func (p feistelPermutation) encrypt(x uint64) uint64 {
+	mask := uint64(1)<<p.halfBits - 1
+	left, right := x>>p.halfBits, x&mask
+	for _, k := range p.keys {
+		left, right = right, left^(feistelRound(right, k)&mask)
+	}
+	return left<<p.halfBits | right
+}
+
+// feistelRound is the round function, a splitmix64-style mix of the half
+// block and the round key
+func feistelRound(x, k uint64) uint64 {
+	z := x ^ k
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -1,8 +1,11 @@
 package iplib
 
 import (
+	"bytes"
 	"encoding/hex"
+	"math/bits"
 	"net"
+	"strings"
 
 	"lukechampine.com/uint128"
 )
@@ -80,6 +83,66 @@ func NewHostMask(masklen int) HostMask {
 	return mask
 }
 
+// HostMaskFromStr parses s as a HostMask. It accepts either a decimal mask
+// length such as "56", a colon-separated IPv6-style mask such as
+// "::f0ff:ffff:ffff:ffff", or the unpunctuated hexadecimal produced by
+// HostMask.String(). A string that cannot be parsed returns a *ParseError,
+// while a mask longer than 128 bits or one whose bits are not contiguous from
+// the right, in the sense described above, returns ErrBadMaskLength
+func HostMaskFromStr(s string) (HostMask, error) {
+	if len(s) > 0 && strings.Trim(s, "0123456789") == "" && len(s) < 32 {
+		masklen, err := parseDecimal(s, 128)
+		if err != nil {
+			return nil, ErrBadMaskLength
+		}
+		return NewHostMask(masklen), nil
+	}
+
+	var mask []byte
+	if strings.Contains(s, ":") {
+		if ip := net.ParseIP(s); ip != nil && strings.Count(s, ":") > 1 {
+			mask = ip.To16()
+		}
+	} else if len(s) == hex.EncodedLen(16) {
+		mask, _ = hex.DecodeString(s)
+	}
+	if mask == nil {
+		return nil, &ParseError{Input: s, Token: s, Err: &net.ParseError{Type: "hostmask", Text: s}}
+	}
+
+	masklen, _ := HostMask(mask).Size()
+	hm := NewHostMask(masklen)
+	if !bytes.Equal(hm, mask) {
+		return nil, ErrBadMaskLength
+	}
+	return hm, nil
+}
+
+// HostMaskForCount returns the HostMask which, combined with a netmask of
+// netmasklen, leaves exactly count allocatable addresses in a Net6. Since the
+// number of usable bits is whatever remains between the two masks the result
+// depends on netmasklen as well as count, e.g. 4096 addresses in a /56 needs
+// a /60 hostmask while in a /64 it needs a /52; without netmasklen there is no
+// single correct answer. Pass the length of the result to NewNet6 along with
+// netmasklen to build the network. A count of 1 leaves no host bits, so the
+// result masks everything after the netmask; note that NewNet6 only accepts
+// that combination for a /128. If count is not a power of two or needs more
+// bits than netmasklen leaves available ErrBadMaskLength is returned
+func HostMaskForCount(netmasklen int, count uint64) (HostMask, error) {
+	if count == 0 || count&(count-1) != 0 {
+		return nil, ErrBadMaskLength
+	}
+	if netmasklen < 0 || netmasklen > 128 {
+		return nil, ErrBadMaskLength
+	}
+
+	hmlen := 128 - netmasklen - bits.TrailingZeros64(count)
+	if hmlen < 0 {
+		return nil, ErrBadMaskLength
+	}
+	return NewHostMask(hmlen), nil
+}
+
 // BoundaryByte returns the rightmost byte in the mask in which any bits fall
 // inside the hostmask, as well as the position of that byte. For example a
 // masklength of 58 would return "0xc0, 8" while 32 would return "0xff, 12".
@@ -119,6 +182,53 @@ func (m HostMask) Size() (int, int) {
 	return ones, bits
 }
 
+// StepWithin moves ip by delta addresses within the unmasked portion of the
+// address, forward if delta is positive and backward if it is negative, by
+// way of IncrementIP6WithinHostmask() or DecrementIP6WithinHostmask(). If ip
+// is already outside of the hostmask, or stepping would overflow or underflow
+// the address space, ErrAddressOutOfRange is returned
+func (m HostMask) StepWithin(ip net.IP, delta int64) (net.IP, error) {
+	// the hostmask functions modify their argument, so work on a copy
+	ip = CopyIP(ip).To16()
+	if ip == nil {
+		return net.IP{}, ErrAddressOutOfRange
+	}
+
+	// negate via delta+1 so that math.MinInt64 doesn't overflow
+	count := uint128.From64(uint64(delta))
+	if delta < 0 {
+		count = uint128.From64(uint64(-(delta + 1))).Add64(1)
+	}
+
+	// without a hostmask the directional functions saturate at the limits of
+	// the address space rather than failing, so do the arithmetic here
+	if hmlen, _ := m.Size(); hmlen == 0 {
+		z := IP6ToUint128(ip)
+		if delta < 0 {
+			if count.Cmp(z) > 0 {
+				return net.IP{}, ErrAddressOutOfRange
+			}
+			return Uint128ToIP6(z.Sub(count)), nil
+		}
+		if nz := z.AddWrap(count); nz.Cmp(z) >= 0 {
+			return Uint128ToIP6(nz), nil
+		}
+		return net.IP{}, ErrAddressOutOfRange
+	}
+
+	var xip net.IP
+	var err error
+	if delta < 0 {
+		xip, err = DecrementIP6WithinHostmask(ip, m, count)
+	} else {
+		xip, err = IncrementIP6WithinHostmask(ip, m, count)
+	}
+	if err != nil {
+		return net.IP{}, err
+	}
+	return xip, nil
+}
+
 // String returns the hexadecimal form of m, with no punctuation
 func (m HostMask) String() string {
 	return hex.EncodeToString(m)
@@ -368,3 +478,30 @@ func incrementUnmaskedBytes(nb []byte, count uint128.Uint128) []byte {
 	// return only as many elements as were passed in
 	return xb[16-len(nb):]
 }
+
+// hostmaskOrdinal returns the position of ip in the sequence of addresses
+// that can be reached by stepping through the unmasked bits of hm, which is
+// what allows the distance between two addresses to be measured in steps
+// rather than raw address values. ip must be 16 bytes long. If any of the
+// masked bits of ip are set the second return value is false
+func hostmaskOrdinal(ip net.IP, hm HostMask) (uint128.Uint128, bool) {
+	bb, bbpos := hm.BoundaryByte()
+	if bbpos == -1 {
+		return IP6ToUint128(ip), true
+	}
+
+	for _, b := range ip[bbpos+1:] {
+		if b > 0 {
+			return uint128.Zero, false
+		}
+	}
+	if ip[bbpos] > 0xff-bb {
+		return uint128.Zero, false
+	}
+
+	z := uint128.Zero
+	for _, b := range ip[:bbpos] {
+		z = z.Mul64(256).Add64(uint64(b))
+	}
+	return z.Mul64(256 - uint64(bb)).Add64(uint64(ip[bbpos])), true
+}
@@ -2,6 +2,7 @@ package iplib
 
 import (
 	"bytes"
+	"math"
 	"net"
 	"testing"
 
@@ -75,6 +76,93 @@ var boundaryByteDeltaTests = []struct {
 	{0x08, 0x09, uint128.From64(1024), 0xe1, uint128.From64(5), 0x29, uint128.From64(4)},
 }
 
+var hostMaskFromStrTests = []struct {
+	s       string
+	masklen int
+	err     error
+}{
+	{"0", 0, nil},
+	{"56", 56, nil},
+	{"128", 128, nil},
+	{"129", 0, ErrBadMaskLength},
+	{"::", 0, nil},
+	{"::ff", 8, nil},
+	{"::c0ff:ffff:ffff:ffff", 58, nil},
+	{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", 128, nil},
+	{"0000000000000000c0ffffffffffffff", 58, nil},
+	{"::ff00", 0, ErrBadMaskLength},                // not anchored at the right
+	{"::3fff:ffff:ffff:ffff", 0, ErrBadMaskLength}, // boundary bits filled from the wrong side
+	{"::ff:ff", 0, ErrBadMaskLength},               // gap between masked bytes
+	{"::gg", 0, &ParseError{Err: &net.ParseError{Type: "hostmask", Text: "::gg"}}},
+	{"ffff", 0, &ParseError{Err: &net.ParseError{Type: "hostmask", Text: "ffff"}}},
+	{"", 0, &ParseError{Err: &net.ParseError{Type: "hostmask", Text: ""}}},
+}
+
+func TestHostMaskFromStr(t *testing.T) {
+	for i, tt := range hostMaskFromStrTests {
+		hm, err := HostMaskFromStr(tt.s)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %q: %s", i, tt.s, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if !bytes.Equal(hm, NewHostMask(tt.masklen)) {
+			t.Errorf("[%d] %q: want %s got %s", i, tt.s, NewHostMask(tt.masklen), hm)
+		}
+		if rt, _ := HostMaskFromStr(hm.String()); !bytes.Equal(rt, hm) {
+			t.Errorf("[%d] %q: String() did not round-trip, got %s", i, tt.s, rt)
+		}
+	}
+}
+
+var hostMaskForCountTests = []struct {
+	netmasklen int
+	count      uint64
+	hmlen      int
+	err        error
+}{
+	{56, 4096, 60, nil},
+	{64, 4096, 52, nil},
+	{64, 1 << 63, 1, nil},
+	{64, 2, 63, nil},
+	{0, 1 << 63, 65, nil},
+	{120, 256, 0, nil},
+	{120, 512, 0, ErrBadMaskLength}, // needs more bits than the netmask leaves
+	{64, 4095, 0, ErrBadMaskLength},
+	{64, 1, 64, nil},
+	{128, 1, 0, nil},
+	{64, 0, 0, ErrBadMaskLength},
+	{129, 2, 0, ErrBadMaskLength},
+	{-1, 2, 0, ErrBadMaskLength},
+}
+
+func TestHostMaskForCount(t *testing.T) {
+	for i, tt := range hostMaskForCountTests {
+		hm, err := HostMaskForCount(tt.netmasklen, tt.count)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		hmlen, _ := hm.Size()
+		if hmlen != tt.hmlen {
+			t.Errorf("[%d] want hostmask length %d got %d", i, tt.hmlen, hmlen)
+		}
+
+		n := NewNet6(net.ParseIP("2001:db8::"), tt.netmasklen, hmlen)
+		if n.IP() == nil {
+			continue
+		}
+		if count := n.Count(); !count.Equals64(tt.count) {
+			t.Errorf("[%d] want Count() %d got %s", i, tt.count, count)
+		}
+	}
+}
+
 func Test_decrementBoundaryByte(t *testing.T) {
 	for i, tt := range boundaryByteDeltaTests {
 		decrcount, decrval := decrementBoundaryByte(tt.bb, tt.bv, tt.count)
@@ -272,3 +360,49 @@ func compareErrors(got, want error) string {
 	}
 	return ""
 }
+
+var stepWithinTests = []struct {
+	ip       net.IP
+	hostmask int
+	delta    int64
+	want     net.IP
+	err      error
+}{
+	{net.ParseIP("2001:db8::"), 0, 1, net.ParseIP("2001:db8::1"), nil},
+	{net.ParseIP("2001:db8::1"), 0, -1, net.ParseIP("2001:db8::"), nil},
+	{net.ParseIP("2001:db8::1"), 0, 0, net.ParseIP("2001:db8::1"), nil},
+	{net.ParseIP("2001:db8::"), 64, 1, net.ParseIP("2001:db8:0:1::"), nil},
+	{net.ParseIP("2001:db8:0:1::"), 64, -1, net.ParseIP("2001:db8::"), nil},
+	{net.ParseIP("2001:db8::"), 60, 16, net.ParseIP("2001:db8:0:1::"), nil},
+	{net.ParseIP("2001:db8:0:1::"), 60, -17, net.ParseIP("2001:db7:ffff:ffff:f00::"), nil},
+	{net.ParseIP("2001:db8::1"), 64, 1, nil, ErrAddressOutOfRange}, // already outside the hostmask
+	{net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), 0, 1, nil, ErrAddressOutOfRange},
+	{net.ParseIP("::"), 0, -1, nil, ErrAddressOutOfRange},
+	{net.ParseIP("ffff:ffff:ffff:ffff::"), 64, 1, nil, ErrAddressOutOfRange},
+	{net.ParseIP("::"), 64, -1, nil, ErrAddressOutOfRange},
+	{net.ParseIP("::"), 0, math.MinInt64, nil, ErrAddressOutOfRange},
+	{net.ParseIP("::1:0:0:0:0"), 0, math.MinInt64, net.ParseIP("::8000:0:0:0"), nil},
+}
+
+func TestHostMask_StepWithin(t *testing.T) {
+	for i, tt := range stepWithinTests {
+		xip, err := NewHostMask(tt.hostmask).StepWithin(tt.ip, tt.delta)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err == nil && !xip.Equal(tt.want) {
+			t.Errorf("[%d] %s %+d: want %s got %s", i, tt.ip, tt.delta, tt.want, xip)
+		}
+	}
+
+	for _, hmlen := range []int{0, 60} {
+		ip := net.ParseIP("2001:db8:0:ff:f00::")
+		if _, err := NewHostMask(hmlen).StepWithin(ip, -1); err != nil {
+			t.Errorf("hostmask %d: unexpected error %v", hmlen, err)
+		}
+		if ip.String() != "2001:db8:0:ff:f00::" {
+			t.Errorf("hostmask %d: input modified to %s", hmlen, ip)
+		}
+	}
+}
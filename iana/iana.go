@@ -21,6 +21,7 @@ package iana
 import (
 	"net"
 	"sort"
+	"strings"
 
 	"github.com/c-robinson/iplib/v2"
 )
@@ -142,26 +143,17 @@ func GetReservationsForIP(ip net.IP) []*Reservation {
 	return reservations
 }
 
+// GetRFCsForIP returns a sorted, deduplicated list of the RFCs governing
+// every reservation that contains ip. As with GetReservationsForIP an IPv4
+// address will not be matched against the IPv4-mapped IPv6 reservation
+func GetRFCsForIP(ip net.IP) []string {
+	return rfcsForReservations(GetReservationsForIP(ip))
+}
+
 // GetRFCsForNetwork returns a list of all RFCs that apply to the given
 // network
 func GetRFCsForNetwork(n iplib.Net) []string {
-	rfclist := []string{}
-	reservations := GetReservationsForNetwork(n)
-	if len(reservations) > 0 {
-		for _, r := range reservations {
-		LOOP:
-			for _, rfc := range r.RFC {
-				for _, xrfc := range rfclist {
-					if xrfc == rfc {
-						continue LOOP
-					}
-				}
-				rfclist = append(rfclist, rfc)
-			}
-		}
-		sort.Strings(rfclist)
-	}
-	return rfclist
+	return rfcsForReservations(GetReservationsForNetwork(n))
 }
 
 // IsForwardable will return false if the given iplib.Net contains or is
@@ -179,6 +171,33 @@ func IsForwardable(n iplib.Net) bool {
 	return true
 }
 
+// IsFullyReserved returns true if every address in the given iplib.Net falls
+// inside a network marked either not-forwardable or reserved-by-protocol in
+// the IANA registry, in other words if no part of it could be assigned. It
+// differs from IsReserved, which is true if the network merely touches
+// reserved space: 127.0.0.0/9 is fully reserved but 127.0.0.0/7 is not,
+// because 126.0.0.0/8 is ordinary address space. Adjacent or overlapping
+// reservations are combined, so a network spanning two of them still counts
+func IsFullyReserved(n iplib.Net) bool {
+	if n == nil || n.IP() == nil {
+		return false
+	}
+
+	end := n.BroadcastAddress()
+	cur := n.IP()
+	for {
+		r := getUnassignableReservation(cur)
+		if r == nil {
+			return false
+		}
+		last := r.Network.BroadcastAddress()
+		if iplib.CompareIPs(last, end) >= 0 {
+			return true
+		}
+		cur = iplib.NextIP(last)
+	}
+}
+
 // IsGlobal will return false if the given iplib.Net contains or is contained
 // in a network that is marked not-global in the IANA registry. IANA defines a
 // global network as one where "...an IP datagram whose destination address is
@@ -210,6 +229,134 @@ func IsReserved(n iplib.Net) bool {
 	return false
 }
 
+// IsSpecialV4 explains why an IPv4 address cannot be assigned to a host. If
+// ip falls inside a registry network marked either not-forwardable or
+// reserved-by-protocol, such as 0.0.0.0/8, 169.254.0.0/16 or the limited
+// broadcast address 255.255.255.255, it returns a reason made up of the
+// reservation's title and RFCs, e.g. "Link Local (RFC3927)", and true. Where
+// several reservations apply the most specific one is used. Ordinary
+// addresses, and any address that isn't IPv4, return "" and false
+func IsSpecialV4(ip net.IP) (string, bool) {
+	if iplib.EffectiveVersion(ip) != iplib.IP4Version {
+		return "", false
+	}
+
+	var found *Reservation
+	best := -1
+	for _, r := range Registry {
+		if r.Forwardable && !r.Reserved {
+			continue
+		}
+		if r.Network.Version() != iplib.IP4Version || !r.Network.Contains(ip) {
+			continue
+		}
+		if ones, _ := r.Network.Mask().Size(); ones > best {
+			found, best = r, ones
+		}
+	}
+	if found == nil {
+		return "", false
+	}
+	return found.Title + " (" + strings.Join(found.RFC, ", ") + ")", true
+}
+
+// NextAssignableIP returns the first address after ip that does not fall
+// inside any registry network marked either not-forwardable or
+// reserved-by-protocol, such as 127.0.0.0/8 or 2001:db8::/32. Rather than
+// testing every address in turn it jumps to the end of each such reservation
+// it encounters, so skipping a large block costs no more than skipping a
+// small one. If no such address exists before the end of the address space
+// nil is returned
+func NextAssignableIP(ip net.IP) net.IP {
+	cur := ip
+	for {
+		next := iplib.NextIP(cur)
+		if iplib.CompareIPs(next, cur) <= 0 {
+			return nil // NextIP doesn't wrap, so we're at the end of the space
+		}
+		cur = next
+
+		r := getUnassignableReservation(cur)
+		if r == nil {
+			return cur
+		}
+		cur = r.Network.BroadcastAddress()
+	}
+}
+
+// ReservedSubnetsWithin returns the registry entries whose networks lie
+// entirely within n, sorted by network as iplib.CompareNets() orders them.
+// Unlike GetReservationsForNetwork, reservations that merely contain n are
+// not included, so this answers the question "which special-use blocks are
+// carved out of this allocation". A nil network returns an empty list
+func ReservedSubnetsWithin(n iplib.Net) []*Reservation {
+	reservations := []*Reservation{}
+	if n == nil || n.IP() == nil {
+		return reservations
+	}
+	for _, r := range Registry {
+		// compare declared families, as the IPv4-mapped block ::ffff:0:0/96
+		// is a Net6 whose address looks like v4
+		if r.Network.Version() != n.Version() {
+			continue
+		}
+		// bounds are compared directly since ContainsNet() does not match a
+		// v6 network against that block
+		if iplib.CompareIPs(r.Network.IP(), n.IP()) >= 0 && iplib.CompareIPs(r.Network.BroadcastAddress(), n.BroadcastAddress()) <= 0 {
+			reservations = append(reservations, r)
+		}
+	}
+
+	sort.SliceStable(reservations, func(i, j int) bool {
+		return iplib.CompareNets(reservations[i].Network, reservations[j].Network) < 0
+	})
+	return reservations
+}
+
+// getUnassignableReservation returns a registry entry, marked either
+// not-forwardable or reserved-by-protocol, which contains ip or nil if there
+// is none. Where more than one qualifies the one ending last is returned, to
+// allow callers to skip past all of them at once
+func getUnassignableReservation(ip net.IP) *Reservation {
+	var found *Reservation
+	for _, r := range Registry {
+		if r.Forwardable && !r.Reserved {
+			continue
+		}
+		if iplib.EffectiveVersion(ip) == 4 && r.Title == "IPv4-mapped Address" {
+			continue
+		}
+		if !r.Network.Contains(ip) {
+			continue
+		}
+		if found == nil || iplib.CompareIPs(r.Network.BroadcastAddress(), found.Network.BroadcastAddress()) > 0 {
+			found = r
+		}
+	}
+	return found
+}
+
+// rfcsForReservations returns the sorted, deduplicated union of the RFC
+// lists of the supplied reservations
+func rfcsForReservations(reservations []*Reservation) []string {
+	rfclist := []string{}
+	if len(reservations) > 0 {
+		for _, r := range reservations {
+		LOOP:
+			for _, rfc := range r.RFC {
+				for _, xrfc := range rfclist {
+					if xrfc == rfc {
+						continue LOOP
+					}
+				}
+				rfclist = append(rfclist, rfc)
+			}
+		}
+		sort.Strings(rfclist)
+	}
+	return rfclist
+}
+
 func getFromCIDR(s string) iplib.Net {
 	_, n, _ := iplib.ParseCIDR(s)
 	return n
@@ -46,6 +46,28 @@ func TestGetReservationsForIP(t *testing.T) {
 	}
 }
 
+var RFCsForIPTests = []struct {
+	address string
+	rfcList []string
+}{
+	{"144.21.1.19", []string{}},
+	{"192.168.123.49", []string{"RFC1918"}},
+	{"::ffff:192.168.123.49", []string{"RFC1918"}},
+	{"192.0.0.9", []string{"RFC6890", "RFC7723"}},
+	{"255.255.255.255", []string{"RFC1112", "RFC8190", "RFC919"}},
+	{"2001:db8:1::250:3", []string{"RFC3849"}},
+	{"25:100:200::195:16", []string{}},
+}
+
+func TestGetRFCsForIP(t *testing.T) {
+	for _, tt := range RFCsForIPTests {
+		rfclist := GetRFCsForIP(net.ParseIP(tt.address))
+		if v := equalList(rfclist, tt.rfcList); v != true {
+			t.Errorf("%s want %v, got %v", tt.address, tt.rfcList, rfclist)
+		}
+	}
+}
+
 var NetTests = []struct {
 	name           string
 	resCount       int
@@ -207,6 +229,168 @@ func TestIsReserved(t *testing.T) {
 	}
 }
 
+var NextAssignableIPTests = []struct {
+	name    string
+	address string
+	next    string
+}{
+	{"Unreservedv4", "144.21.1.19", "144.21.1.20"},
+	{"Forwardablev4", "9.255.255.255", "10.0.0.0"},
+	{"Loopbackv4", "126.255.255.255", "128.0.0.0"},
+	{"InsideLoopbackv4", "127.0.0.1", "128.0.0.0"},
+	{"Documentationv4", "198.51.100.17", "198.51.101.0"},
+	{"IETFProtocolv4", "192.0.0.254", "192.0.1.0"},
+	{"AdjacentDocumentationv4", "192.0.1.255", "192.0.3.0"},
+	{"EndOfSpacev4", "239.255.255.255", ""},
+	{"Unspecifiedv6", "::", "::2"},
+	{"Documentationv6", "2001:db7:ffff:ffff:ffff:ffff:ffff:ffff", "2001:db9::"},
+	{"LinkLocalv6", "fe7f:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "fec0::"},
+	{"EndOfSpacev6", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", ""},
+}
+
+func TestNextAssignableIP(t *testing.T) {
+	for _, tt := range NextAssignableIPTests {
+		next := NextAssignableIP(net.ParseIP(tt.address))
+		if tt.next == "" {
+			if next != nil {
+				t.Errorf("'%s' want nil, got %s", tt.name, next)
+			}
+			continue
+		}
+		if !next.Equal(net.ParseIP(tt.next)) {
+			t.Errorf("'%s' want %s, got %s", tt.name, tt.next, next)
+		}
+	}
+}
+
+var IsFullyReservedTests = []struct {
+	name     string
+	network  string
+	reserved bool
+}{
+	{"Unreservedv4", "144.21.1.0/24", false},
+	{"Forwardablev4", "10.0.0.0/8", false},
+	{"Loopbackv4", "127.0.0.0/8", true},
+	{"InsideLoopbackv4", "127.0.0.0/9", true},
+	{"OverlapsLoopbackv4", "126.0.0.0/7", false},
+	{"PartlyReservedv4", "192.0.0.0/23", false},
+	{"Reservedv4", "240.0.0.0/4", true},
+	{"HighestAddressv4", "255.255.255.255/32", true},
+	{"Documentationv6", "2001:db8:1::/48", true},
+	{"LinkLocalv6", "fe80::/10", true},
+	{"Unreservedv6", "2600::/16", false},
+	{"PartlyReservedv6", "2001:db8::/31", false},
+	{"SpanningReservationsv6", "::/127", true},
+	{"SpanningPartlyReservedv6", "::/126", false},
+}
+
+func TestIsFullyReserved(t *testing.T) {
+	for _, tt := range IsFullyReservedTests {
+		_, n, _ := iplib.ParseCIDR(tt.network)
+		if v := IsFullyReserved(n); v != tt.reserved {
+			t.Errorf("'%s' want %t, got %t", tt.name, tt.reserved, v)
+		}
+	}
+	if IsFullyReserved(nil) {
+		t.Errorf("nil network should not be reserved")
+	}
+}
+
+var IsSpecialV4Tests = []struct {
+	ip      string
+	reason  string
+	special bool
+}{
+	{"0.0.0.0", "This host on this network (RFC1122)", true},
+	{"0.1.2.3", "This host on this network (RFC1122)", true},
+	{"255.255.255.255", "Limited Broadcast (RFC8190, RFC919)", true},
+	{"250.1.1.1", "Reserved (RFC1112)", true},
+	{"169.254.10.1", "Link Local (RFC3927)", true},
+	{"127.0.0.1", "Loopback (RFC1122)", true},
+	{"192.0.2.55", "Documentation (TEST-NET-1) (RFC5737)", true},
+	{"192.168.1.1", "", false},
+	{"8.8.8.8", "", false},
+	{"::ffff:169.254.1.1", "Link Local (RFC3927)", true},
+	{"fe80::1", "", false},
+	{"::", "", false},
+}
+
+func TestIsSpecialV4(t *testing.T) {
+	for _, tt := range IsSpecialV4Tests {
+		reason, special := IsSpecialV4(net.ParseIP(tt.ip))
+		if special != tt.special {
+			t.Errorf("%s: want %t got %t", tt.ip, tt.special, special)
+		}
+		if reason != tt.reason {
+			t.Errorf("%s: want %q got %q", tt.ip, tt.reason, reason)
+		}
+	}
+}
+
+var ReservedSubnetsWithinTests = []struct {
+	network string
+	nets    []string
+}{
+	{"8.0.0.0/8", []string{}},
+	{"10.0.0.0/16", []string{}}, // inside 10.0.0.0/8, but does not contain it
+	{"10.0.0.0/8", []string{"10.0.0.0/8"}},
+	{"192.0.0.0/16", []string{
+		"192.0.0.0/24", "192.0.0.0/29", "192.0.0.8/32", "192.0.0.9/32",
+		"192.0.0.10/32", "192.0.0.170/32", "192.0.0.171/32", "192.0.2.0/24",
+	}},
+	{"192.0.0.0/8", []string{
+		"192.0.0.0/24", "192.0.0.0/29", "192.0.0.8/32", "192.0.0.9/32",
+		"192.0.0.10/32", "192.0.0.170/32", "192.0.0.171/32", "192.0.2.0/24",
+		"192.31.196.0/24", "192.52.193.0/24", "192.168.0.0/16", "192.175.48.0/24",
+	}},
+	{"2001::/16", []string{
+		"2001::/23", "2001::/32", "2001:1::1/128", "2001:1::2/128", "2001:2::/48",
+		"2001:3::/32", "2001:4:112::/48", "2001:5::/32", "2001:20::/28", "2001:db8::/32",
+	}},
+	{"fe80::/16", []string{}},
+}
+
+func TestReservedSubnetsWithin(t *testing.T) {
+	for _, tt := range ReservedSubnetsWithinTests {
+		_, n, _ := iplib.ParseCIDR(tt.network)
+		got := []string{}
+		for _, r := range ReservedSubnetsWithin(n) {
+			got = append(got, r.Network.String())
+		}
+		if !equalList(got, tt.nets) {
+			t.Errorf("%s: want %v got %v", tt.network, tt.nets, got)
+		}
+	}
+	if r := ReservedSubnetsWithin(nil); len(r) != 0 {
+		t.Errorf("nil network: want nothing got %d reservations", len(r))
+	}
+
+	for _, s := range []string{"0.0.0.0/0", "::/0"} {
+		_, n, _ := iplib.ParseCIDR(s)
+		reservations := ReservedSubnetsWithin(n)
+		mapped := false
+		for i, r := range reservations {
+			if r.Network.Version() != n.Version() {
+				t.Errorf("%s: got %s, a v%d reservation", s, r.Network, r.Network.Version())
+			}
+			if r.Title == "IPv4-mapped Address" {
+				mapped = true
+			}
+			if i > 0 && iplib.CompareNets(reservations[i-1].Network, r.Network) > 0 {
+				t.Errorf("%s: %s sorts before %s", s, reservations[i-1].Network, r.Network)
+			}
+		}
+		if mapped != (n.Version() == iplib.IP6Version) {
+			t.Errorf("%s: want IPv4-mapped Address %t got %t", s, !mapped, mapped)
+		}
+	}
+
+	n := iplib.NewNet6(net.ParseIP("::ffff:0:0"), 96, 0)
+	if r := ReservedSubnetsWithin(n); len(r) != 1 || r[0].Title != "IPv4-mapped Address" {
+		t.Errorf("::ffff:0:0/96: want only the IPv4-mapped Address reservation got %d reservations", len(r))
+	}
+}
+
 func equalList(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
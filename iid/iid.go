@@ -57,7 +57,12 @@ const (
 
 // Errors that may be returned by functions in this package
 var (
+	ErrBadHardwareAddr     = errors.New("hardware address is not a 48-bit IPv6 multicast (33:33) address")
+	ErrBadIIDLength        = errors.New("interface identifier must be 64 bits")
+	ErrBadMACLength        = errors.New("hardware address must be 48 or 64 bits")
 	ErrIIDAddressCollision = errors.New("proposed IID collides with IANA reserved IID list")
+	ErrNotIPv6             = errors.New("address is not an IPv6 address")
+	ErrNotMulticast        = errors.New("address is not an IPv6 multicast address")
 )
 
 // Registry holds the aggregated network list from IANA's "Reserved IPv6
@@ -117,6 +122,34 @@ func init() {
 	}
 }
 
+// DecodeEUI64Addr is the inverse of MakeEUI64Addr() with ScopeInvert, the
+// form used by SLAAC: it recovers the hardware address from the final 64 bits
+// of ip by way of ModifiedEUI64ToMAC(), so fe80::211:22ff:fe33:4455 returns
+// 00:11:22:33:44:55. If ip is not an IPv6 address ErrNotIPv6 is returned
+func DecodeEUI64Addr(ip net.IP) (net.HardwareAddr, error) {
+	if iplib.EffectiveVersion(ip) != 6 {
+		return nil, ErrNotIPv6
+	}
+	return ModifiedEUI64ToMAC(ip[8:])
+}
+
+// EUI48ToIPv6Multicast takes an Ethernet multicast MAC address of the form
+// 33:33:xx:xx:xx:xx, as described in RFC2464 section 7, and returns the IPv6
+// multicast address it was derived from. Only the final 32 bits of the group
+// survive the mapping, so the result is placed in the link-local scope: the
+// MAC 33:33:00:00:00:01 returns ff02::1. If hw is not 48 bits long or does
+// not begin with 33:33 ErrBadHardwareAddr is returned
+func EUI48ToIPv6Multicast(hw net.HardwareAddr) (net.IP, error) {
+	if len(hw) != 6 || hw[0] != 0x33 || hw[1] != 0x33 {
+		return nil, ErrBadHardwareAddr
+	}
+
+	ip := make(net.IP, 16)
+	ip[0], ip[1] = 0xff, 0x02
+	copy(ip[12:], hw[2:])
+	return ip, nil
+}
+
 // GenerateRFC7217Addr generates a pseudo-random IID from supplied input
 // parameters in compliance with RFC7217. The signature of this function
 // deviates from the one specified in that RFC only insomuch as is necessary
@@ -197,6 +230,44 @@ func GetReservationsForIP(ip net.IP) *Reservation {
 	return nil
 }
 
+// IPv6MulticastToEUI48 returns the Ethernet MAC address that the IPv6
+// multicast address ip maps to per RFC2464 section 7, which is 33:33 followed
+// by the final 32 bits of the address, so ff02::1:ff00:1234 becomes
+// 33:33:ff:00:12:34. If ip is not in ff00::/8 ErrNotMulticast is returned
+func IPv6MulticastToEUI48(ip net.IP) (net.HardwareAddr, error) {
+	if iplib.EffectiveVersion(ip) != 6 || ip[0] != 0xff {
+		return nil, ErrNotMulticast
+	}
+
+	hw := make(net.HardwareAddr, 6)
+	hw[0], hw[1] = 0x33, 0x33
+	copy(hw[2:], ip[12:])
+	return hw, nil
+}
+
+// MACToModifiedEUI64 returns the 64-bit modified EUI-64 Interface Identifier
+// for hw as described in RFC4291 appendix A, without attaching it to an IPv6
+// address. A 48-bit MAC has the octets 0xFFFE inserted in the middle to pad
+// it to 64 bits, and in either case the universal/local bit (0x02 in the
+// first octet) is inverted, so 00:11:22:33:44:55 becomes
+// 02:11:22:ff:fe:33:44:55. It is the identifier MakeEUI64Addr() produces
+// with ScopeInvert. If hw is neither 48 nor 64 bits ErrBadMACLength is
+// returned
+func MACToModifiedEUI64(hw net.HardwareAddr) ([]byte, error) {
+	var iid []byte
+	switch len(hw) {
+	case 6:
+		iid = []byte{hw[0], hw[1], hw[2], 0xff, 0xfe, hw[3], hw[4], hw[5]}
+	case 8:
+		iid = make([]byte, 8)
+		copy(iid, hw)
+	default:
+		return nil, ErrBadMACLength
+	}
+	iid[0] ^= 1 << 1
+	return iid, nil
+}
+
 // MakeEUI64Addr takes an IPv6 address, a hardware MAC address and a scope as
 // input and uses them to generate an Interface Identifier suitable for use
 // in link local, global unicast and Stateless Address Autoconfiguration
@@ -212,27 +283,24 @@ func GetReservationsForIP(ip net.IP) *Reservation {
 // * if the address is 48 bits, the octets 0xFFFE are inserted in the middle
 // of the address to pad it to 64 bits
 func MakeEUI64Addr(ip net.IP, hw net.HardwareAddr, scope Scope) net.IP {
-	tag := []byte{0xff, 0xfe}
-
 	if iplib.EffectiveVersion(ip) != 6 {
 		return nil
 	}
 
-	if len(hw) < 6 || len(hw) > 8 {
+	iid, err := MACToModifiedEUI64(hw)
+	if err != nil {
 		return nil
 	}
 
 	eui64 := make([]byte, 16)
 	copy(eui64, ip)
-
-	hwi := make([]byte, len(hw))
-	copy(hwi, hw)
-
-	if len(hwi) == 6 {
-		hwi = append(hwi[:3], append(tag, hwi[3:]...)...)
+	copy(eui64[8:], iid)
+	if scope == ScopeInvert {
+		return eui64
 	}
 
-	copy(eui64[8:], hwi)
+	// restore the original X bit so the other scopes act on the MAC as given
+	eui64[8] ^= 1 << 1
 	return setScopeBit(eui64, scope)
 }
 
@@ -246,6 +314,28 @@ func MakeOpaqueAddr(ip net.IP, hw net.HardwareAddr, counter int64, netid, secret
 	return GenerateRFC7217Addr(ip, hw, counter, netid, secret, crypto.SHA256, ScopeGlobal)
 }
 
+// ModifiedEUI64ToMAC is the inverse of MACToModifiedEUI64(), recovering the
+// hardware address from a 64-bit modified EUI-64 Interface Identifier by
+// inverting the universal/local bit. If the identifier contains the 0xFFFE
+// padding in its middle octets it was derived from a 48-bit MAC and the
+// padding is removed, otherwise the 64-bit EUI-64 is returned. If iid is not
+// 64 bits ErrBadIIDLength is returned
+func ModifiedEUI64ToMAC(iid []byte) (net.HardwareAddr, error) {
+	if len(iid) != 8 {
+		return nil, ErrBadIIDLength
+	}
+
+	var hw net.HardwareAddr
+	if iid[3] == 0xff && iid[4] == 0xfe {
+		hw = net.HardwareAddr{iid[0], iid[1], iid[2], iid[5], iid[6], iid[7]}
+	} else {
+		hw = make(net.HardwareAddr, 8)
+		copy(hw, iid)
+	}
+	hw[0] ^= 1 << 1
+	return hw, nil
+}
+
 func setScopeBit(ip net.IP, scope Scope) net.IP {
 	switch scope {
 	case ScopeGlobal:
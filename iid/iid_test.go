@@ -1,6 +1,7 @@
 package iid
 
 import (
+	"bytes"
 	"crypto"
 	_ "crypto/sha512"
 	"net"
@@ -298,3 +299,135 @@ func TestMakeOpaqueAddr(t *testing.T) {
 		}
 	}
 }
+
+var MulticastTests = []struct {
+	hwaddr string
+	inaddr string
+	outIP  string
+}{
+	{"33:33:00:00:00:01", "ff02::1", "ff02::1"},
+	{"33:33:ff:00:12:34", "ff02::1:ff00:1234", "ff02::ff00:1234"},
+	{"33:33:00:01:00:03", "ff05::1:3", "ff02::1:3"},
+	{"33:33:de:ad:be:ef", "ff0e::dead:beef", "ff02::dead:beef"},
+}
+
+func TestEUI48ToIPv6Multicast(t *testing.T) {
+	for i, tt := range MulticastTests {
+		hwaddr, _ := net.ParseMAC(tt.hwaddr)
+		ip, err := EUI48ToIPv6Multicast(hwaddr)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+			continue
+		}
+		if !ip.Equal(net.ParseIP(tt.outIP)) {
+			t.Errorf("[%d] '%s': expected %s got %s", i, tt.hwaddr, tt.outIP, ip)
+		}
+	}
+
+	for i, s := range []string{"01:00:5e:00:00:01", "33:33:00:00:00:01:00:00", "33:32:00:00:00:01"} {
+		hwaddr, _ := net.ParseMAC(s)
+		if _, err := EUI48ToIPv6Multicast(hwaddr); err != ErrBadHardwareAddr {
+			t.Errorf("[%d] '%s': expected ErrBadHardwareAddr got '%v'", i, s, err)
+		}
+	}
+}
+
+func TestIPv6MulticastToEUI48(t *testing.T) {
+	for i, tt := range MulticastTests {
+		hw, err := IPv6MulticastToEUI48(net.ParseIP(tt.inaddr))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+			continue
+		}
+		if hw.String() != tt.hwaddr {
+			t.Errorf("[%d] '%s': expected %s got %s", i, tt.inaddr, tt.hwaddr, hw)
+		}
+	}
+
+	for i, s := range []string{"2001:db8::1", "fe80::1", "224.0.0.1"} {
+		if _, err := IPv6MulticastToEUI48(net.ParseIP(s)); err != ErrNotMulticast {
+			t.Errorf("[%d] '%s': expected ErrNotMulticast got '%v'", i, s, err)
+		}
+	}
+}
+
+var ModifiedEUI64Tests = []struct {
+	hwaddr string
+	iid    []byte
+}{
+	{"00:11:22:33:44:55", []byte{0x02, 0x11, 0x22, 0xff, 0xfe, 0x33, 0x44, 0x55}},
+	{"02:11:22:33:44:55", []byte{0x00, 0x11, 0x22, 0xff, 0xfe, 0x33, 0x44, 0x55}},
+	{"bb:aa:cc:dd:ee:ff", []byte{0xb9, 0xaa, 0xcc, 0xff, 0xfe, 0xdd, 0xee, 0xff}},
+	{"99:88:77:66:55:44:33:22", []byte{0x9b, 0x88, 0x77, 0x66, 0x55, 0x44, 0x33, 0x22}},
+}
+
+func TestMACToModifiedEUI64(t *testing.T) {
+	for i, tt := range ModifiedEUI64Tests {
+		hwaddr, _ := net.ParseMAC(tt.hwaddr)
+		iid, err := MACToModifiedEUI64(hwaddr)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+			continue
+		}
+		if !bytes.Equal(iid, tt.iid) {
+			t.Errorf("[%d] '%s': expected %x got %x", i, tt.hwaddr, tt.iid, iid)
+		}
+
+		ip := MakeEUI64Addr(net.ParseIP("fe80::"), hwaddr, ScopeInvert)
+		if !bytes.Equal(ip[8:], iid) {
+			t.Errorf("[%d] '%s': does not match MakeEUI64Addr, expected %x got %x", i, tt.hwaddr, []byte(ip[8:]), iid)
+		}
+	}
+
+	hwaddr, _ := net.ParseMAC("00:11:22:33:44:55")
+	iid, _ := MACToModifiedEUI64(hwaddr)
+	iid[1] = 0
+	if hwaddr[1] != 0x11 {
+		t.Errorf("input hardware address was modified")
+	}
+
+	for i, hw := range []net.HardwareAddr{nil, {0, 1, 2, 3}, make(net.HardwareAddr, 20)} {
+		if _, err := MACToModifiedEUI64(hw); err != ErrBadMACLength {
+			t.Errorf("[%d] expected ErrBadMACLength got '%v'", i, err)
+		}
+	}
+}
+
+func TestModifiedEUI64ToMAC(t *testing.T) {
+	for i, tt := range ModifiedEUI64Tests {
+		hw, err := ModifiedEUI64ToMAC(tt.iid)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+			continue
+		}
+		if hw.String() != tt.hwaddr {
+			t.Errorf("[%d] %x: expected %s got %s", i, tt.iid, tt.hwaddr, hw)
+		}
+	}
+
+	for i, iid := range [][]byte{nil, {0x02, 0x11, 0x22, 0xff, 0xfe, 0x33}} {
+		if _, err := ModifiedEUI64ToMAC(iid); err != ErrBadIIDLength {
+			t.Errorf("[%d] expected ErrBadIIDLength got '%v'", i, err)
+		}
+	}
+}
+
+func TestDecodeEUI64Addr(t *testing.T) {
+	for i, tt := range ModifiedEUI64Tests {
+		hwaddr, _ := net.ParseMAC(tt.hwaddr)
+		hw, err := DecodeEUI64Addr(MakeEUI64Addr(net.ParseIP("2001:db8::"), hwaddr, ScopeInvert))
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+			continue
+		}
+		if hw.String() != tt.hwaddr {
+			t.Errorf("[%d] expected %s got %s", i, tt.hwaddr, hw)
+		}
+	}
+
+	for i, s := range []string{"192.168.1.1", ""} {
+		if _, err := DecodeEUI64Addr(net.ParseIP(s)); err != ErrNotIPv6 {
+			t.Errorf("[%d] '%s': expected ErrNotIPv6 got '%v'", i, s, err)
+		}
+	}
+}
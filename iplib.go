@@ -41,7 +41,9 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"math/bits"
 	"net"
+	"strconv"
 	"strings"
 
 	"lukechampine.com/uint128"
@@ -62,11 +64,49 @@ const (
 var (
 	ErrAddressOutOfRange = errors.New("address is not a part of this netblock")
 	ErrBadMaskLength     = errors.New("illegal mask length provided")
+	ErrBadNibble         = errors.New("nibble value must be between 0 and 15")
 	ErrBroadcastAddress  = errors.New("address is the broadcast address of this netblock (and not considered usable)")
+	ErrInsufficientSpace = errors.New("netblock does not have enough free space to satisfy the request")
 	ErrNetworkAddress    = errors.New("address is the network address of this netblock (and not considered usable)")
 	ErrNoValidRange      = errors.New("no netblock can be found between the supplied values")
 )
 
+// ParseError is returned when a string cannot be parsed as a network. Input
+// is the complete string that was supplied and Token is the portion of it
+// found to be at fault, for example the mask in "192.168.0.0/33". Err is the
+// underlying error, which is usually a *net.ParseError, and the message
+// returned by Error() is that of Err
+type ParseError struct {
+	Input string
+	Token string
+	Err   error
+}
+
+// Error implements the error interface
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error, for use with errors.Is and errors.As
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newCIDRParseError returns a *ParseError wrapping err, determining which
+// part of the CIDR string s is to blame
+func newCIDRParseError(s string, err error) *ParseError {
+	addr, mask, ok := strings.Cut(s, "/")
+	token := s
+	if ok {
+		if net.ParseIP(addr) == nil {
+			token = addr
+		} else {
+			token = mask
+		}
+	}
+	return &ParseError{Input: s, Token: token, Err: err}
+}
+
 // ByIP implements sort.Interface for net.IP addresses
 type ByIP []net.IP
 
@@ -85,10 +125,39 @@ func (bi ByIP) Swap(a, b int) {
 // returns true if the LHS should sort before the RHS. For details on the
 // implementation, see CompareIPs()
 func (bi ByIP) Less(a, b int) bool {
-	val := CompareIPs(bi[a], bi[b])
+	val := CompareIPsFast(bi[a], bi[b])
 	return val == -1
 }
 
+// AddressFamily returns "IPv4" or "IPv6" depending on the EffectiveVersion of
+// the supplied net.IP, so 4in6 addresses are labeled "IPv4". If the address
+// is nil an empty string is returned
+func AddressFamily(ip net.IP) string {
+	switch EffectiveVersion(ip) {
+	case IP4Version:
+		return "IPv4"
+	case IP6Version:
+		return "IPv6"
+	}
+	return ""
+}
+
+// ARPANibbles returns the 32 4-bit nibbles of an IPv6 address in the
+// reverse order used by ip6.arpa, i.e. the nibbles that IP6ToARPA would
+// produce as a []byte of values 0-15 and without the domain suffix. If ip is
+// not a valid address nil is returned
+func ARPANibbles(ip net.IP) []byte {
+	ip = ip.To16()
+	if ip == nil {
+		return nil
+	}
+	nibbles := make([]byte, 0, 32)
+	for i := len(ip) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, ip[i]&0x0f, ip[i]>>4)
+	}
+	return nibbles
+}
+
 // ARPAToIP takes a strings containing an ARPA domain and returns the
 // corresponding net.IP
 func ARPAToIP(s string) net.IP {
@@ -137,6 +206,26 @@ func ARPAToIP6(s string) net.IP {
 	return h
 }
 
+// AreAdjacent returns true if b is the address immediately following a, as
+// returned by NextIP(a). The addresses must be of the same effective version
+// and the maximum address of either version, which has no successor, always
+// returns false. It does not allocate
+func AreAdjacent(a, b net.IP) bool {
+	va := EffectiveVersion(a)
+	if va == 0 || va != EffectiveVersion(b) {
+		return false
+	}
+	if va == IP4Version {
+		ua := IP4ToUint32(a)
+		return ua != ^uint32(0) && ua+1 == IP4ToUint32(b)
+	}
+	if len(a) != net.IPv6len || len(b) != net.IPv6len {
+		return false
+	}
+	ua := IP6ToUint128(a)
+	return !ua.Equals(uint128.Max) && ua.Add64(1).Equals(IP6ToUint128(b))
+}
+
 // BigintToIP6 converts a big.Int to an ip6 address and returns it as a net.IP
 func BigintToIP6(z *big.Int) net.IP {
 	b := z.Bytes()
@@ -163,6 +252,18 @@ func CompareIPs(a, b net.IP) int {
 	return bytes.Compare(a.To16(), b.To16())
 }
 
+// CompareIPsFast returns the same result as CompareIPs but skips converting
+// its arguments to 16-byte form when they are already the same length, which
+// makes it noticeably cheaper for 4-byte addresses in hot paths such as
+// sorting. Inputs of differing lengths, such as a 4-byte address compared to
+// a 16-byte one, fall back to CompareIPs
+func CompareIPsFast(a, b net.IP) int {
+	if len(a) == len(b) && (len(a) == net.IPv4len || len(a) == net.IPv6len) {
+		return bytes.Compare(a, b)
+	}
+	return CompareIPs(a, b)
+}
+
 // CompareNets compares two iplib.Net objects by evaluating their network
 // address (the first address in a CIDR range) and, if they're equal,
 // comparing their netmasks (smallest wins). This means that if a network is
@@ -185,6 +286,29 @@ func CompareNets(a, b Net) int {
 	return 1
 }
 
+// CompareNetsBySpecificity is the counterpart of CompareNets for
+// longest-prefix-match ordering: networks are still compared first by their
+// network address, but when those are equal the network with the longer
+// netmask, i.e. the more specific one, sorts first. So a network compared to
+// one of its subnets that shares its network address sorts after it
+func CompareNetsBySpecificity(a, b Net) int {
+	val := bytes.Compare(a.IP(), b.IP())
+	if val != 0 {
+		return val
+	}
+
+	am, _ := a.Mask().Size()
+	bm, _ := b.Mask().Size()
+
+	if am == bm {
+		return 0
+	}
+	if am > bm {
+		return -1
+	}
+	return 1
+}
+
 // CopyIP creates a new net.IP object containing the same data as the supplied
 // net.IP (e.g. creates a new array and duplicates the contents)
 func CopyIP(ip net.IP) net.IP {
@@ -269,6 +393,32 @@ func DeltaIP6(a, b net.IP) uint128.Uint128 {
 	return bi.Sub(ai)
 }
 
+// DistinguishingBit returns the position of the first bit at which a and b
+// differ, counted from 0 at the most significant bit as with GetBit(). This
+// is also the length of the longest prefix they share, so it gives the
+// branch point for the two addresses in a radix tree: 10.0.0.0 and 10.0.0.1
+// are distinguished by bit 31. v4 addresses, including those in IPv4-mapped
+// form, are compared as 32 bits. If a and b are equal, are different
+// versions, or either is not a valid address ErrNoValidRange is returned
+func DistinguishingBit(a, b net.IP) (int, error) {
+	version := EffectiveVersion(a)
+	if version == 0 || version != EffectiveVersion(b) {
+		return 0, ErrNoValidRange
+	}
+
+	if version == IP4Version {
+		a, b = ForceIP4(a), ForceIP4(b)
+	} else {
+		a, b = a.To16(), b.To16()
+	}
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x), nil
+		}
+	}
+	return 0, ErrNoValidRange
+}
+
 // EffectiveVersion returns 4 if the net.IP either contains a v4 address or if
 // it contains the v4-encapsulating v6 address range ::ffff. Note that the
 // second example below is a v6 address but reports as v4 because it is in the
@@ -314,6 +464,15 @@ func ForceIP4(ip net.IP) net.IP {
 	return ip
 }
 
+// GetBit returns true if the bit at position pos of ip is set. Positions are
+// counted from 0 at the most significant bit and the valid range depends on
+// the version of the address: 0-31 for v4 (including 4in6 addresses) and
+// 0-127 for v6. GetBit panics if pos falls outside of that range
+func GetBit(ip net.IP, pos int) bool {
+	i, bit := bitPosition(ip, pos)
+	return ip[i]&bit != 0
+}
+
 // HexStringToIP converts a hexadecimal string to an IP address. If the given
 // string cannot be converted nil is returned. Input strings may contain '.'
 // or ':'
@@ -335,6 +494,37 @@ func HexStringToIP(s string) net.IP {
 	return h
 }
 
+// IPSortKey returns a fixed-length 17-byte key for ip whose byte-wise order,
+// as with bytes.Compare, sorts every IPv4 address before every IPv6 address
+// and addresses of the same version numerically. This makes it suitable for
+// keying ordered key-value stores and b-trees. The layout is one byte holding
+// the effective IP version (4 or 6) followed by 16 address bytes; an IPv4
+// address, whether 4-byte or IPv4-mapped, occupies the final 4 of those with
+// the preceding 12 left as zero. Note that this differs from the ordering of
+// CompareIPs, which places IPv4-mapped addresses within the IPv6 space. If ip
+// is not a valid address nil is returned
+func IPSortKey(ip net.IP) []byte {
+	key := make([]byte, 1+net.IPv6len)
+	switch EffectiveVersion(ip) {
+	case IP4Version:
+		v4 := ip.To4()
+		if v4 == nil {
+			return nil
+		}
+		key[0] = IP4Version
+		copy(key[1+net.IPv6len-net.IPv4len:], v4)
+	case IP6Version:
+		if len(ip) != net.IPv6len {
+			return nil
+		}
+		key[0] = IP6Version
+		copy(key[1:], ip)
+	default:
+		return nil
+	}
+	return key
+}
+
 // IPToARPA takes a net.IP as input and returns a string of the version-
 // appropriate ARPA DNS name
 func IPToARPA(ip net.IP) string {
@@ -480,6 +670,43 @@ func IncrementIP6By(ip net.IP, count uint128.Uint128) net.IP {
 	return Uint128ToIP6(nz)
 }
 
+// HostBitsAllClear returns true if every bit of ip to the right of masklen is
+// zero, that is if ip is the network address of the masklen network
+// containing it. It is the prefix-aware counterpart of IsAllZeroes(). As with
+// IsSubnetBoundary() v4 addresses, including those in IPv4-mapped form, are
+// checked against a 32-bit mask and v6 addresses against a 128-bit one. If
+// masklen falls outside of that range, or ip is not a valid address, false
+// is returned
+func HostBitsAllClear(ip net.IP, masklen int) bool {
+	xip, mask, ok := hostBitsMask(ip, masklen)
+	if !ok {
+		return false
+	}
+	for i := range xip {
+		if xip[i]&^mask[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// HostBitsAllSet returns true if every bit of ip to the right of masklen is
+// one, that is if ip is the broadcast address of the masklen network
+// containing it. It is the prefix-aware counterpart of IsAllOnes() and
+// follows the same rules as HostBitsAllClear() for versions and masklen
+func HostBitsAllSet(ip net.IP, masklen int) bool {
+	xip, mask, ok := hostBitsMask(ip, masklen)
+	if !ok {
+		return false
+	}
+	for i := range xip {
+		if xip[i]|mask[i] != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
 // Is4in6 returns true if the supplied net.IP is an IPv4 address encapsulated
 // in an IPv6 address. It is very common for the net library to re-write v4
 // addresses into v6 addresses prefixed 0000:0000:0000:0000:ffff. When this
@@ -526,6 +753,97 @@ func IsAllZeroes(ip net.IP) bool {
 	return true
 }
 
+// IsSubnetBoundary returns true if ip could be the network address of a
+// network of length masklen, that is if all of its bits to the right of
+// masklen are zero. It is version-aware: v4 addresses, including those in
+// IPv4-mapped form, are checked against a 32-bit mask and v6 addresses
+// against a 128-bit one. If masklen falls outside of that range, or ip is not
+// a valid address, false is returned
+func IsSubnetBoundary(ip net.IP, masklen int) bool {
+	return HostBitsAllClear(ip, masklen)
+}
+
+// IsSubnetRouterAnycast returns true if the supplied net.IP is the RFC4291
+// Subnet-Router anycast address for a network of length prefixlen, that is if
+// all of the address bits to the right of prefixlen are zero. Such an address
+// should not be assigned to a host. It will always return false for v4
+// addresses or if prefixlen falls outside of 0-128
+func IsSubnetRouterAnycast(ip net.IP, prefixlen int) bool {
+	if EffectiveVersion(ip) != IP6Version || prefixlen < 0 || prefixlen > 128 {
+		return false
+	}
+	return ip.Mask(net.CIDRMask(prefixlen, 128)).Equal(ip)
+}
+
+// MaskIsContiguous returns true if m is a proper prefix mask, meaning all of
+// its 1 bits are to the left of all of its 0 bits. Arbitrary bitmasks such as
+// the wildcard masks found in some ACLs will return false, as will an empty
+// mask
+func MaskIsContiguous(m net.IPMask) bool {
+	_, bits := m.Size()
+	return bits != 0
+}
+
+// MaskOnes returns the number of 1 bits in m. Unlike net.IPMask.Size(),
+// which returns 0 for any mask that is not a contiguous prefix, it counts
+// every set bit regardless of position
+func MaskOnes(m net.IPMask) int {
+	ones := 0
+	for _, b := range m {
+		ones += bits.OnesCount8(b)
+	}
+	return ones
+}
+
+// MatchUnderMask returns true if ip and pattern are identical in every bit
+// that is set in mask, i.e. if ip AND mask equals pattern AND mask. Unlike
+// Net.Contains() the mask may be any bitmask, contiguous or not, which makes
+// this the basic match operation of an ACL or packet classifier. The
+// addresses are normalized to the length of the mask first: a 4-byte mask
+// matches v4 addresses in either their 4 or 16-byte form, while a 16-byte
+// mask compares the 16-byte forms. If either address cannot be represented at
+// the mask's length, or the mask is neither 4 nor 16 bytes, false is returned
+func MatchUnderMask(ip, pattern net.IP, mask net.IPMask) bool {
+	var a, b net.IP
+	switch len(mask) {
+	case net.IPv4len:
+		a, b = ip.To4(), pattern.To4()
+	case net.IPv6len:
+		a, b = ip.To16(), pattern.To16()
+	default:
+		return false
+	}
+	if a == nil || b == nil {
+		return false
+	}
+
+	for i := range mask {
+		if a[i]&mask[i] != b[i]&mask[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// MaxAddr returns the highest possible address for the given IP version,
+// 255.255.255.255 for IP4Version or ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff
+// for IP6Version. Any other version returns nil
+func MaxAddr(version int) net.IP {
+	if version != IP4Version && version != IP6Version {
+		return nil
+	}
+	return generateNetLimits(version, 255)
+}
+
+// MinAddr returns the lowest possible address for the given IP version,
+// 0.0.0.0 for IP4Version or :: for IP6Version. Any other version returns nil
+func MinAddr(version int) net.IP {
+	if version != IP4Version && version != IP6Version {
+		return nil
+	}
+	return generateNetLimits(version, 0)
+}
+
 // NextIP returns a net.IP incremented by one from the input address
 func NextIP(ip net.IP) net.IP {
 	var xip []byte
@@ -544,6 +862,68 @@ func NextIP(ip net.IP) net.IP {
 	return ip // if we're already at the end of range, don't wrap
 }
 
+// NibblesToIP6 is the inverse of ARPANibbles, it accepts a sequence of
+// nibbles in ip6.arpa order and returns the IPv6 address they describe along
+// with the implied prefix length. The sequence may be partial, as with the
+// labels of a delegated zone such as 8.b.d.0.1.0.0.2.ip6.arpa, in which case
+// the nibbles are taken to be the leading portion of the address and the
+// prefix length is 4 bits per nibble. More than 32 nibbles will return
+// ErrBadMaskLength and a nibble outside of 0-15 will return ErrBadNibble
+func NibblesToIP6(nibbles []byte) (net.IP, int, error) {
+	if len(nibbles) > 32 {
+		return nil, 0, ErrBadMaskLength
+	}
+	ip := make(net.IP, net.IPv6len)
+	for i, nib := range nibbles {
+		if nib > 0x0f {
+			return nil, 0, ErrBadNibble
+		}
+		pos := len(nibbles) - 1 - i
+		if pos%2 == 0 {
+			ip[pos/2] |= nib << 4
+		} else {
+			ip[pos/2] |= nib
+		}
+	}
+	return ip, len(nibbles) * 4, nil
+}
+
+// NormalizeIPs returns a new slice containing the supplied addresses with any
+// RFC4291 IPv4-mapped IPv6 addresses converted to native 4-byte v4 addresses
+// via ForceIP4(). Native v4 and true v6 addresses are left untouched. This is
+// useful before sorting or de-duplicating a list drawn from several sources,
+// where the same v4 address may otherwise appear in both forms
+func NormalizeIPs(ips []net.IP) []net.IP {
+	xips := make([]net.IP, len(ips))
+	for i, ip := range ips {
+		if Is4in6(ip) {
+			ip = ForceIP4(ip)
+		}
+		xips[i] = ip
+	}
+	return xips
+}
+
+// ParseIPWithZone parses an address which may carry an RFC4007 zone
+// identifier, as is common for link-local addresses in system output, e.g.
+// fe80::1%eth0. The address is returned as a net.IP with the zone removed,
+// and the zone is returned separately; if there is no zone it will be an
+// empty string. If the address cannot be parsed, or if a zone is attached to
+// an IPv4 address or is empty, a *net.ParseError is returned
+func ParseIPWithZone(s string) (net.IP, string, error) {
+	perr := &net.ParseError{Type: "IP address", Text: s}
+
+	addr, zone, hasZone := strings.Cut(s, "%")
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, "", perr
+	}
+	if hasZone && (zone == "" || strings.Contains(addr, ".")) {
+		return nil, "", perr
+	}
+	return ip, zone, nil
+}
+
 // PreviousIP returns a net.IP decremented by one from the input address
 func PreviousIP(ip net.IP) net.IP {
 	var xip []byte
@@ -562,6 +942,21 @@ func PreviousIP(ip net.IP) net.IP {
 	return ip // if we're already at beginning of range, don't wrap
 }
 
+// SetBit returns a copy of ip with the bit at position pos set to 1 if val is
+// true or to 0 if it is false. Positions are counted as in GetBit(), and as
+// with GetBit this function panics if pos is out of range for the version of
+// the supplied address
+func SetBit(ip net.IP, pos int, val bool) net.IP {
+	i, bit := bitPosition(ip, pos)
+	xip := CopyIP(ip)
+	if val {
+		xip[i] |= bit
+	} else {
+		xip[i] &^= bit
+	}
+	return xip
+}
+
 // Uint32ToIP4 converts a uint32 to an ip4 address and returns it as a net.IP
 func Uint32ToIP4(i uint32) net.IP {
 	ip := make([]byte, 4)
@@ -586,6 +981,95 @@ func Uint128ToIP6(i uint128.Uint128) net.IP {
 	return ip
 }
 
+// ValidateIPString parses s as an IP address, returning the address, the
+// version implied by its notation and, if it could not be parsed, a
+// *ParseError whose message explains why: an empty string, a bad character,
+// the wrong number of octets or groups, an out-of-range or zero-padded
+// octet, an oversized group or a repeated "::". The Token field of the error
+// holds the offending portion of s. Dotted-decimal addresses are version 4
+// and are returned in 4-byte form, while anything in colon notation,
+// including IPv4-mapped addresses like ::ffff:192.0.2.1, is version 6. Zone
+// identifiers are rejected, use ValidateIPStringWithZone() for addresses which
+// may carry them
+func ValidateIPString(s string) (net.IP, int, error) {
+	fail := func(token, reason string) (net.IP, int, error) {
+		return nil, 0, &ParseError{Input: s, Token: token, Err: errors.New(reason)}
+	}
+
+	switch {
+	case s == "":
+		return fail(s, "empty IP address")
+	case strings.Contains(s, "%"):
+		_, zone, _ := strings.Cut(s, "%")
+		return fail(zone, "zone identifier not permitted in IP address")
+	case !strings.Contains(s, ":"):
+		if reason, token := validateIP4String(s); reason != "" {
+			return fail(token, reason)
+		}
+		return ForceIP4(net.ParseIP(s)), IP4Version, nil
+	}
+
+	if i := strings.IndexFunc(s, func(r rune) bool {
+		return !strings.ContainsRune("0123456789abcdefABCDEF:.", r)
+	}); i >= 0 {
+		return fail(s[i:i+1], "bad character in IPv6 address")
+	}
+	if strings.Count(s, "::") > 1 {
+		return fail("::", "multiple \"::\" in IPv6 address")
+	}
+
+	groups := strings.Split(s, ":")
+	want := 8
+	if last := groups[len(groups)-1]; strings.Contains(last, ".") {
+		if reason, token := validateIP4String(last); reason != "" {
+			return fail(token, reason)
+		}
+		groups, want = groups[:len(groups)-1], 6
+	}
+	count := 0
+	for _, g := range groups {
+		if len(g) > 4 {
+			return fail(g, "IPv6 group has more than 4 hex digits")
+		}
+		if g != "" {
+			count++
+		}
+	}
+	if (strings.Contains(s, "::") && count >= want) || (!strings.Contains(s, "::") && count != want) {
+		return fail(s, "wrong number of groups in IPv6 address")
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return fail(s, "invalid IPv6 address")
+	}
+	return ip, IP6Version, nil
+}
+
+// ValidateIPStringWithZone behaves as ValidateIPString() but permits an
+// RFC4007 zone identifier on IPv6 addresses, e.g. fe80::1%eth0, returning it
+// separately with the address. If there is no zone it will be an empty
+// string. An empty zone, or a zone attached to an IPv4 address, is reported
+// as a *ParseError in the same way as any other fault in s
+func ValidateIPStringWithZone(s string) (net.IP, int, string, error) {
+	addr, zone, hasZone := strings.Cut(s, "%")
+	ip, version, err := ValidateIPString(addr)
+	if err != nil {
+		if perr, ok := err.(*ParseError); ok {
+			perr.Input = s
+		}
+		return nil, 0, "", err
+	}
+
+	switch {
+	case hasZone && zone == "":
+		return nil, 0, "", &ParseError{Input: s, Token: "%", Err: errors.New("empty zone identifier in IP address")}
+	case hasZone && version == IP4Version:
+		return nil, 0, "", &ParseError{Input: s, Token: zone, Err: errors.New("zone identifier not permitted in IPv4 address")}
+	}
+	return ip, version, zone, nil
+}
+
 // Version returns 4 if the net.IP contains a v4 address. It will return 6 for
 // any v6 address, including the v4-encapsulating v6 address range ::ffff.
 // Contrast with EffectiveVersion above and note that in the provided example
@@ -604,6 +1088,43 @@ func Version(ip net.IP) int {
 	return IP6Version
 }
 
+// validateIP4String checks that s is a dotted-decimal IPv4 address, if it
+// is not the reason and the offending token are returned
+func validateIP4String(s string) (string, string) {
+	octets := strings.Split(s, ".")
+	if len(octets) != 4 {
+		return "wrong number of octets in IPv4 address", s
+	}
+	for _, o := range octets {
+		if o == "" {
+			return "empty octet in IPv4 address", s
+		}
+		if i := strings.IndexFunc(o, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+			return "bad character in IPv4 address", o[i : i+1]
+		}
+		if len(o) > 1 && o[0] == '0' {
+			return "IPv4 octet has a leading zero", o
+		}
+		if v, err := strconv.Atoi(o); err != nil || v > 255 {
+			return "IPv4 octet out of range", o
+		}
+	}
+	return "", ""
+}
+
+// bitPosition returns the index of the byte within ip holding bit pos and a
+// mask selecting that bit, accounting for v4 addresses stored in 16 bytes
+func bitPosition(ip net.IP, pos int) (int, byte) {
+	offset, bits := 0, 128
+	if EffectiveVersion(ip) == IP4Version {
+		offset, bits = len(ip)-4, 32
+	}
+	if ip == nil || pos < 0 || pos >= bits {
+		panic(fmt.Sprintf("iplib: bit position %d out of range for %s", pos, ip))
+	}
+	return offset + pos/8, 0x80 >> uint(pos%8)
+}
+
 func generateNetLimits(version int, filler byte) net.IP {
 	var b []byte
 	if version == IP6Version {
@@ -615,3 +1136,22 @@ func generateNetLimits(version int, filler byte) net.IP {
 	}
 	return b
 }
+
+// hostBitsMask returns ip in its native length along with a netmask of
+// masklen for that length, or false if ip is invalid or masklen is out of
+// range for its version
+func hostBitsMask(ip net.IP, masklen int) (net.IP, net.IPMask, bool) {
+	var all int
+	switch EffectiveVersion(ip) {
+	case IP4Version:
+		ip, all = ForceIP4(ip), 32
+	case IP6Version:
+		ip, all = ip.To16(), 128
+	default:
+		return nil, nil, false
+	}
+	if masklen < 0 || masklen > all {
+		return nil, nil, false
+	}
+	return ip, net.CIDRMask(masklen, all), true
+}
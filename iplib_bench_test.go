@@ -42,6 +42,34 @@ func BenchmarkARPAToIP6(b *testing.B) {
 	}
 }
 
+func BenchmarkCompareIPs4(b *testing.B) {
+	x, y := net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}
+	for i := 0; i < b.N; i++ {
+		CompareIPs(x, y)
+	}
+}
+
+func BenchmarkCompareIPsFast4(b *testing.B) {
+	x, y := net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}
+	for i := 0; i < b.N; i++ {
+		CompareIPsFast(x, y)
+	}
+}
+
+func BenchmarkCompareIPs6(b *testing.B) {
+	x, y := net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")
+	for i := 0; i < b.N; i++ {
+		CompareIPs(x, y)
+	}
+}
+
+func BenchmarkCompareIPsFast6(b *testing.B) {
+	x, y := net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")
+	for i := 0; i < b.N; i++ {
+		CompareIPsFast(x, y)
+	}
+}
+
 func Benchmark_DeltaIP4(b *testing.B) {
 	var xip = net.IP{10, 255, 255, 255}
 	var zip = net.IP{192, 168, 23, 5}
@@ -280,3 +308,35 @@ func BenchmarkNet6_Enumerate(b *testing.B) {
 		n6.Enumerate(8192, 1024)
 	}
 }
+
+func benchmarkMatcherNets() []Net {
+	nets := []Net{}
+	xnet := Net4FromStr("10.0.0.0/24")
+	for i := 0; i < 1024; i++ {
+		nets = append(nets, xnet)
+		xnet = xnet.NextNet(24).NextNet(24)
+	}
+	return nets
+}
+
+func BenchmarkMatcher_Contains(b *testing.B) {
+	m := Prepare(benchmarkMatcherNets())
+	xip := net.IP{10, 0, 255, 1}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = m.Contains(xip)
+	}
+}
+
+func BenchmarkNet_ContainsLoop(b *testing.B) {
+	nets := benchmarkMatcherNets()
+	xip := net.IP{10, 0, 255, 1}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, n := range nets {
+			if n.Contains(xip) {
+				break
+			}
+		}
+	}
+}
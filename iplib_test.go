@@ -2,10 +2,13 @@ package iplib
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"math/big"
 	"net"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"lukechampine.com/uint128"
@@ -562,6 +565,21 @@ func Test_EffectiveVersion(t *testing.T) {
 	}
 }
 
+func TestAddressFamily(t *testing.T) {
+	for i, tt := range IPVersionTests {
+		want := "IPv4"
+		if tt.eversion == IP6Version {
+			want = "IPv6"
+		}
+		if family := AddressFamily(tt.ipaddr); family != want {
+			t.Errorf("[%d] want %s got %s", i, want, family)
+		}
+	}
+	if family := AddressFamily(nil); family != "" {
+		t.Errorf("want empty string for nil, got %s", family)
+	}
+}
+
 func Test_EffectiveVersionNil(t *testing.T) {
 	eversion := EffectiveVersion(nil)
 	if eversion != 0 {
@@ -622,6 +640,31 @@ var isAllTests = []struct {
 	{net.ParseIP("2001:db8::1"), false, false, false},
 }
 
+func TestCompareIPsFast(t *testing.T) {
+	ips := []net.IP{
+		nil,
+		{},
+		{10, 0, 0, 1},
+		{10, 0, 0, 2},
+		{255, 255, 255, 255},
+		{1, 2, 3, 4, 5},
+		{1, 2, 3, 4, 6},
+		net.ParseIP("10.0.0.1"),
+		net.ParseIP("10.0.0.2"),
+		net.ParseIP("0.0.0.0"),
+		net.ParseIP("::"),
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"),
+	}
+	for i, a := range ips {
+		for j, b := range ips {
+			if want, got := CompareIPs(a, b), CompareIPsFast(a, b); want != got {
+				t.Errorf("[%d, %d] CompareIPsFast(%s, %s) want %d got %d", i, j, a, b, want, got)
+			}
+		}
+	}
+}
+
 func TestIs4in6(t *testing.T) {
 	for i, tt := range isAllTests {
 		v := Is4in6(tt.ipaddr)
@@ -631,6 +674,94 @@ func TestIs4in6(t *testing.T) {
 	}
 }
 
+var subnetRouterAnycastTests = []struct {
+	ipaddr    net.IP
+	prefixlen int
+	anycast   bool
+}{
+	{net.ParseIP("2001:db8::"), 64, true},
+	{net.ParseIP("2001:db8::"), 32, true},
+	{net.ParseIP("2001:db8::1"), 64, false},
+	{net.ParseIP("2001:db8:0:1::"), 64, true},
+	{net.ParseIP("2001:db8:0:1::"), 48, false},
+	{net.ParseIP("2001:db8::1"), 128, true},
+	{net.ParseIP("::"), 0, true},
+	{net.ParseIP("2001:db8::"), 129, false},
+	{net.ParseIP("2001:db8::"), -1, false},
+	{net.ParseIP("192.168.0.0"), 24, false},
+	{net.IP{192, 168, 0, 0}, 24, false},
+}
+
+func TestIsSubnetRouterAnycast(t *testing.T) {
+	for i, tt := range subnetRouterAnycastTests {
+		if v := IsSubnetRouterAnycast(tt.ipaddr, tt.prefixlen); v != tt.anycast {
+			t.Errorf("[%d] %s/%d: want %t got %t", i, tt.ipaddr, tt.prefixlen, tt.anycast, v)
+		}
+	}
+}
+
+var isSubnetBoundaryTests = []struct {
+	ipaddr  net.IP
+	masklen int
+	aligned bool
+}{
+	{net.ParseIP("192.168.1.0"), 24, true},
+	{net.ParseIP("192.168.1.0"), 23, false},
+	{net.ParseIP("192.168.2.0"), 23, true},
+	{net.ParseIP("192.168.1.128"), 25, true},
+	{net.ParseIP("192.168.1.128"), 24, false},
+	{net.ParseIP("192.168.1.1"), 32, true},
+	{net.ParseIP("0.0.0.0"), 0, true},
+	{net.ParseIP("10.0.0.0"), 0, false},
+	{net.IP{10, 0, 0, 0}, 8, true},
+	{net.ParseIP("10.0.0.0"), 33, false},
+	{net.ParseIP("10.0.0.0"), 64, false},
+	{net.ParseIP("10.0.0.0"), -1, false},
+	{net.ParseIP("2001:db8::"), 32, true},
+	{net.ParseIP("2001:db8::"), 28, false},
+	{net.ParseIP("2001:db8:0:100::"), 56, true},
+	{net.ParseIP("2001:db8:0:100::"), 55, false},
+	{net.ParseIP("2001:db8:0:200::"), 55, true},
+	{net.ParseIP("2001:db8:0:180::"), 56, false},
+	{net.ParseIP("2001:db8::1"), 128, true},
+	{net.ParseIP("2001:db8::"), 129, false},
+	{nil, 0, false},
+}
+
+func TestIsSubnetBoundary(t *testing.T) {
+	for i, tt := range isSubnetBoundaryTests {
+		if v := IsSubnetBoundary(tt.ipaddr, tt.masklen); v != tt.aligned {
+			t.Errorf("[%d] %s/%d: want %t got %t", i, tt.ipaddr, tt.masklen, tt.aligned, v)
+		}
+	}
+}
+
+func TestNormalizeIPs(t *testing.T) {
+	ips := []net.IP{}
+	for _, tt := range isAllTests {
+		ips = append(ips, tt.ipaddr)
+	}
+	xips := NormalizeIPs(ips)
+	if len(xips) != len(ips) {
+		t.Fatalf("want %d addresses got %d", len(ips), len(xips))
+	}
+	for i, tt := range isAllTests {
+		wantLen := len(tt.ipaddr)
+		if tt.is4in6 {
+			wantLen = 4
+		}
+		if len(xips[i]) != wantLen {
+			t.Errorf("[%d] want length %d got %d", i, wantLen, len(xips[i]))
+		}
+		if !xips[i].Equal(tt.ipaddr) {
+			t.Errorf("[%d] want %s got %s", i, tt.ipaddr, xips[i])
+		}
+		if len(ips[i]) != len(tt.ipaddr) {
+			t.Errorf("[%d] input slice was modified", i)
+		}
+	}
+}
+
 func TestIsAllOnes(t *testing.T) {
 	for i, tt := range isAllTests {
 		v := IsAllOnes(tt.ipaddr)
@@ -648,3 +779,485 @@ func TestIsAllZeroes(t *testing.T) {
 		}
 	}
 }
+
+var bitTests = []struct {
+	ip  net.IP
+	pos int
+	set net.IP
+	clr net.IP
+	was bool
+}{
+	{net.ParseIP("192.168.0.0"), 0, net.ParseIP("192.168.0.0"), net.ParseIP("64.168.0.0"), true},
+	{net.ParseIP("192.168.0.0"), 31, net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.0"), false},
+	{net.IP{10, 0, 0, 0}, 8, net.ParseIP("10.128.0.0"), net.ParseIP("10.0.0.0"), false},
+	{net.ParseIP("2001:db8::"), 0, net.ParseIP("a001:db8::"), net.ParseIP("2001:db8::"), false},
+	{net.ParseIP("2001:db8::"), 2, net.ParseIP("2001:db8::"), net.ParseIP("0001:db8::"), true},
+	{net.ParseIP("2001:db8::"), 127, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::"), false},
+}
+
+func TestGetBitSetBit(t *testing.T) {
+	for i, tt := range bitTests {
+		orig := CopyIP(tt.ip)
+		if v := GetBit(tt.ip, tt.pos); v != tt.was {
+			t.Errorf("[%d] GetBit(%s, %d): want %t got %t", i, tt.ip, tt.pos, tt.was, v)
+		}
+		if ip := SetBit(tt.ip, tt.pos, true); !ip.Equal(tt.set) || !GetBit(ip, tt.pos) {
+			t.Errorf("[%d] SetBit(%s, %d, true): want %s got %s", i, tt.ip, tt.pos, tt.set, ip)
+		}
+		if ip := SetBit(tt.ip, tt.pos, false); !ip.Equal(tt.clr) || GetBit(ip, tt.pos) {
+			t.Errorf("[%d] SetBit(%s, %d, false): want %s got %s", i, tt.ip, tt.pos, tt.clr, ip)
+		}
+		if !orig.Equal(tt.ip) || len(orig) != len(tt.ip) {
+			t.Errorf("[%d] input address was modified", i)
+		}
+	}
+}
+
+func TestGetBitOutOfRange(t *testing.T) {
+	for i, tt := range []struct {
+		ip  net.IP
+		pos int
+	}{
+		{net.ParseIP("192.168.0.0"), 32},
+		{net.ParseIP("192.168.0.0"), -1},
+		{net.ParseIP("2001:db8::"), 128},
+		{nil, 0},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("[%d] GetBit(%s, %d) did not panic", i, tt.ip, tt.pos)
+				}
+			}()
+			GetBit(tt.ip, tt.pos)
+		}()
+	}
+}
+
+var parseIPWithZoneTests = []struct {
+	s    string
+	ip   net.IP
+	zone string
+	err  bool
+}{
+	{"fe80::1%eth0", net.ParseIP("fe80::1"), "eth0", false},
+	{"fe80::1%1", net.ParseIP("fe80::1"), "1", false},
+	{"fe80::1", net.ParseIP("fe80::1"), "", false},
+	{"2001:db8::1%en0", net.ParseIP("2001:db8::1"), "en0", false},
+	{"192.168.1.1", net.ParseIP("192.168.1.1"), "", false},
+	{"192.168.1.1%eth0", nil, "", true},
+	{"::ffff:192.168.1.1%eth0", nil, "", true},
+	{"fe80::1%", nil, "", true},
+	{"%eth0", nil, "", true},
+	{"not.an.ip%eth0", nil, "", true},
+	{"", nil, "", true},
+}
+
+func TestParseIPWithZone(t *testing.T) {
+	for i, tt := range parseIPWithZoneTests {
+		ip, zone, err := ParseIPWithZone(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("[%d] %s: want error %t got '%v'", i, tt.s, tt.err, err)
+			continue
+		}
+		if !ip.Equal(tt.ip) || zone != tt.zone {
+			t.Errorf("[%d] %s: want %s, %q got %s, %q", i, tt.s, tt.ip, tt.zone, ip, zone)
+		}
+	}
+}
+
+var addrLimitTests = []struct {
+	version int
+	min     net.IP
+	max     net.IP
+}{
+	{IP4Version, net.IP{0, 0, 0, 0}, net.IP{255, 255, 255, 255}},
+	{IP6Version, net.ParseIP("::"), net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+	{0, nil, nil},
+	{5, nil, nil},
+	{16, nil, nil},
+}
+
+func TestMinAddrMaxAddr(t *testing.T) {
+	for i, tt := range addrLimitTests {
+		if ip := MinAddr(tt.version); !bytes.Equal(ip, tt.min) {
+			t.Errorf("[%d] MinAddr(%d): want %s got %s", i, tt.version, tt.min, ip)
+		}
+		if ip := MaxAddr(tt.version); !bytes.Equal(ip, tt.max) {
+			t.Errorf("[%d] MaxAddr(%d): want %s got %s", i, tt.version, tt.max, ip)
+		}
+	}
+}
+
+func TestARPANibbles(t *testing.T) {
+	ip := net.ParseIP("2001:db8::567:89ab")
+	nibbles := ARPANibbles(ip)
+	if len(nibbles) != 32 {
+		t.Fatalf("want 32 nibbles got %d", len(nibbles))
+	}
+
+	// IP6ToARPA is the string form of the same sequence
+	var sb strings.Builder
+	for _, nib := range nibbles {
+		fmt.Fprintf(&sb, "%x.", nib)
+	}
+	sb.WriteString("ip6.arpa")
+	if s := IP6ToARPA(ip); sb.String() != s {
+		t.Errorf("want %s got %s", s, sb.String())
+	}
+
+	if ARPANibbles(nil) != nil {
+		t.Errorf("want nil for nil input")
+	}
+}
+
+var nibblesToIP6Tests = []struct {
+	nibbles []byte
+	ip      net.IP
+	masklen int
+	err     error
+}{
+	{
+		[]byte{8, 0xb, 0xd, 0, 1, 0, 0, 2},
+		net.ParseIP("2001:db8::"), 32, nil,
+	},
+	{
+		[]byte{2},
+		net.ParseIP("2000::"), 4, nil,
+	},
+	{
+		[]byte{},
+		net.ParseIP("::"), 0, nil,
+	},
+	{
+		ARPANibbles(net.ParseIP("2001:db8::567:89ab")),
+		net.ParseIP("2001:db8::567:89ab"), 128, nil,
+	},
+	{
+		[]byte{8, 0x10, 0xd, 0, 1, 0, 0, 2},
+		nil, 0, ErrBadNibble,
+	},
+	{
+		make([]byte, 33),
+		nil, 0, ErrBadMaskLength,
+	},
+}
+
+func TestNibblesToIP6(t *testing.T) {
+	for i, tt := range nibblesToIP6Tests {
+		ip, masklen, err := NibblesToIP6(tt.nibbles)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if !ip.Equal(tt.ip) || masklen != tt.masklen {
+			t.Errorf("[%d] want %s/%d got %s/%d", i, tt.ip, tt.masklen, ip, masklen)
+		}
+	}
+}
+
+func TestIPSortKey(t *testing.T) {
+	ordered := []net.IP{
+		{0, 0, 0, 0},
+		net.ParseIP("10.0.0.1"),
+		net.ParseIP("10.0.0.2"),
+		net.ParseIP("192.168.1.1"),
+		net.ParseIP("255.255.255.255"),
+		net.ParseIP("::"),
+		net.ParseIP("::1"),
+		net.ParseIP("2001:db8::"),
+		net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"),
+	}
+	for i := range ordered {
+		ki := IPSortKey(ordered[i])
+		if len(ki) != 17 {
+			t.Fatalf("[%d] %s: want a 17 byte key got %d bytes", i, ordered[i], len(ki))
+		}
+		for j := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if v := bytes.Compare(ki, IPSortKey(ordered[j])); v != want {
+				t.Errorf("%s vs %s: want %d got %d", ordered[i], ordered[j], want, v)
+			}
+		}
+	}
+
+	if !bytes.Equal(IPSortKey(net.IP{10, 0, 0, 1}), IPSortKey(net.ParseIP("::ffff:10.0.0.1"))) {
+		t.Errorf("4-byte and IPv4-mapped forms should produce the same key")
+	}
+	want := []byte{4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 192, 168, 1, 1}
+	if k := IPSortKey(net.ParseIP("192.168.1.1")); !bytes.Equal(k, want) {
+		t.Errorf("want %v got %v", want, k)
+	}
+	if IPSortKey(nil) != nil || IPSortKey(net.IP{1, 2, 3}) != nil {
+		t.Errorf("want nil for invalid input")
+	}
+}
+
+var areAdjacentTests = []struct {
+	a   net.IP
+	b   net.IP
+	adj bool
+}{
+	{net.ParseIP("192.168.1.1"), net.ParseIP("192.168.1.2"), true},
+	{net.ParseIP("192.168.1.255"), net.ParseIP("192.168.2.0"), true},
+	{net.IP{10, 0, 0, 1}, net.ParseIP("::ffff:10.0.0.2"), true},
+	{net.ParseIP("192.168.1.2"), net.ParseIP("192.168.1.1"), false},
+	{net.ParseIP("192.168.1.1"), net.ParseIP("192.168.1.1"), false},
+	{net.ParseIP("192.168.1.1"), net.ParseIP("192.168.1.3"), false},
+	{net.ParseIP("255.255.255.255"), net.ParseIP("0.0.0.0"), false},
+	{net.ParseIP("2001:db8::ffff"), net.ParseIP("2001:db8::1:0"), true},
+	{net.ParseIP("2001:db8:0:0:ffff:ffff:ffff:ffff"), net.ParseIP("2001:db8:0:1::"), true},
+	{net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), net.ParseIP("::"), false},
+	{net.ParseIP("::fffe:ffff:ffff"), net.ParseIP("::ffff:0:0"), false}, // mixed effective versions
+	{net.ParseIP("::ffff:ffff"), net.ParseIP("::1:0:0"), true},
+	{nil, net.ParseIP("0.0.0.1"), false},
+}
+
+func TestAreAdjacent(t *testing.T) {
+	for i, tt := range areAdjacentTests {
+		if v := AreAdjacent(tt.a, tt.b); v != tt.adj {
+			t.Errorf("[%d] AreAdjacent(%s, %s): want %t got %t", i, tt.a, tt.b, tt.adj, v)
+		}
+	}
+}
+
+var validateIPStringTests = []struct {
+	s       string
+	ip      net.IP
+	version int
+	reason  string
+	token   string
+}{
+	{"192.168.1.1", net.IP{192, 168, 1, 1}, 4, "", ""},
+	{"0.0.0.0", net.IP{0, 0, 0, 0}, 4, "", ""},
+	{"2001:db8::1", net.ParseIP("2001:db8::1"), 6, "", ""},
+	{"::", net.ParseIP("::"), 6, "", ""},
+	{"1:2:3:4:5:6:7:8", net.ParseIP("1:2:3:4:5:6:7:8"), 6, "", ""},
+	{"::ffff:192.0.2.1", net.ParseIP("::ffff:192.0.2.1"), 6, "", ""},
+	{"", nil, 0, "empty IP address", ""},
+	{"fe80::1%eth0", nil, 0, "zone identifier not permitted in IP address", "eth0"},
+	{"192.168.1", nil, 0, "wrong number of octets in IPv4 address", "192.168.1"},
+	{"192.168.1.1.1", nil, 0, "wrong number of octets in IPv4 address", "192.168.1.1.1"},
+	{"192.168..1", nil, 0, "empty octet in IPv4 address", "192.168..1"},
+	{"192.168.1.x", nil, 0, "bad character in IPv4 address", "x"},
+	{"192.168.1.256", nil, 0, "IPv4 octet out of range", "256"},
+	{"192.168.01.1", nil, 0, "IPv4 octet has a leading zero", "01"},
+	{"2001:db8::g", nil, 0, "bad character in IPv6 address", "g"},
+	{"2001::db8::1", nil, 0, "multiple \"::\" in IPv6 address", "::"},
+	{"2001:db8:12345::1", nil, 0, "IPv6 group has more than 4 hex digits", "12345"},
+	{"1:2:3:4:5:6:7", nil, 0, "wrong number of groups in IPv6 address", "1:2:3:4:5:6:7"},
+	{"1:2:3:4:5:6:7:8:9", nil, 0, "wrong number of groups in IPv6 address", "1:2:3:4:5:6:7:8:9"},
+	{"1:2:3:4:5:6:7:8::", nil, 0, "wrong number of groups in IPv6 address", "1:2:3:4:5:6:7:8::"},
+	{"::ffff:192.0.2.300", nil, 0, "IPv4 octet out of range", "300"},
+	{"1:2:3:4:5:6:7:192.0.2.1", nil, 0, "wrong number of groups in IPv6 address", "1:2:3:4:5:6:7:192.0.2.1"},
+	{":1:2:3:4:5:6:7:8", nil, 0, "invalid IPv6 address", ":1:2:3:4:5:6:7:8"},
+}
+
+func TestValidateIPString(t *testing.T) {
+	for i, tt := range validateIPStringTests {
+		ip, version, err := ValidateIPString(tt.s)
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("[%d] %q: unexpected error %v", i, tt.s, err)
+				continue
+			}
+			if !bytes.Equal(ip, tt.ip) || version != tt.version {
+				t.Errorf("[%d] %q: want %v (v%d) got %v (v%d)", i, tt.s, []byte(tt.ip), tt.version, []byte(ip), version)
+			}
+			continue
+		}
+
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("[%d] %q: want a *ParseError got %v", i, tt.s, err)
+			continue
+		}
+		if perr.Error() != tt.reason || perr.Token != tt.token || perr.Input != tt.s {
+			t.Errorf("[%d] %q: want %q (token %q) got %q (token %q)", i, tt.s, tt.reason, tt.token, perr.Error(), perr.Token)
+		}
+		if ip != nil || version != 0 {
+			t.Errorf("[%d] %q: want nil address and version 0 on error", i, tt.s)
+		}
+	}
+}
+
+var validateIPStringWithZoneTests = []struct {
+	s      string
+	ip     net.IP
+	zone   string
+	reason string
+	token  string
+}{
+	{"fe80::1%eth0", net.ParseIP("fe80::1"), "eth0", "", ""},
+	{"fe80::1%25", net.ParseIP("fe80::1"), "25", "", ""},
+	{"2001:db8::1", net.ParseIP("2001:db8::1"), "", "", ""},
+	{"192.168.1.1", net.IP{192, 168, 1, 1}, "", "", ""},
+	{"fe80::1%", nil, "", "empty zone identifier in IP address", "%"},
+	{"192.168.1.1%eth0", nil, "", "zone identifier not permitted in IPv4 address", "eth0"},
+	{"fe80::g%eth0", nil, "", "bad character in IPv6 address", "g"},
+	{"%eth0", nil, "", "empty IP address", ""},
+}
+
+func TestValidateIPStringWithZone(t *testing.T) {
+	for i, tt := range validateIPStringWithZoneTests {
+		ip, _, zone, err := ValidateIPStringWithZone(tt.s)
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("[%d] %q: unexpected error %v", i, tt.s, err)
+				continue
+			}
+			if !bytes.Equal(ip, tt.ip) || zone != tt.zone {
+				t.Errorf("[%d] %q: want %s%%%s got %s%%%s", i, tt.s, tt.ip, tt.zone, ip, zone)
+			}
+			continue
+		}
+
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("[%d] %q: want a *ParseError got %v", i, tt.s, err)
+			continue
+		}
+		if perr.Error() != tt.reason || perr.Token != tt.token || perr.Input != tt.s {
+			t.Errorf("[%d] %q: want %q (token %q) got %q (token %q)", i, tt.s, tt.reason, tt.token, perr.Error(), perr.Token)
+		}
+	}
+}
+
+var maskOnesTests = []struct {
+	mask       net.IPMask
+	ones       int
+	contiguous bool
+}{
+	{net.CIDRMask(24, 32), 24, true},
+	{net.CIDRMask(0, 32), 0, true},
+	{net.CIDRMask(32, 32), 32, true},
+	{net.CIDRMask(64, 128), 64, true},
+	{net.IPv4Mask(0, 0, 0, 255), 8, false},    // wildcard mask
+	{net.IPv4Mask(255, 0, 255, 0), 16, false}, // discontiguous
+	{net.IPv4Mask(255, 255, 255, 254), 31, true},
+	{net.IPv4Mask(255, 255, 255, 253), 31, false},
+	{net.IPMask{}, 0, false},
+}
+
+func TestMaskOnesMaskIsContiguous(t *testing.T) {
+	for i, tt := range maskOnesTests {
+		if v := MaskOnes(tt.mask); v != tt.ones {
+			t.Errorf("[%d] MaskOnes(%s): want %d got %d", i, tt.mask, tt.ones, v)
+		}
+		if v := MaskIsContiguous(tt.mask); v != tt.contiguous {
+			t.Errorf("[%d] MaskIsContiguous(%s): want %t got %t", i, tt.mask, tt.contiguous, v)
+		}
+	}
+}
+
+var matchUnderMaskTests = []struct {
+	ip      string
+	pattern string
+	mask    net.IPMask
+	match   bool
+}{
+	{"192.168.1.10", "192.168.1.0", net.CIDRMask(24, 32), true},
+	{"192.168.2.10", "192.168.1.0", net.CIDRMask(24, 32), false},
+	{"10.1.7.1", "10.0.7.0", net.IPv4Mask(255, 0, 255, 0), true}, // non-contiguous
+	{"10.1.8.1", "10.0.7.0", net.IPv4Mask(255, 0, 255, 0), false},
+	{"10.1.7.1", "10.0.7.0", net.IPv4Mask(0, 0, 0, 0), true},
+	{"::ffff:192.168.1.10", "192.168.1.0", net.CIDRMask(24, 32), true},
+	{"192.168.1.10", "::ffff:192.168.1.0", net.CIDRMask(120, 128), true},
+	{"2001:db8:1::1", "2001:db8::", net.CIDRMask(32, 128), true},
+	{"2001:db8:1::1", "2001:db8::", net.CIDRMask(48, 128), false},
+	{"2001:db8::1:0:0:1", "::1:0:0:1", net.IPMask(HexStringToIP("0000000000000000ffffffffffffffff")), true},
+	{"2001:db8::1", "192.168.1.0", net.CIDRMask(24, 32), false},
+	{"192.168.1.10", "192.168.1.0", net.IPMask{255, 255}, false},
+	{"", "192.168.1.0", net.CIDRMask(24, 32), false},
+}
+
+func TestMatchUnderMask(t *testing.T) {
+	for i, tt := range matchUnderMaskTests {
+		v := MatchUnderMask(net.ParseIP(tt.ip), net.ParseIP(tt.pattern), tt.mask)
+		if v != tt.match {
+			t.Errorf("[%d] %s, %s under %s: want %t got %t", i, tt.ip, tt.pattern, tt.mask, tt.match, v)
+		}
+	}
+}
+
+var hostBitsTests = []struct {
+	ipaddr  net.IP
+	masklen int
+	set     bool
+	clear   bool
+}{
+	{net.ParseIP("192.168.1.0"), 24, false, true},
+	{net.ParseIP("192.168.1.255"), 24, true, false},
+	{net.ParseIP("192.168.1.127"), 25, true, false},
+	{net.ParseIP("192.168.1.127"), 24, false, false},
+	{net.ParseIP("192.168.1.128"), 25, false, true},
+	{net.ParseIP("192.168.1.1"), 32, true, true}, // no host bits at all
+	{net.ParseIP("255.255.255.255"), 0, true, false},
+	{net.ParseIP("0.0.0.0"), 0, false, true},
+	{net.IP{10, 255, 255, 255}, 8, true, false},
+	{net.ParseIP("::ffff:10.0.0.0"), 8, false, true},
+	{net.ParseIP("10.0.0.0"), 33, false, false},
+	{net.ParseIP("10.0.0.0"), -1, false, false},
+	{net.ParseIP("2001:db8::"), 32, false, true},
+	{net.ParseIP("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"), 32, true, false},
+	{net.ParseIP("2001:db8::ff"), 120, true, false},
+	{net.ParseIP("2001:db8::fe"), 120, false, false},
+	{net.ParseIP("2001:db8::"), 129, false, false},
+	{nil, 0, false, false},
+}
+
+func TestHostBitsAllSetAllClear(t *testing.T) {
+	for i, tt := range hostBitsTests {
+		if v := HostBitsAllSet(tt.ipaddr, tt.masklen); v != tt.set {
+			t.Errorf("[%d] HostBitsAllSet(%s, %d): want %t got %t", i, tt.ipaddr, tt.masklen, tt.set, v)
+		}
+		if v := HostBitsAllClear(tt.ipaddr, tt.masklen); v != tt.clear {
+			t.Errorf("[%d] HostBitsAllClear(%s, %d): want %t got %t", i, tt.ipaddr, tt.masklen, tt.clear, v)
+		}
+	}
+}
+
+var distinguishingBitTests = []struct {
+	a, b string
+	pos  int
+	err  error
+}{
+	{"10.0.0.0", "10.0.0.1", 31, nil},
+	{"10.0.0.1", "10.0.0.0", 31, nil},
+	{"10.0.0.0", "138.0.0.0", 0, nil},
+	{"192.168.0.0", "192.168.128.0", 16, nil},
+	{"192.168.1.0", "192.168.2.0", 22, nil},
+	{"::ffff:10.0.0.0", "10.0.0.1", 31, nil},
+	{"2001:db8::", "2001:db8::1", 127, nil},
+	{"2001:db8::", "2001:db9::", 31, nil},
+	{"::", "8000::", 0, nil},
+	{"10.0.0.1", "10.0.0.1", 0, ErrNoValidRange},
+	{"2001:db8::1", "2001:db8::1", 0, ErrNoValidRange},
+	{"10.0.0.1", "2001:db8::1", 0, ErrNoValidRange},
+	{"10.0.0.1", "", 0, ErrNoValidRange},
+}
+
+func TestDistinguishingBit(t *testing.T) {
+	for i, tt := range distinguishingBitTests {
+		a, b := net.ParseIP(tt.a), net.ParseIP(tt.b)
+		pos, err := DistinguishingBit(a, b)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if pos != tt.pos {
+			t.Errorf("[%d] %s, %s: want %d got %d", i, tt.a, tt.b, tt.pos, pos)
+		}
+		if GetBit(a, pos) == GetBit(b, pos) {
+			t.Errorf("[%d] %s, %s: bit %d does not differ", i, tt.a, tt.b, pos)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package iplib
+
+import (
+	"math/big"
+	"net"
+	"sort"
+)
+
+// IPSet is a set of IP addresses built up from Net objects. Internally it is
+// held in the same normalized form as a Matcher, as sorted, non-overlapping
+// and non-adjacent bounds for each IP version, so two IPSets covering the
+// same addresses are always identical no matter how they were constructed.
+// The set operations below all return a new IPSet and leave their inputs
+// untouched. As with Matcher the hostmask of a Net6 is not considered, and a
+// nil *IPSet behaves as an empty set
+type IPSet struct {
+	v4 []matcherBounds
+	v6 []matcherBounds
+}
+
+// NewIPSet returns an IPSet containing every address in nets. Nil or empty
+// networks are ignored
+func NewIPSet(nets []Net) *IPSet {
+	m := Prepare(nets)
+	return &IPSet{v4: m.v4, v6: m.v6}
+}
+
+// Contains returns true if ip is a member of the set
+func (s *IPSet) Contains(ip net.IP) bool {
+	if s == nil {
+		return false
+	}
+	return Matcher{v4: s.v4, v6: s.v6}.Contains(ip)
+}
+
+// CoversNet returns true if every address in n is a member of the set. Since
+// the set is held as sorted, merged bounds this is a single binary search
+// rather than a test of each address: n is covered only if one of those
+// bounds spans it entirely. As with Contains() the hostmask of a Net6 is not
+// considered. A nil or empty n returns false
+func (s *IPSet) CoversNet(n Net) bool {
+	if n == nil || n.IP() == nil {
+		return false
+	}
+
+	b := s.bounds()
+	bounds := b.v6
+	if n.Version() == IP4Version {
+		bounds = b.v4
+	}
+
+	nb := netBounds(n)
+	i := sort.Search(len(bounds), func(i int) bool {
+		return bounds[i].last.Cmp(nb.first) >= 0
+	})
+	return i < len(bounds) && bounds[i].first.Cmp(nb.first) <= 0 && bounds[i].last.Cmp(nb.last) >= 0
+}
+
+// Difference returns a new IPSet containing the addresses in s which are not
+// in other
+func (s *IPSet) Difference(other *IPSet) *IPSet {
+	a, b := s.bounds(), other.bounds()
+	return &IPSet{
+		v4: subtractBounds(a.v4, b.v4),
+		v6: subtractBounds(a.v6, b.v6),
+	}
+}
+
+// Intersection returns a new IPSet containing the addresses in both s and
+// other
+func (s *IPSet) Intersection(other *IPSet) *IPSet {
+	a, b := s.bounds(), other.bounds()
+	return &IPSet{
+		v4: intersectBounds(a.v4, b.v4),
+		v6: intersectBounds(a.v6, b.v6),
+	}
+}
+
+// Nets returns the smallest list of networks which exactly covers the set,
+// v4 networks first and each version in ascending order
+func (s *IPSet) Nets() []Net {
+	b := s.bounds()
+	nets := boundsToNets(b.v4, IP4Version)
+	return append(nets, boundsToNets(b.v6, IP6Version)...)
+}
+
+// Ranges returns the v4 portion of the set as the smallest list of Range4,
+// in ascending order. Since the set is held in normalized form, adjacent or
+// overlapping networks are always collapsed into a single range. Range4 has
+// no v6 counterpart so any v6 addresses in the set are not included
+func (s *IPSet) Ranges() []Range4 {
+	ranges := []Range4{}
+	for _, b := range s.bounds().v4 {
+		ranges = append(ranges, Range4{
+			first: Uint32ToIP4(uint32(b.first.Lo)),
+			last:  Uint32ToIP4(uint32(b.last.Lo)),
+		})
+	}
+	return ranges
+}
+
+// SymmetricDifference returns a new IPSet containing the addresses that are
+// in exactly one of s and other, that is the parts of the two sets that
+// disagree with one another
+func (s *IPSet) SymmetricDifference(other *IPSet) *IPSet {
+	return s.Union(other).Difference(s.Intersection(other))
+}
+
+// Union returns a new IPSet containing the addresses in either s or other
+func (s *IPSet) Union(other *IPSet) *IPSet {
+	a, b := s.bounds(), other.bounds()
+	return &IPSet{
+		v4: mergeMatcherBounds(concatBounds(a.v4, b.v4)),
+		v6: mergeMatcherBounds(concatBounds(a.v6, b.v6)),
+	}
+}
+
+// bounds returns s, or an empty IPSet if s is nil
+func (s *IPSet) bounds() IPSet {
+	if s == nil {
+		return IPSet{}
+	}
+	return *s
+}
+
+// count returns the number of addresses in the set
+func (s *IPSet) count() *big.Int {
+	total := new(big.Int)
+	b := s.bounds()
+	for _, bounds := range [][]matcherBounds{b.v4, b.v6} {
+		for _, x := range bounds {
+			size := x.last.Sub(x.first).Big()
+			total.Add(total, size.Add(size, big.NewInt(1)))
+		}
+	}
+	return total
+}
+
+// boundsToNets returns the smallest list of networks of the given version
+// which exactly covers the normalized bounds
+func boundsToNets(bounds []matcherBounds, version int) []Net {
+	width := 128
+	if version == IP4Version {
+		width = 32
+	}
+
+	nets := []Net{}
+	for _, b := range bounds {
+		cur := b.first
+		for {
+			// start with the largest block aligned on cur, then shrink it
+			// until it no longer runs past the end of the bounds
+			hostbits := cur.TrailingZeros()
+			if hostbits > width {
+				hostbits = width
+			}
+			for hostbits > 0 && cur.Or(hostSpan(hostbits)).Cmp(b.last) > 0 {
+				hostbits--
+			}
+
+			if version == IP4Version {
+				nets = append(nets, NewNet4(Uint32ToIP4(uint32(cur.Lo)), width-hostbits))
+			} else {
+				nets = append(nets, NewNet6(Uint128ToIP6(cur), width-hostbits, 0))
+			}
+
+			end := cur.Or(hostSpan(hostbits))
+			if end.Cmp(b.last) >= 0 {
+				break
+			}
+			cur = end.Add64(1)
+		}
+	}
+	return nets
+}
+
+// concatBounds returns a new slice holding a followed by b, so that merging
+// it can't disturb the backing array of either
+func concatBounds(a, b []matcherBounds) []matcherBounds {
+	c := make([]matcherBounds, 0, len(a)+len(b))
+	c = append(c, a...)
+	return append(c, b...)
+}
+
+// intersectBounds returns the overlap of two normalized bounds lists
+func intersectBounds(a, b []matcherBounds) []matcherBounds {
+	var out []matcherBounds
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		first, last := a[i].first, a[i].last
+		if b[j].first.Cmp(first) > 0 {
+			first = b[j].first
+		}
+		if b[j].last.Cmp(last) < 0 {
+			last = b[j].last
+		}
+		if first.Cmp(last) <= 0 {
+			out = append(out, matcherBounds{first, last})
+		}
+		if a[i].last.Cmp(b[j].last) < 0 {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out
+}
+
+// subtractBounds returns the parts of normalized bounds list a which are not
+// covered by normalized bounds list b
+func subtractBounds(a, b []matcherBounds) []matcherBounds {
+	var out []matcherBounds
+	j := 0
+	for _, x := range a {
+		cur, done := x.first, false
+
+		// skip anything in b that ends before x begins
+		for j < len(b) && b[j].last.Cmp(cur) < 0 {
+			j++
+		}
+		for k := j; k < len(b) && b[k].first.Cmp(x.last) <= 0; k++ {
+			if b[k].first.Cmp(cur) > 0 {
+				out = append(out, matcherBounds{cur, b[k].first.Sub64(1)})
+			}
+			if b[k].last.Cmp(x.last) >= 0 {
+				done = true
+				break
+			}
+			cur = b[k].last.Add64(1)
+		}
+		if !done {
+			out = append(out, matcherBounds{cur, x.last})
+		}
+	}
+	return out
+}
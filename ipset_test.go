@@ -0,0 +1,255 @@
+package iplib
+
+import (
+	"net"
+	"testing"
+)
+
+func newIPSetFromStrings(cidrs []string) *IPSet {
+	nets := []Net{}
+	for _, s := range cidrs {
+		_, n, _ := ParseCIDR(s)
+		nets = append(nets, n)
+	}
+	return NewIPSet(nets)
+}
+
+func ipSetStrings(s *IPSet) []string {
+	strs := []string{}
+	for _, n := range s.Nets() {
+		strs = append(strs, n.String())
+	}
+	return strs
+}
+
+func compareIPSetToStrings(s *IPSet, want []string) bool {
+	nets := s.Nets()
+	if len(nets) != len(want) {
+		return false
+	}
+	for i, n := range nets {
+		if n.String() != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var newIPSetTests = []struct {
+	in   []string
+	nets []string
+}{
+	{[]string{}, []string{}},
+	{
+		[]string{"192.168.1.0/24", "192.168.0.0/24", "192.168.0.128/25"},
+		[]string{"192.168.0.0/23"},
+	},
+	{
+		[]string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"},
+		[]string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"},
+	},
+	{
+		[]string{"2001:db8::/64", "2001:db8:0:1::/64", "10.0.0.0/8"},
+		[]string{"10.0.0.0/8", "2001:db8::/63"},
+	},
+	{
+		[]string{"0.0.0.0/0", "::/0"},
+		[]string{"0.0.0.0/0", "::/0"},
+	},
+}
+
+func TestNewIPSet(t *testing.T) {
+	for i, tt := range newIPSetTests {
+		s := newIPSetFromStrings(tt.in)
+		if !compareIPSetToStrings(s, tt.nets) {
+			t.Errorf("[%d] want %v got %v", i, tt.nets, s.Nets())
+		}
+	}
+}
+
+func TestIPSet_Contains(t *testing.T) {
+	s := newIPSetFromStrings([]string{"192.168.0.0/24", "2001:db8::/64"})
+	for i, tt := range []struct {
+		ip net.IP
+		in bool
+	}{
+		{net.ParseIP("192.168.0.55"), true},
+		{net.ParseIP("192.168.1.55"), false},
+		{net.ParseIP("2001:db8::1"), true},
+		{net.ParseIP("2001:db8:1::1"), false},
+	} {
+		if v := s.Contains(tt.ip); v != tt.in {
+			t.Errorf("[%d] %s: want %t got %t", i, tt.ip, tt.in, v)
+		}
+	}
+	var nilset *IPSet
+	if nilset.Contains(net.ParseIP("192.168.0.55")) {
+		t.Errorf("nil IPSet should contain nothing")
+	}
+}
+
+var ipSetAlgebraTests = []struct {
+	a         []string
+	b         []string
+	union     []string
+	intersect []string
+	diff      []string
+	symdiff   []string
+}{
+	{
+		[]string{"10.0.0.0/24"},
+		[]string{"10.0.0.128/25", "10.0.1.0/24"},
+		[]string{"10.0.0.0/23"},
+		[]string{"10.0.0.128/25"},
+		[]string{"10.0.0.0/25"},
+		[]string{"10.0.0.0/25", "10.0.1.0/24"},
+	},
+	{
+		[]string{"10.0.0.0/24"},
+		[]string{"10.0.0.0/24"},
+		[]string{"10.0.0.0/24"},
+		[]string{"10.0.0.0/24"},
+		[]string{},
+		[]string{},
+	},
+	{
+		[]string{"10.0.0.0/8"},
+		[]string{"10.1.0.0/16", "10.3.0.0/16"},
+		[]string{"10.0.0.0/8"},
+		[]string{"10.1.0.0/16", "10.3.0.0/16"},
+		[]string{"10.0.0.0/16", "10.2.0.0/16", "10.4.0.0/14", "10.8.0.0/13", "10.16.0.0/12", "10.32.0.0/11", "10.64.0.0/10", "10.128.0.0/9"},
+		[]string{"10.0.0.0/16", "10.2.0.0/16", "10.4.0.0/14", "10.8.0.0/13", "10.16.0.0/12", "10.32.0.0/11", "10.64.0.0/10", "10.128.0.0/9"},
+	},
+	{
+		[]string{"192.168.0.0/24", "2001:db8::/32"},
+		[]string{"2001:db8::/33"},
+		[]string{"192.168.0.0/24", "2001:db8::/32"},
+		[]string{"2001:db8::/33"},
+		[]string{"192.168.0.0/24", "2001:db8:8000::/33"},
+		[]string{"192.168.0.0/24", "2001:db8:8000::/33"},
+	},
+	{
+		[]string{"::/0"},
+		[]string{"::/1"},
+		[]string{"::/0"},
+		[]string{"::/1"},
+		[]string{"8000::/1"},
+		[]string{"8000::/1"},
+	},
+	{
+		[]string{},
+		[]string{"10.0.0.0/30"},
+		[]string{"10.0.0.0/30"},
+		[]string{},
+		[]string{},
+		[]string{"10.0.0.0/30"},
+	},
+}
+
+func TestIPSet_Algebra(t *testing.T) {
+	for i, tt := range ipSetAlgebraTests {
+		a := newIPSetFromStrings(tt.a)
+		b := newIPSetFromStrings(tt.b)
+		if s := a.Union(b); !compareIPSetToStrings(s, tt.union) {
+			t.Errorf("[%d] Union: want %v got %v", i, tt.union, s.Nets())
+		}
+		if s := a.Intersection(b); !compareIPSetToStrings(s, tt.intersect) {
+			t.Errorf("[%d] Intersection: want %v got %v", i, tt.intersect, s.Nets())
+		}
+		if s := a.Difference(b); !compareIPSetToStrings(s, tt.diff) {
+			t.Errorf("[%d] Difference: want %v got %v", i, tt.diff, s.Nets())
+		}
+		if s := a.SymmetricDifference(b); !compareIPSetToStrings(s, tt.symdiff) {
+			t.Errorf("[%d] SymmetricDifference: want %v got %v", i, tt.symdiff, s.Nets())
+		}
+		if s := b.SymmetricDifference(a); !compareIPSetToStrings(s, tt.symdiff) {
+			t.Errorf("[%d] SymmetricDifference (reversed): want %v got %v", i, tt.symdiff, s.Nets())
+		}
+
+		// the inputs must not have been modified
+		if !compareIPSetToStrings(a, ipSetStrings(newIPSetFromStrings(tt.a))) {
+			t.Errorf("[%d] input was modified: %v", i, a.Nets())
+		}
+	}
+}
+
+var ipSetRangesTests = []struct {
+	in     []string
+	ranges []string
+}{
+	{[]string{}, []string{}},
+	{
+		[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.3.0/24"},
+		[]string{"10.0.0.0-10.0.1.255", "10.0.3.0-10.0.3.255"},
+	},
+	{
+		[]string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"},
+		[]string{"10.0.0.1-10.0.0.6"},
+	},
+	{
+		[]string{"2001:db8::/64", "192.168.0.0/16"},
+		[]string{"192.168.0.0-192.168.255.255"},
+	},
+}
+
+func TestIPSet_Ranges(t *testing.T) {
+	for i, tt := range ipSetRangesTests {
+		got := []string{}
+		for _, r := range newIPSetFromStrings(tt.in).Ranges() {
+			got = append(got, r.String())
+		}
+		if len(got) != len(tt.ranges) {
+			t.Errorf("[%d] want %v got %v", i, tt.ranges, got)
+			continue
+		}
+		for j := range got {
+			if got[j] != tt.ranges[j] {
+				t.Errorf("[%d] want %v got %v", i, tt.ranges, got)
+				break
+			}
+		}
+	}
+
+	var s *IPSet
+	if r := s.Ranges(); len(r) != 0 {
+		t.Errorf("nil IPSet: want no ranges got %v", r)
+	}
+}
+
+var ipSetCoversNetTests = []struct {
+	xnet   string
+	covers bool
+}{
+	{"10.0.0.0/16", true},
+	{"10.0.1.0/24", true},
+	{"10.0.0.0/15", true}, // spans both merged /16s
+	{"10.0.0.0/14", false},
+	{"10.2.0.0/24", false},
+	{"10.3.0.0/16", false},
+	{"192.168.1.0/24", true},
+	{"192.168.1.0/23", false}, // partial coverage
+	{"192.168.0.255/32", false},
+	{"192.168.1.255/32", true},
+	{"2001:db8::/48", true},
+	{"2001:db8::/32", false},
+}
+
+func TestIPSet_CoversNet(t *testing.T) {
+	s := newIPSetFromStrings([]string{
+		"10.0.0.0/16", "10.1.0.0/16", "192.168.1.0/24", "2001:db8::/47",
+	})
+	for i, tt := range ipSetCoversNetTests {
+		_, n, _ := ParseCIDR(tt.xnet)
+		if v := s.CoversNet(n); v != tt.covers {
+			t.Errorf("[%d] %s: want %t got %t", i, tt.xnet, tt.covers, v)
+		}
+	}
+
+	if s.CoversNet(nil) {
+		t.Errorf("nil network should not be covered")
+	}
+	var empty *IPSet
+	if empty.CoversNet(Net4FromStr("10.0.0.0/24")) {
+		t.Errorf("nil IPSet should cover nothing")
+	}
+}
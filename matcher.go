@@ -0,0 +1,210 @@
+package iplib
+
+import (
+	"net"
+	"sort"
+
+	"lukechampine.com/uint128"
+)
+
+// Matcher is a read-only allowlist compiled from a list of Net objects. It
+// exists for cases where the same set of networks will be checked against a
+// very large number of addresses: rather than calling Contains() on each Net
+// in turn the networks are flattened into sorted, non-overlapping bounds
+// which can be binary-searched without allocating. The original networks are
+// also kept, sorted and nested, so that WhichNet() can report which of them
+// matched. Use Prepare() to create one
+type Matcher struct {
+	v4     []matcherBounds
+	v6     []matcherBounds
+	v4nets []matcherEntry
+	v6nets []matcherEntry
+}
+
+type matcherBounds struct {
+	first uint128.Uint128
+	last  uint128.Uint128
+}
+
+// matcherEntry is a single prepared network, parent is the index of the
+// nearest entry which encloses it or -1 if there is none
+type matcherEntry struct {
+	matcherBounds
+	net    Net
+	parent int
+}
+
+// Prepare compiles the supplied networks into a Matcher. Overlapping and
+// adjacent networks are merged, and empty or nil networks are ignored. As
+// with Net.Contains() the hostmask of a Net6 is not considered, and v4
+// addresses will only ever match v4 networks
+func Prepare(nets []Net) Matcher {
+	m := Matcher{}
+	for _, n := range nets {
+		if n == nil || n.IP() == nil {
+			continue
+		}
+
+		e := matcherEntry{matcherBounds: netBounds(n), net: n}
+		if n.Version() == IP4Version {
+			m.v4 = append(m.v4, e.matcherBounds)
+			m.v4nets = append(m.v4nets, e)
+		} else {
+			m.v6 = append(m.v6, e.matcherBounds)
+			m.v6nets = append(m.v6nets, e)
+		}
+	}
+
+	m.v4 = mergeMatcherBounds(m.v4)
+	m.v6 = mergeMatcherBounds(m.v6)
+	m.v4nets = nestMatcherEntries(m.v4nets)
+	m.v6nets = nestMatcherEntries(m.v6nets)
+	return m
+}
+
+// Contains returns true if ip falls within any of the networks the Matcher
+// was prepared with
+func (m Matcher) Contains(ip net.IP) bool {
+	z, version := matcherKey(ip)
+	var bounds []matcherBounds
+
+	switch version {
+	case IP4Version:
+		bounds = m.v4
+	case IP6Version:
+		bounds = m.v6
+	default:
+		return false
+	}
+
+	// find the first block ending at or after z, then check that it starts
+	// at or before it
+	i := sort.Search(len(bounds), func(i int) bool {
+		return bounds[i].last.Cmp(z) >= 0
+	})
+	return i < len(bounds) && bounds[i].first.Cmp(z) <= 0
+}
+
+// WhichNet is the prepared form of the package-level WhichNet(), returning
+// the most-specific network the Matcher was prepared with which contains ip.
+// Rather than checking every network it binary-searches for the last one
+// starting at or before ip and then walks outward through the networks
+// enclosing it, so it is suited to very large lists. If no network contains
+// ip ok will be false, and where the same network was supplied more than once
+// the first is returned. As with Contains() v4 addresses will only ever match
+// v4 networks
+func (m Matcher) WhichNet(ip net.IP) (Net, bool) {
+	z, version := matcherKey(ip)
+	var entries []matcherEntry
+
+	switch version {
+	case IP4Version:
+		entries = m.v4nets
+	case IP6Version:
+		entries = m.v6nets
+	default:
+		return nil, false
+	}
+
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].first.Cmp(z) > 0
+	}) - 1
+	for i >= 0 {
+		if e := entries[i]; e.last.Cmp(z) >= 0 && e.net.Contains(ip) {
+			return e.net, true
+		}
+		i = entries[i].parent
+	}
+	return nil, false
+}
+
+// matcherKey returns ip as an integer along with its effective version, which
+// is 0 if ip is not a valid address
+func matcherKey(ip net.IP) (uint128.Uint128, int) {
+	switch EffectiveVersion(ip) {
+	case IP4Version:
+		return uint128.From64(uint64(IP4ToUint32(ip))), IP4Version
+	case IP6Version:
+		return IP6ToUint128(ip), IP6Version
+	}
+	return uint128.Zero, 0
+}
+
+// netBounds returns the first and last address of n as a matcherBounds
+func netBounds(n Net) matcherBounds {
+	ones, all := n.Mask().Size()
+
+	var first uint128.Uint128
+	if n.Version() == IP4Version {
+		first = uint128.From64(uint64(IP4ToUint32(n.IP())))
+	} else {
+		first = IP6ToUint128(n.IP())
+	}
+	return matcherBounds{first, first.Or(hostSpan(all - ones))}
+}
+
+// hostSpan returns the largest value that fits in hostbits bits, i.e. the
+// offset of the last address in a block with that many host bits
+func hostSpan(hostbits int) uint128.Uint128 {
+	if hostbits >= 128 {
+		return uint128.Max
+	}
+	return uint128.From64(1).Lsh(uint(hostbits)).Sub64(1)
+}
+
+// mergeMatcherBounds sorts bounds and collapses any that overlap or abut one
+// another
+func mergeMatcherBounds(bounds []matcherBounds) []matcherBounds {
+	if len(bounds) < 2 {
+		return bounds
+	}
+
+	sort.Slice(bounds, func(i, j int) bool {
+		return bounds[i].first.Cmp(bounds[j].first) < 0
+	})
+
+	merged := []matcherBounds{bounds[0]}
+	for _, b := range bounds[1:] {
+		cur := &merged[len(merged)-1]
+		if cur.last.Equals(uint128.Max) || b.first.Cmp(cur.last.Add64(1)) <= 0 {
+			if b.last.Cmp(cur.last) > 0 {
+				cur.last = b.last
+			}
+			continue
+		}
+		merged = append(merged, b)
+	}
+	return merged
+}
+
+// nestMatcherEntries sorts entries by first address, enclosing networks
+// before the networks they contain, drops repeated networks other than the
+// first supplied and records the parent of each. Since CIDR blocks either
+// nest or are disjoint every network containing an address is an ancestor of
+// the last entry starting at or before it
+func nestMatcherEntries(entries []matcherEntry) []matcherEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if c := entries[i].first.Cmp(entries[j].first); c != 0 {
+			return c < 0
+		}
+		return entries[i].last.Cmp(entries[j].last) > 0
+	})
+
+	nested := []matcherEntry{}
+	var open []int
+	for _, e := range entries {
+		if l := len(nested); l > 0 && nested[l-1].matcherBounds == e.matcherBounds {
+			continue
+		}
+		for len(open) > 0 && nested[open[len(open)-1]].last.Cmp(e.first) < 0 {
+			open = open[:len(open)-1]
+		}
+		e.parent = -1
+		if len(open) > 0 {
+			e.parent = open[len(open)-1]
+		}
+		nested = append(nested, e)
+		open = append(open, len(nested)-1)
+	}
+	return nested
+}
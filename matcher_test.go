@@ -0,0 +1,145 @@
+package iplib
+
+import (
+	"net"
+	"testing"
+)
+
+var matcherNets = []string{
+	"10.0.0.0/8",
+	"10.1.0.0/16",
+	"192.168.0.0/24",
+	"192.168.1.0/24",
+	"172.16.5.5/32",
+	"2001:db8::/64",
+	"2001:db8:0:1::/64",
+	"fe80::/10",
+}
+
+var matcherTests = []struct {
+	ip       net.IP
+	contains bool
+}{
+	{net.ParseIP("10.0.0.0"), true},
+	{net.ParseIP("10.255.255.255"), true},
+	{net.ParseIP("11.0.0.0"), false},
+	{net.ParseIP("9.255.255.255"), false},
+	{net.ParseIP("192.168.0.17"), true},
+	{net.ParseIP("192.168.1.255"), true},
+	{net.ParseIP("192.168.2.0"), false},
+	{net.IP{172, 16, 5, 5}, true},
+	{net.IP{172, 16, 5, 4}, false},
+	{net.IP{172, 16, 5, 6}, false},
+	{net.ParseIP("2001:db8::1"), true},
+	{net.ParseIP("2001:db8:0:1:ffff:ffff:ffff:ffff"), true},
+	{net.ParseIP("2001:db8:0:2::"), false},
+	{net.ParseIP("fe80::1"), true},
+	{net.ParseIP("::"), false},
+	{net.ParseIP("::ffff:a00:1"), true},
+	{nil, false},
+}
+
+func TestMatcher_Contains(t *testing.T) {
+	nets := []Net{}
+	for _, s := range matcherNets {
+		_, n, _ := ParseCIDR(s)
+		nets = append(nets, n)
+	}
+	m := Prepare(nets)
+
+	for i, tt := range matcherTests {
+		if v := m.Contains(tt.ip); v != tt.contains {
+			t.Errorf("[%d] Contains(%s) want %t got %t", i, tt.ip, tt.contains, v)
+		}
+
+		// the Matcher must always agree with checking each Net in turn
+		want := false
+		for _, n := range nets {
+			if n.Contains(tt.ip) {
+				want = true
+				break
+			}
+		}
+		if want != tt.contains {
+			t.Errorf("[%d] Net.Contains(%s) disagrees with test table", i, tt.ip)
+		}
+	}
+}
+
+var matcherWhichNetTests = []struct {
+	ip   string
+	want string
+	ok   bool
+}{
+	{"10.1.2.3", "10.1.2.0/24", true},
+	{"10.1.3.3", "10.1.0.0/16", true},
+	{"10.2.0.1", "10.0.0.0/8", true},
+	{"10.1.2.128", "10.1.2.128/25", true},
+	{"10.200.0.0", "10.0.0.0/8", true}, // after a nested block, falls back to its parent
+	{"11.0.0.1", "", false},
+	{"9.255.255.255", "", false},
+	{"192.168.1.1", "192.168.1.1/32", true},
+	{"192.168.1.2", "", false},
+	{"::ffff:10.1.2.3", "10.1.2.0/24", true},
+	{"2001:db8::1", "2001:db8::/64", true},
+	{"2001:db8:1::1", "2001:db8::/32", true},
+	{"2001:db9::1", "", false},
+	{"", "", false},
+}
+
+func TestMatcher_WhichNet(t *testing.T) {
+	nets := []Net{nil, Net4{}, NewNet4(net.ParseIP("::ffff:10.1.2.0"), 24)}
+	for _, s := range []string{
+		"10.0.0.0/8", "10.1.2.128/25", "10.1.0.0/16", "10.1.2.0/24",
+		"192.168.1.1/32", "2001:db8::/64", "2001:db8::/32",
+	} {
+		_, n, _ := ParseCIDR(s)
+		nets = append(nets, n)
+	}
+	m := Prepare(nets)
+
+	for i, tt := range matcherWhichNetTests {
+		ip := net.ParseIP(tt.ip)
+		n, ok := m.WhichNet(ip)
+		if ok != tt.ok {
+			t.Errorf("[%d] %s: want %t got %t", i, tt.ip, tt.ok, ok)
+			continue
+		}
+		if ok && n.String() != tt.want {
+			t.Errorf("[%d] %s: want %s got %s", i, tt.ip, tt.want, n)
+		}
+
+		// the Matcher must always agree with the unprepared WhichNet
+		if wn, wok := WhichNet(ip, nets); wok != ok || (ok && wn.String() != n.String()) {
+			t.Errorf("[%d] %s: WhichNet() gave %v, %t", i, tt.ip, wn, wok)
+		}
+	}
+
+	// the first of two identical networks is returned, as with WhichNet()
+	if n, _ := m.WhichNet(net.ParseIP("10.1.2.3")); !n.(Net4).Is4in6() {
+		t.Errorf("want the first 10.1.2.0/24 in the list")
+	}
+	if _, ok := Prepare(nil).WhichNet(net.ParseIP("10.0.0.1")); ok {
+		t.Errorf("empty Matcher should not match")
+	}
+}
+
+func TestMatcher_ContainsAllSpace(t *testing.T) {
+	m := Prepare([]Net{Net4FromStr("0.0.0.0/0"), Net6FromStr("::/0"), Net6FromStr("2001:db8::/32")})
+	for _, ip := range []net.IP{{0, 0, 0, 0}, {255, 255, 255, 255}, net.ParseIP("::"), net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")} {
+		if !m.Contains(ip) {
+			t.Errorf("want %s to be contained", ip)
+		}
+	}
+}
+
+func TestMatcher_Empty(t *testing.T) {
+	m := Prepare(nil)
+	if m.Contains(net.ParseIP("10.0.0.1")) {
+		t.Errorf("empty Matcher should not contain anything")
+	}
+	m = Prepare([]Net{Net4{}, nil})
+	if m.Contains(net.ParseIP("0.0.0.0")) {
+		t.Errorf("Matcher built from empty Nets should not contain anything")
+	}
+}
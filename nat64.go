@@ -0,0 +1,64 @@
+package iplib
+
+import (
+	"net"
+)
+
+// WellKnownPrefix returns the RFC6052 NAT64 Well-Known Prefix, 64:ff9b::/96
+func WellKnownPrefix() Net6 {
+	return NewNet6(net.ParseIP("64:ff9b::"), 96, 0)
+}
+
+// IsWellKnownPrefix returns true if n is exactly the RFC6052 NAT64
+// Well-Known Prefix 64:ff9b::/96. Subnets or supernets of the prefix do not
+// count
+func IsWellKnownPrefix(n Net6) bool {
+	if n.IP() == nil {
+		return false
+	}
+	ones, _ := n.Mask().Size()
+	return ones == 96 && n.IP().Equal(WellKnownPrefix().IP())
+}
+
+// Synthesize64 embeds v4 into the NAT64 prefix following the RFC6052 address
+// format, so 192.0.2.33 under 2001:db8:122:344::/64 becomes
+// 2001:db8:122:344:c0:2:2100:0. Bits 64-71 of the result (the "u" octet) are
+// always zero. RFC6052 permits prefix lengths of 32, 40, 48, 56, 64 or 96
+// bits, any other length returns ErrBadMaskLength. If v4 is not an IPv4
+// address ErrNoValidRange is returned
+func Synthesize64(prefix Net6, v4 net.IP) (net.IP, error) {
+	prefixlen, _ := prefix.Mask().Size()
+	switch prefixlen {
+	case 32, 40, 48, 56, 64, 96:
+	default:
+		return nil, ErrBadMaskLength
+	}
+	if EffectiveVersion(v4) != IP4Version {
+		return nil, ErrNoValidRange
+	}
+
+	xip := CopyIP(prefix.IP().To16())
+	xip[8] = 0
+	pos := prefixlen / 8
+	for _, b := range ForceIP4(v4) {
+		if pos == 8 {
+			pos++
+		}
+		xip[pos] = b
+		pos++
+	}
+	return xip, nil
+}
+
+// Synthesize64WKP returns the IPv4-embedded IPv6 address for v4 under the
+// NAT64 Well-Known Prefix, so 192.0.2.33 becomes 64:ff9b::c000:221. Note that
+// RFC6052 forbids using the Well-Known Prefix with non-global addresses such
+// as those in RFC1918 space, but that is left to the caller to enforce. If v4
+// is not an IPv4 address nil is returned
+func Synthesize64WKP(v4 net.IP) net.IP {
+	xip, err := Synthesize64(WellKnownPrefix(), v4)
+	if err != nil {
+		return nil
+	}
+	return xip
+}
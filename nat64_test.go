@@ -0,0 +1,90 @@
+package iplib
+
+import (
+	"net"
+	"testing"
+)
+
+func TestWellKnownPrefix(t *testing.T) {
+	wkp := WellKnownPrefix()
+	if wkp.String() != "64:ff9b::/96" {
+		t.Errorf("want 64:ff9b::/96 got %s", wkp)
+	}
+}
+
+var isWellKnownPrefixTests = []struct {
+	xnet Net6
+	is   bool
+}{
+	{Net6FromStr("64:ff9b::/96"), true},
+	{NewNet6(net.ParseIP("64:ff9b::1"), 96, 0), true},
+	{Net6FromStr("64:ff9b::/64"), false},
+	{Net6FromStr("64:ff9b::/112"), false},
+	{Net6FromStr("64:ff9b:1::/96"), false},
+	{Net6FromStr("2001:db8::/96"), false},
+	{Net6{}, false},
+}
+
+func TestIsWellKnownPrefix(t *testing.T) {
+	for i, tt := range isWellKnownPrefixTests {
+		if v := IsWellKnownPrefix(tt.xnet); v != tt.is {
+			t.Errorf("[%d] %s: want %t got %t", i, tt.xnet, tt.is, v)
+		}
+	}
+}
+
+var synthesize64WKPTests = []struct {
+	v4  net.IP
+	out net.IP
+}{
+	{net.ParseIP("192.0.2.33"), net.ParseIP("64:ff9b::192.0.2.33")},
+	{net.IP{192, 0, 2, 33}, net.ParseIP("64:ff9b::c000:221")},
+	{net.ParseIP("0.0.0.0"), net.ParseIP("64:ff9b::")},
+	{net.ParseIP("255.255.255.255"), net.ParseIP("64:ff9b::ffff:ffff")},
+	{net.ParseIP("2001:db8::1"), nil},
+	{nil, nil},
+}
+
+func TestSynthesize64WKP(t *testing.T) {
+	for i, tt := range synthesize64WKPTests {
+		if xip := Synthesize64WKP(tt.v4); !xip.Equal(tt.out) {
+			t.Errorf("[%d] %s: want %s got %s", i, tt.v4, tt.out, xip)
+		}
+	}
+}
+
+// examples from RFC6052 section 2.4
+var synthesize64Tests = []struct {
+	prefix    string
+	prefixlen int
+	out       string
+	err       error
+}{
+	{"2001:db8::", 32, "2001:db8:c000:221::", nil},
+	{"2001:db8:100::", 40, "2001:db8:1c0:2:21::", nil},
+	{"2001:db8:122::", 48, "2001:db8:122:c000:2:2100::", nil},
+	{"2001:db8:122:300::", 56, "2001:db8:122:3c0:0:221::", nil},
+	{"2001:db8:122:344::", 64, "2001:db8:122:344:c0:2:2100:0", nil},
+	{"2001:db8:122:344::", 96, "2001:db8:122:344::192.0.2.33", nil},
+	{"2001:db8::", 33, "", ErrBadMaskLength},
+	{"2001:db8::", 0, "", ErrBadMaskLength},
+}
+
+func TestSynthesize64(t *testing.T) {
+	v4 := net.ParseIP("192.0.2.33")
+	for i, tt := range synthesize64Tests {
+		xip, err := Synthesize64(NewNet6(net.ParseIP(tt.prefix), tt.prefixlen, 0), v4)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err == nil && !xip.Equal(net.ParseIP(tt.out)) {
+			t.Errorf("[%d] want %s got %s", i, tt.out, xip)
+		}
+	}
+
+	prefix := NewNet6(net.ParseIP("2001:db8::"), 32, 0)
+	if _, err := Synthesize64(prefix, net.ParseIP("2001:db8::1")); err != ErrNoValidRange {
+		t.Errorf("v6 input: want %v got %v", ErrNoValidRange, err)
+	}
+}
@@ -1,14 +1,21 @@
 package iplib
 
 import (
+	"fmt"
+	"hash/fnv"
+	"math/big"
 	"net"
+	"sort"
 	"strings"
+
+	"lukechampine.com/uint128"
 )
 
 // Net describes an iplib.Net object, the enumerated functions are those that
 // are required for comparison, sorting, generic initialization and for
 // ancillary functions such as those found in the iid and iana submodules
 type Net interface {
+	BroadcastAddress() net.IP
 	Contains(ip net.IP) bool
 	ContainsNet(network Net) bool
 	FirstAddress() net.IP
@@ -16,6 +23,7 @@ type Net interface {
 	LastAddress() net.IP
 	Mask() net.IPMask
 	String() string
+	UsableCount() *big.Int
 	Version() int
 	finalAddress() (net.IP, int)
 }
@@ -35,44 +43,259 @@ func NewNet(ip net.IP, masklen int) Net {
 // netblocks spanning the range between them, inclusively, even if it must
 // return one or more single-address netblocks to do so
 func AllNetsBetween(a, b net.IP) ([]Net, error) {
-	var lastNet Net
-	if EffectiveVersion(a) == IP4Version {
-		lastNet = Net4{}
-	} else {
-		lastNet = Net6{}
+	var nets []Net
+	var err error
+	NetsBetweenIter(a, b)(func(n Net, e error) bool {
+		if e != nil {
+			err = e
+			return false
+		}
+		nets = append(nets, n)
+		return true
+	})
+	return nets, err
+}
+
+// AllNet4sBetween is AllNetsBetween for callers who know they are working
+// with IPv4, returning a []Net4 rather than a []Net so that no type assertions
+// are needed. If either a or b is not a v4 address ErrNoValidRange is
+// returned
+func AllNet4sBetween(a, b net.IP) ([]Net4, error) {
+	if EffectiveVersion(a) != IP4Version || EffectiveVersion(b) != IP4Version {
+		return nil, ErrNoValidRange
 	}
 
-	var nets []Net
+	nets, err := AllNetsBetween(a, b)
+	nets4 := make([]Net4, 0, len(nets))
+	for _, n := range nets {
+		nets4 = append(nets4, n.(Net4))
+	}
+	return nets4, err
+}
 
-	for {
-		ipnet, tf, err := NewNetBetween(a, b)
-		if err != nil {
-			return nets, err
-		}
+// AllNet6sBetween is AllNetsBetween for callers who know they are working
+// with IPv6, returning a []Net6 rather than a []Net so that no type assertions
+// are needed. If either a or b is not a v6 address ErrNoValidRange is
+// returned
+func AllNet6sBetween(a, b net.IP) ([]Net6, error) {
+	if EffectiveVersion(a) != IP6Version || EffectiveVersion(b) != IP6Version {
+		return nil, ErrNoValidRange
+	}
 
-		nets = append(nets, ipnet)
-		if tf {
-			return nets, nil
+	nets, err := AllNetsBetween(a, b)
+	nets6 := make([]Net6, 0, len(nets))
+	for _, n := range nets {
+		nets6 = append(nets6, n.(Net6))
+	}
+	return nets6, err
+}
+
+// NetsBetweenIter is the iterator counterpart to AllNetsBetween, it yields
+// the same netblocks in the same order but one at a time, so that very wide
+// ranges do not need to be held in a single slice. If an error occurs it is
+// yielded with a nil Net as the final value. The returned function has the
+// same signature as iter.Seq2[Net, error] and can be used directly with
+// range-over-func on Go 1.23 or later
+func NetsBetweenIter(a, b net.IP) func(yield func(Net, error) bool) {
+	return func(yield func(Net, error) bool) {
+		var lastNet Net
+		if EffectiveVersion(a) == IP4Version {
+			lastNet = Net4{}
+		} else {
+			lastNet = Net6{}
 		}
 
-		finalIP, _ := ipnet.finalAddress()
-		if CompareIPs(finalIP, b) > 0 {
-			return nets, nil
+		start := a
+		for {
+			ipnet, tf, err := NewNetBetween(start, b)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !yield(ipnet, nil) || tf {
+				return
+			}
+
+			finalIP, _ := ipnet.finalAddress()
+			if CompareIPs(finalIP, b) > 0 {
+				return
+			}
+
+			if lastNet.IP() == nil {
+				lastNet = ipnet
+			} else if CompareIPs(ipnet.IP(), lastNet.IP()) > 0 {
+				lastNet = ipnet
+			} else {
+				return
+			}
+
+			start = NextIP(finalIP)
+			if CompareIPs(start, b) > 0 {
+				return
+			}
 		}
+	}
+}
+
+// ExactNet returns the single netblock which begins at first and ends at last,
+// inclusive. Where NewNetBetween() returns the largest netblock that fits and
+// signals an exact fit with a boolean, ExactNet treats anything other than an
+// exact fit as failure: if first and last are different versions, out of
+// order, or do not bound a correctly aligned CIDR block ErrNoValidRange is
+// returned. So 192.168.1.0 and 192.168.1.255 yield 192.168.1.0/24, but
+// 192.168.1.0 and 192.168.1.100 are an error
+func ExactNet(first, last net.IP) (Net, error) {
+	xnet, exact, err := NewNetBetween(first, last)
+	if err != nil {
+		return nil, err
+	}
+	if !exact {
+		return nil, ErrNoValidRange
+	}
+	return xnet, nil
+}
 
-		if lastNet.IP() == nil {
-			lastNet = ipnet
-		} else if CompareIPs(ipnet.IP(), lastNet.IP()) > 0 {
-			lastNet = ipnet
+// FindOverlaps returns every pair of networks in nets which share at least
+// one address, for example because one is a subnet of the other or because
+// they are duplicates. Within each pair the network that sorts first
+// according to CompareNets() is in position 0. The input is sorted and swept
+// rather than compared pairwise, so it is reasonable to call on large lists.
+// The extent of each network is taken from its netmask alone, so a Net6
+// hostmask does not hide overlaps. Nil or empty networks are ignored, and v4
+// and v6 networks never overlap
+func FindOverlaps(nets []Net) [][2]Net {
+	var v4, v6 []Net
+	for _, n := range nets {
+		if n == nil || n.IP() == nil {
+			continue
+		}
+		if n.Version() == IP4Version {
+			v4 = append(v4, n)
 		} else {
-			return nets, nil
+			v6 = append(v6, n)
+		}
+	}
+
+	overlaps := [][2]Net{}
+	for _, list := range [][]Net{v4, v6} {
+		sort.Sort(ByNet(list))
+		for i := range list {
+			end := netBounds(list[i]).last
+			for j := i + 1; j < len(list) && netBounds(list[j]).first.Cmp(end) <= 0; j++ {
+				overlaps = append(overlaps, [2]Net{list[i], list[j]})
+			}
+		}
+	}
+	return overlaps
+}
+
+// NetBroadcast returns the upper bound of n without the caller needing to know
+// its type. For a Net4 this is the broadcast address. IPv6 has no broadcast
+// address, so for a Net6 it is the address with every host bit set, which
+// ignores any hostmask. It is a nil-safe wrapper around n.BroadcastAddress():
+// if n is nil or empty nil is returned
+func NetBroadcast(n Net) net.IP {
+	if n == nil || n.IP() == nil {
+		return nil
+	}
+	return n.BroadcastAddress()
+}
+
+// NetsTable returns nets as an aligned, multi-line table with a header, one
+// row per network giving its CIDR, first and last usable address and usable
+// count, as TableRow() does for a single Net4. Column widths are sized to the
+// widest cell so that v4 and v6 networks can share a table, and the count is
+// right-aligned. Nil entries are skipped. Every line, including the last, is
+// terminated by a newline
+func NetsTable(nets []Net) string {
+	rows := [][4]string{tableHeader}
+	for _, n := range nets {
+		if n != nil {
+			rows = append(rows, tableCells(n))
+		}
+	}
+
+	var widths [4]int
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for _, row := range rows {
+		sb.WriteString(fmt.Sprintf("%-*s  %-*s  %-*s  %*s\n",
+			widths[0], row[0], widths[1], row[1], widths[2], row[2], widths[3], row[3]))
+	}
+	return sb.String()
+}
+
+// FitNet returns the smallest single network containing every address in
+// ips, along with the fraction of that network's addresses which are not in
+// ips, so that an allocator can judge whether one enclosing prefix is an
+// efficient fit or whether the set would be better split. Duplicate addresses
+// are only counted once, and as with Utilization() the whole block, including
+// any network and broadcast addresses, is considered: 10.0.0.1 and 10.0.0.2
+// fit in 10.0.0.0/30 with half of it wasted. If ips is empty, contains a nil
+// address, or mixes v4 and v6 addresses ErrNoValidRange is returned
+func FitNet(ips []net.IP) (Net, float64, error) {
+	if len(ips) == 0 {
+		return nil, 0, ErrNoValidRange
+	}
+
+	version := EffectiveVersion(ips[0])
+	hosts := make([]Net, 0, len(ips))
+	lo, hi := uint128.Max, uint128.Zero
+	for _, ip := range ips {
+		if v := EffectiveVersion(ip); v == 0 || v != version {
+			return nil, 0, ErrNoValidRange
 		}
 
-		a = NextIP(finalIP)
-		if CompareIPs(a, b) > 0 {
-			return nets, nil
+		var z uint128.Uint128
+		if version == IP4Version {
+			z = uint128.From64(uint64(IP4ToUint32(ip)))
+			hosts = append(hosts, NewNet4(ip, 32))
+		} else {
+			z = IP6ToUint128(ip)
+			hosts = append(hosts, NewNet6(ip, 128, 0))
+		}
+		if z.Cmp(lo) < 0 {
+			lo = z
+		}
+		if z.Cmp(hi) > 0 {
+			hi = z
 		}
 	}
+
+	var n Net
+	if version == IP4Version {
+		n = NewNet4(Uint32ToIP4(uint32(lo.Lo)), lo.Xor(hi).LeadingZeros()-96)
+	} else {
+		n = NewNet6(Uint128ToIP6(lo), lo.Xor(hi).LeadingZeros(), 0)
+	}
+	return n, 1 - utilization(n, hosts), nil
+}
+
+// LargestNetFrom returns the largest netblock whose network address is a and
+// whose final address is not greater than b. It is the building block used by
+// NewNetBetween() and AllNetsBetween() and is exposed for callers who want to
+// write their own range-to-CIDR loops: call it, then call it again starting
+// from the address following the returned block. The boolean is true if the
+// block is an exact fit, i.e. its final address is b. If a and b are not the
+// same IP version, if either is nil, or if a is greater than b
+// ErrNoValidRange is returned
+func LargestNetFrom(a, b net.IP) (Net, bool, error) {
+	if EffectiveVersion(a) == 0 || EffectiveVersion(a) != EffectiveVersion(b) {
+		return nil, false, ErrNoValidRange
+	}
+	if CompareIPs(a, b) > 0 {
+		return nil, false, ErrNoValidRange
+	}
+
+	return fitNetworkBetween(a, b, 0)
 }
 
 // NewNetBetween takes two net.IP's as input and will return the largest
@@ -117,8 +340,68 @@ func (bn ByNet) Less(a, b int) bool {
 	return val == -1
 }
 
+// ByNetSpecificity implements sort.Interface for iplib.Net based on the
+// starting address of the netblock, with the more specific netmask as a tie
+// breaker. So if two Networks share a starting address the subnet will be
+// returned first. For details see CompareNetsBySpecificity()
+type ByNetSpecificity []Net
+
+// Len implements sort.interface Len(), returning the length of the
+// ByNetSpecificity array
+func (bn ByNetSpecificity) Len() int {
+	return len(bn)
+}
+
+// Swap implements sort.interface Swap(), swapping two elements in our array
+func (bn ByNetSpecificity) Swap(a, b int) {
+	bn[a], bn[b] = bn[b], bn[a]
+}
+
+// Less implements sort.interface Less(), given two elements in the array it
+// returns true if the LHS should sort before the RHS
+func (bn ByNetSpecificity) Less(a, b int) bool {
+	return CompareNetsBySpecificity(bn[a], bn[b]) == -1
+}
+
+// BySize implements sort.Interface for iplib.Net based on the number of
+// usable addresses in each netblock, as reported by UsableCount(). By default
+// the smallest networks sort first, set Descending to place the largest first
+// as a VLSM allocator would want. Networks of equal size are ordered as ByNet
+// would order them. It complements ByNet rather than replacing it:
+//
+//	sort.Sort(iplib.BySize{Nets: nets, Descending: true})
+type BySize struct {
+	Nets       []Net
+	Descending bool
+}
+
+// Len implements sort.interface Len(), returning the length of the
+// BySize array
+func (bs BySize) Len() int {
+	return len(bs.Nets)
+}
+
+// Swap implements sort.interface Swap(), swapping two elements in our array
+func (bs BySize) Swap(a, b int) {
+	bs.Nets[a], bs.Nets[b] = bs.Nets[b], bs.Nets[a]
+}
+
+// Less implements sort.interface Less(), given two elements in the array it
+// returns true if the LHS should sort before the RHS
+func (bs BySize) Less(a, b int) bool {
+	val := bs.Nets[a].UsableCount().Cmp(bs.Nets[b].UsableCount())
+	if val == 0 {
+		return CompareNets(bs.Nets[a], bs.Nets[b]) == -1
+	}
+	if bs.Descending {
+		return val == 1
+	}
+	return val == -1
+}
+
 // ParseCIDR returns a new Net object. It is a passthrough to net.ParseCIDR
-// and will return any error it generates to the caller. There is one major
+// and any error it generates is returned to the caller wrapped in a
+// *ParseError identifying the offending part of the string. There is one major
 // difference between how net.IPNet manages addresses and how ipnet.Net does,
 // and this function exposes it: net.ParseCIDR *always* returns an IPv6
 // address; if given a v4 address it returns the RFC4291 IPv4-mapped IPv6
@@ -127,7 +410,7 @@ func (bn ByNet) Less(a, b int) bool {
 func ParseCIDR(s string) (net.IP, Net, error) {
 	ip, ipnet, err := net.ParseCIDR(s)
 	if err != nil {
-		return ip, nil, err
+		return ip, nil, newCIDRParseError(s, err)
 	}
 	masklen, _ := ipnet.Mask.Size()
 
@@ -142,6 +425,121 @@ func ParseCIDR(s string) (net.IP, Net, error) {
 	return ip, NewNet6(ip, masklen, 0), err
 }
 
+// ParseCIDRAs parses s as a network of the given IP version, removing the
+// guesswork ParseCIDR must do with ambiguous strings. With version 4 the
+// result is exactly that of Net4FromStrErr, so an IPv4-mapped address such
+// as ::ffff:c0a8:0101/24 yields 192.168.1.0/24 while the same address at /64
+// is an error. With version 6 any colon-delimited address, IPv4-mapped or
+// not, is treated as a 128-bit address: ::ffff:c0a8:0101/24 yields ::/24. If s
+// cannot be parsed as the requested family a *ParseError is returned, and if
+// version is neither 4 nor 6 ErrNoValidRange is returned
+func ParseCIDRAs(s string, version int) (Net, error) {
+	switch version {
+	case IP4Version:
+		n4, err := Net4FromStrErr(s)
+		if err != nil {
+			return nil, err
+		}
+		return n4, nil
+	case IP6Version:
+		ip, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, newCIDRParseError(s, err)
+		}
+		if !strings.Contains(s, ":") {
+			return nil, &ParseError{Input: s, Token: s, Err: &net.ParseError{Type: "IPv6 CIDR address", Text: s}}
+		}
+		masklen, _ := ipnet.Mask.Size()
+		return NewNet6(ip.To16(), masklen, 0), nil
+	}
+	return nil, ErrNoValidRange
+}
+
+// ParseCIDRPreferV4 behaves exactly like ParseCIDR except for one edge case:
+// when given an RFC4291 IPv4-mapped IPv6 address with a masklen greater than
+// 32, such as ::ffff:c0a8:0101/64, ParseCIDR silently returns a v6 network
+// (::/64 in this example) while ParseCIDRPreferV4 treats the mask as a likely
+// mistake and returns ErrBadMaskLength
+func ParseCIDRPreferV4(s string) (net.IP, Net, error) {
+	ip, ipnet, err := ParseCIDR(s)
+	if err != nil {
+		return ip, ipnet, err
+	}
+	if Is4in6(ip) && ipnet.Version() != IP4Version {
+		return nil, nil, ErrBadMaskLength
+	}
+	return ip, ipnet, nil
+}
+
+// SameNetwork returns true if a and b describe the same block of addresses,
+// regardless of how each is represented. Both are first reduced to their
+// native form, so a Net6 such as ::ffff:c0a8:0/120 is treated as the v4
+// network 192.168.0.0/24, and then the network addresses and mask lengths are
+// compared. Unlike comparing the concrete types this recognizes 4in6 and
+// native v4 forms of a block as identical. As with Contains() the hostmask of
+// a Net6 is not considered. Two nil networks are the same, a nil and a non-nil
+// network are not
+func SameNetwork(a, b Net) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	aip, aones := nativeNet(a)
+	bip, bones := nativeNet(b)
+	return aones == bones && aip.Equal(bip) && len(aip) == len(bip)
+}
+
+// WhichNet returns the most-specific network in nets which contains ip, that
+// is the matching network with the longest mask. If no network contains ip
+// ok will be false. Nil or empty entries in nets are ignored, and when two
+// matching networks share a mask length the first one in nets is returned.
+// This checks every network in turn; when the same large list will be
+// searched many times use Prepare() and Matcher.WhichNet() instead
+func WhichNet(ip net.IP, nets []Net) (Net, bool) {
+	var match Net
+	best := -1
+	for _, n := range nets {
+		if n == nil || n.IP() == nil || !n.Contains(ip) {
+			continue
+		}
+		if ones, _ := n.Mask().Size(); ones > best {
+			match, best = n, ones
+		}
+	}
+	return match, match != nil
+}
+
+// nativeNet returns the network address and mask length of n, converting an
+// RFC4291 IPv4-mapped Net6 with a mask of at least 96 bits to its v4 form
+func nativeNet(n Net) (net.IP, int) {
+	ones, all := n.Mask().Size()
+	ip := n.IP()
+	if n.Version() == IP4Version {
+		return ForceIP4(ip), ones
+	}
+	if all == 128 && ones >= 96 && Is4in6(ip) {
+		return ForceIP4(ip), ones - 96
+	}
+	return ip.To16(), ones
+}
+
+// tableHeader holds the column titles used by NetsTable()
+var tableHeader = [4]string{"NETWORK", "FIRST", "LAST", "COUNT"}
+
+// tableCells returns the CIDR, first and last usable address and usable
+// count of n as strings, for use as the columns of a table row
+func tableCells(n Net) [4]string {
+	if n.IP() == nil {
+		return [4]string{"<nil>", "<nil>", "<nil>", "0"}
+	}
+	return [4]string{
+		n.String(),
+		n.FirstAddress().String(),
+		n.LastAddress().String(),
+		n.UsableCount().String(),
+	}
+}
+
 func fitNetworkBetween(a, b net.IP, mask int) (Net, bool, error) {
 	xnet := NewNet(a, mask)
 
@@ -161,6 +559,31 @@ func fitNetworkBetween(a, b net.IP, mask int) (Net, bool, error) {
 	return fitNetworkBetween(a, b, mask+1)
 }
 
+// hashNet returns the FNV-1a hash shared by Net4.Hash() and Net6.Hash()
+func hashNet(version int, ip net.IP, masklen, hostmasklen int) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte{byte(version)})
+	h.Write(ip)
+	h.Write([]byte{byte(masklen), byte(hostmasklen)})
+	return h.Sum64()
+}
+
+// utilization implements Net4.Utilization() and Net6.Utilization(). It
+// measures coverage of every address in the block, including the network and
+// broadcast addresses, so it deliberately does not use UsableCount()
+func utilization(n Net, used []Net) float64 {
+	if n.IP() == nil {
+		return 0
+	}
+	covered := NewIPSet(used).Intersection(NewIPSet([]Net{n})).count()
+
+	ones, all := n.Mask().Size()
+	total := new(big.Int).Lsh(big.NewInt(1), uint(all-ones))
+
+	f, _ := new(big.Rat).SetFrac(covered, total).Float64()
+	return f
+}
+
 func maskMax(ip net.IP) int {
 	if EffectiveVersion(ip) == 4 {
 		return 32
@@ -2,18 +2,60 @@ package iplib
 
 import (
 	"crypto/rand"
+	"fmt"
+	"io"
 	"math"
 	"math/big"
+	"math/bits"
 	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+
+	"lukechampine.com/uint128"
 )
 
 // Net4 is an implementation of Net intended for IPv4 netblocks. It has
 // functions to return the broadcast address and wildcard mask not present in
-// the IPv6 implementation
+// the IPv6 implementation. A Net4 may optionally carry a Hostmask, see
+// NewNet4WithHostmask()
 type Net4 struct {
 	net.IPNet
-	is4in6 bool
+	Hostmask HostMask
+	is4in6   bool
+}
+
+// EnumerateOptions controls the behavior of Net4.EnumerateOpts(). The zero
+// value reproduces Enumerate(0, 0): every usable address with the network
+// and broadcast addresses excluded
+type EnumerateOptions struct {
+	// IncludeNetwork prepends the network address to the usable addresses
+	IncludeNetwork bool
+
+	// IncludeBroadcast appends the broadcast address to the usable addresses,
+	// unless the network has a hostmask
+	IncludeBroadcast bool
+
+	// Offset is the position of the first address returned
+	Offset int
+
+	// Size is the maximum number of addresses returned, 0 means no limit
+	Size int
+
+	// Stride returns only every Stride'th address, 0 is the same as 1
+	Stride int
+}
+
+// SubnetBits describes one field of a hierarchical addressing plan for use
+// with Net4.SubnetByBits(), such as the region or availability zone portion
+// of the network bits
+type SubnetBits struct {
+	// Bits is the width of the field
+	Bits int
+
+	// Value is the value stored in the field, which must fit in Bits bits
+	Value uint32
 }
 
 // NewNet4 returns an initialized Net4 object at the specified masklen. If
@@ -30,6 +72,43 @@ func NewNet4(ip net.IP, masklen int) Net4 {
 	return Net4{IPNet: n, is4in6: Is4in6(ip)}
 }
 
+// NewNet4WithHostmask returns an initialized Net4 object at the specified
+// masklen with the specified hostmasklen. The hostmask behaves exactly as it
+// does for Net6 (see the documentation for HostMask) but within 32 bits, so it
+// can be used to reserve the rightmost bits of every address in the block for
+// some other purpose. When a hostmask is set the network address is the
+// first usable address, Count() reflects only the addresses outside the
+// hostmask, and Enumerate(), NextIP() and PreviousIP() step over the masked
+// bits. Networks derived from a Net4 via Subnet(), Supernet(), NextNet() or
+// PreviousNet() do not inherit its hostmask.
+//
+// If ip is not a v4 address ErrNoValidRange is returned; if either mask is
+// negative, or masklen plus hostmasklen exceeds 32, ErrBadMaskLength is
+// returned
+func NewNet4WithHostmask(ip net.IP, masklen, hostmasklen int) (Net4, error) {
+	if EffectiveVersion(ip) != IP4Version {
+		return Net4{}, ErrNoValidRange
+	}
+	if masklen < 0 || hostmasklen < 0 || masklen+hostmasklen > 32 {
+		return Net4{}, ErrBadMaskLength
+	}
+
+	n := NewNet4(ip, masklen)
+	if hostmasklen > 0 {
+		n.Hostmask = NewHostMask(hostmasklen)
+	}
+	return n, nil
+}
+
+// Net4FromPacked is the inverse of Net4.Packed(), returning the Net4 encoded
+// in b. If the masklen byte is greater than 32 ErrBadMaskLength is returned
+func Net4FromPacked(b [5]byte) (Net4, error) {
+	if b[4] > 32 {
+		return Net4{}, ErrBadMaskLength
+	}
+	return NewNet4(net.IP{b[0], b[1], b[2], b[3]}, int(b[4])), nil
+}
+
 // Net4FromStr takes a string which should be a v4 address in CIDR notation
 // and returns an initialized Net4. If the string isn't parseable an empty
 // Net4 will be returned
@@ -44,6 +123,273 @@ func Net4FromStr(s string) Net4 {
 	return Net4{}
 }
 
+// Net4FromStrErr is like Net4FromStr but returns an error rather than an
+// empty Net4 if the string can't be used. If the string is not a valid CIDR
+// or is a v6 network the error will be a *ParseError
+func Net4FromStrErr(s string) (Net4, error) {
+	_, n, err := ParseCIDR(s)
+	if err != nil {
+		return Net4{}, err
+	}
+	if n4, ok := n.(Net4); ok {
+		return n4, nil
+	}
+	return Net4{}, &ParseError{Input: s, Token: s, Err: &net.ParseError{Type: "IPv4 CIDR address", Text: s}}
+}
+
+// Net4FromShorthand takes a string containing a v4 network in abbreviated,
+// classful-style notation and returns an initialized Net4. Any missing
+// trailing octets are padded with zeroes, so "10/8" becomes 10.0.0.0/8 and
+// "192.168/16" becomes 192.168.0.0/16. Fully-specified addresses are also
+// accepted. If the string has more than four octets, an empty octet, an octet
+// outside 0-255, an octet or mask with a leading zero (which inet_aton() and
+// friends would read as octal), a missing or illegal mask, or sets bits past
+// the mask (as "10.1/8" does) a *ParseError is returned
+func Net4FromShorthand(s string) (Net4, error) {
+	perr := func(token string) error {
+		return &ParseError{Input: s, Token: token, Err: &net.ParseError{Type: "CIDR address", Text: s}}
+	}
+
+	addr, mask, ok := strings.Cut(s, "/")
+	if !ok {
+		return Net4{}, perr(s)
+	}
+
+	octets := strings.Split(addr, ".")
+	if len(octets) > 4 {
+		return Net4{}, perr(addr)
+	}
+
+	ip := make(net.IP, 4)
+	for i, octet := range octets {
+		v, err := parseDecimal(octet, 255)
+		if err != nil {
+			return Net4{}, perr(octet)
+		}
+		ip[i] = byte(v)
+	}
+
+	masklen, err := parseDecimal(mask, 32)
+	if err != nil {
+		return Net4{}, perr(mask)
+	}
+
+	if !ip.Equal(ip.Mask(net.CIDRMask(masklen, 32))) {
+		return Net4{}, perr(addr)
+	}
+
+	return NewNet4(ip, masklen), nil
+}
+
+// AggregateToSupernet returns the smallest single network which contains
+// every network in nets, i.e. the network formed by the prefix they all have
+// in common. Unlike Range4.CIDRs(), which returns an exact multi-block cover,
+// this deliberately over-covers so that a set of routes can be summarized as
+// one: 192.168.1.0/24 and 192.168.2.0/24 aggregate to 192.168.0.0/22. If nets
+// is empty, or contains an empty Net4, ErrNoValidRange is returned
+func AggregateToSupernet(nets []Net4) (Net4, error) {
+	if len(nets) == 0 {
+		return Net4{}, ErrNoValidRange
+	}
+
+	lo, hi := ^uint32(0), uint32(0)
+	for _, n := range nets {
+		if n.IP() == nil {
+			return Net4{}, ErrNoValidRange
+		}
+		if first := IP4ToUint32(n.IP()); first < lo {
+			lo = first
+		}
+		if last := IP4ToUint32(n.BroadcastAddress()); last > hi {
+			hi = last
+		}
+	}
+
+	masklen := bits.LeadingZeros32(lo ^ hi)
+	return NewNet4(Uint32ToIP4(lo), masklen), nil
+}
+
+// AlignNet returns the network of length masklen containing ip, snapping
+// down to the nearest aligned boundary at or before it, so 10.0.0.77 at /26
+// returns 10.0.0.64/26. This is the same network NewNet4() would return, but
+// named for the alignment it performs. If ip is not a v4 address or masklen
+// is outside of 0-32 an empty Net4 is returned
+func AlignNet(ip net.IP, masklen int) Net4 {
+	if EffectiveVersion(ip) != IP4Version || masklen < 0 || masklen > 32 {
+		return Net4{}
+	}
+	return NewNet4(ip, masklen)
+}
+
+// AlignNetUp returns the first network of length masklen which begins at or
+// after ip, snapping up to the next aligned boundary when ip isn't on one:
+// 10.0.0.77 at /26 returns 10.0.0.128/26 while 10.0.0.64 returns
+// 10.0.0.64/26. This lets a sequential allocator start from an arbitrary
+// address without overlapping whatever precedes it. If ip is not a v4
+// address, masklen is outside of 0-32, or there is no such network before the
+// end of the address space an empty Net4 is returned
+func AlignNetUp(ip net.IP, masklen int) Net4 {
+	n := AlignNet(ip, masklen)
+	if n.IP() == nil || n.IP().Equal(ip) {
+		return n
+	}
+
+	next := uint64(IP4ToUint32(n.IP())) + uint64(1)<<uint(32-masklen)
+	if next > uint64(^uint32(0)) {
+		return Net4{}
+	}
+	return NewNet4(Uint32ToIP4(uint32(next)), masklen)
+}
+
+// DeltaNets returns the number of masklen-sized blocks separating the network
+// addresses of a and b, so the delta between 10.0.0.0/24 and 10.0.3.0/24 at
+// a masklen of 24 is 3. The result is the same regardless of which of a or
+// b is larger. If either network is empty ErrNoValidRange is returned, and
+// if masklen is greater than 32 or shorter than the netmask of either a or b
+// ErrBadMaskLength is returned
+func DeltaNets(a, b Net4, masklen int) (uint32, error) {
+	if a.IP() == nil || b.IP() == nil {
+		return 0, ErrNoValidRange
+	}
+
+	aones, _ := a.Mask().Size()
+	bones, _ := b.Mask().Size()
+	if masklen > 32 || masklen < aones || masklen < bones {
+		return 0, ErrBadMaskLength
+	}
+
+	return DeltaIP4(a.IP(), b.IP()) >> uint(32-masklen), nil
+}
+
+// GapBetween returns the minimal set of networks covering the address space
+// strictly between the end of a and the start of b, in ascending order, as
+// AllNet4sBetween() would for the addresses bounding the gap. It is intended
+// for filling holes between allocations. If a and b are directly adjacent the
+// result is empty. If either network is empty, if they overlap, or if b does
+// not come after a ErrNoValidRange is returned
+func GapBetween(a, b Net4) ([]Net4, error) {
+	if a.IP() == nil || b.IP() == nil {
+		return nil, ErrNoValidRange
+	}
+
+	aEnd := uint64(IP4ToUint32(a.BroadcastAddress()))
+	bStart := uint64(IP4ToUint32(b.IP()))
+	if bStart <= aEnd {
+		return nil, ErrNoValidRange
+	}
+	if bStart == aEnd+1 {
+		return []Net4{}, nil
+	}
+	return AllNet4sBetween(Uint32ToIP4(uint32(aEnd+1)), Uint32ToIP4(uint32(bStart-1)))
+}
+
+// NextFreeAcross returns the lowest usable address not in used, trying each
+// of pools in turn, along with the pool it was found in. Pools which are empty
+// or fully allocated are skipped, so this is the allocation loop for an IPAM
+// system spanning several blocks. A nil used treats every address as free. If
+// every pool is exhausted ErrInsufficientSpace is returned
+func NextFreeAcross(pools []Net4, used *IPSet) (net.IP, Net4, error) {
+	for _, pool := range pools {
+		if ip, ok := pool.firstFree(used); ok {
+			return ip, pool, nil
+		}
+	}
+	return nil, Net4{}, ErrInsufficientSpace
+}
+
+// ShareParent returns the network of length masklen which encloses the first
+// element of nets, and true if every other element of nets also falls within
+// it. This can be used to verify that a set of subnets belong to the same
+// allocation before aggregating them. If nets is empty or masklen is outside
+// of 0-32 an empty Net4 and false are returned
+func ShareParent(nets []Net4, masklen int) (Net4, bool) {
+	if len(nets) == 0 || masklen < 0 || masklen > 32 || nets[0].IP() == nil {
+		return Net4{}, false
+	}
+	parent := NewNet4(nets[0].IP(), masklen)
+	for _, n := range nets {
+		if n.IP() == nil || !parent.ContainsNet(n) {
+			return parent, false
+		}
+	}
+	return parent, true
+}
+
+// AddressIterExcluding returns an iterator, with the same signature as
+// iter.Seq[net.IP], which yields the usable addresses of n in ascending order
+// while skipping any that are members of excl. A nil excl excludes nothing
+func (n Net4) AddressIterExcluding(excl *IPSet) func(yield func(net.IP) bool) {
+	_, iter := n.EnumerateOrIter(-1)
+	return func(yield func(net.IP) bool) {
+		if iter == nil {
+			return
+		}
+		iter(func(ip net.IP) bool {
+			if excl.Contains(ip) {
+				return true
+			}
+			return yield(ip)
+		})
+	}
+}
+
+// AllocateVLSMWithRemainder carves n into variable-length subnets, one for
+// each of the requested host counts, and also returns the space left over
+// after the allocation. Each subnet is the smallest network whose Count() is
+// at least the requested number of hosts, and subnets are packed from the
+// start of n largest-first so that each remains aligned. The allocated slice
+// is in the same order as hostCounts, while the remainder is the minimal set
+// of networks covering the unallocated tail of n, in ascending order; between
+// them the two slices exactly tile n.
+//
+// If any host count is less than 1 an ErrBadMaskLength will be returned, if
+// the requests cannot all fit inside n the error will be ErrInsufficientSpace
+func (n Net4) AllocateVLSMWithRemainder(hostCounts []int) ([]Net4, []Net4, error) {
+	ones, _ := n.Mask().Size()
+
+	masks := make([]int, len(hostCounts))
+	order := make([]int, len(hostCounts))
+	for i, c := range hostCounts {
+		if c < 1 {
+			return nil, nil, ErrBadMaskLength
+		}
+		masks[i] = masklenForHosts4(c)
+		if masks[i] < ones {
+			return nil, nil, ErrInsufficientSpace
+		}
+		order[i] = i
+	}
+
+	// allocate the largest blocks first, this guarantees every block lands on
+	// a boundary appropriate to its size
+	sort.SliceStable(order, func(a, b int) bool { return masks[order[a]] < masks[order[b]] })
+
+	cur := uint64(IP4ToUint32(n.IP()))
+	end := uint64(IP4ToUint32(n.BroadcastAddress()))
+
+	allocated := make([]Net4, len(hostCounts))
+	for _, i := range order {
+		size := uint64(1) << uint(32-masks[i])
+		if cur+size-1 > end {
+			return nil, nil, ErrInsufficientSpace
+		}
+		allocated[i] = NewNet4(Uint32ToIP4(uint32(cur)), masks[i])
+		allocated[i].is4in6 = n.is4in6
+		cur += size
+	}
+
+	remainder := []Net4{}
+	if cur <= end {
+		r, _ := NewRange4(Uint32ToIP4(uint32(cur)), n.BroadcastAddress())
+		remainder = r.CIDRs()
+		for i := range remainder {
+			remainder[i].is4in6 = n.is4in6
+		}
+	}
+
+	return allocated, remainder, nil
+}
+
 // BroadcastAddress returns the broadcast address for the represented network.
 // In the context of IPv6 broadcast is meaningless and the value will be
 // equivalent to LastAddress().
@@ -52,6 +398,30 @@ func (n Net4) BroadcastAddress() net.IP {
 	return xip
 }
 
+// CanAggregateWith returns true if n and other can be summarized as a single
+// network without covering any extra address space. That is the case when
+// one of them contains the other, so the smaller can simply be dropped, or
+// when they are the same size and are the two halves of a common parent, so
+// 10.0.0.0/24 and 10.0.1.0/24 can be merged into 10.0.0.0/23 while
+// 10.0.1.0/24 and 10.0.2.0/24, though adjacent, cannot. Empty networks never
+// aggregate
+func (n Net4) CanAggregateWith(other Net4) bool {
+	if n.IP() == nil || other.IP() == nil {
+		return false
+	}
+	if n.ContainsNet(other) || other.ContainsNet(n) {
+		return true
+	}
+
+	ones, _ := n.Mask().Size()
+	otherOnes, _ := other.Mask().Size()
+	if ones != otherOnes || ones == 0 {
+		return false
+	}
+	parent := NewNet4(n.IP(), ones-1)
+	return parent.ContainsNet(other)
+}
+
 // Contains returns true if ip is contained in the represented netblock
 func (n Net4) Contains(ip net.IP) bool {
 	return n.IPNet.Contains(ip)
@@ -65,11 +435,25 @@ func (n Net4) ContainsNet(network Net) bool {
 	return l1 <= l2 && n.Contains(network.IP())
 }
 
+// ContainsRange returns true if every address in r falls within n. As with
+// Contains() the whole netblock is considered, including the network and
+// broadcast addresses. An empty Range4 is not contained by any network
+func (n Net4) ContainsRange(r Range4) bool {
+	if n.IP() == nil || r.first == nil {
+		return false
+	}
+	final, _ := n.finalAddress()
+	return CompareIPs(r.first, n.IP()) >= 0 && CompareIPs(r.last, final) <= 0
+}
+
 // Count returns the total number of usable IP addresses in the represented
 // network..
 func (n Net4) Count() uint32 {
 	ones, all := n.Mask().Size()
 	exp := all - ones
+	if hmlen, _ := n.Hostmask.Size(); hmlen > 0 {
+		return uint32(1) << uint(exp-hmlen)
+	}
 	if exp == 1 {
 		return uint32(2) // special handling for RFC3021 /31
 	}
@@ -79,6 +463,22 @@ func (n Net4) Count() uint32 {
 	return uint32(math.Pow(2, float64(exp))) - 2
 }
 
+// DescendantsAtDepth returns every subnet of n whose mask is exactly depth
+// bits longer than n's, in ascending order, i.e. one level of the subnet tree
+// rooted at n: a depth of 2 applied to a /24 returns its four /26s. A depth
+// of 0 returns n itself. If depth is negative, or n's mask plus depth exceeds
+// 32, ErrBadMaskLength is returned
+func (n Net4) DescendantsAtDepth(depth int) ([]Net4, error) {
+	ones, all := n.Mask().Size()
+	if depth < 0 || ones+depth > all {
+		return nil, ErrBadMaskLength
+	}
+	if depth == 0 {
+		return []Net4{n}, nil
+	}
+	return n.Subnet(ones + depth)
+}
+
 // Enumerate generates an array of all usable addresses in Net up to the
 // given size starting at the given offset. If size=0 the entire block is
 // enumerated.
@@ -89,79 +489,323 @@ func (n Net4) Count() uint32 {
 //
 // For consistency, enumerating a /32 will return the IP in a 1 element array
 func (n Net4) Enumerate(size, offset int) []net.IP {
-	if n.IP() == nil {
+	return n.EnumerateOpts(EnumerateOptions{Size: size, Offset: offset})
+}
+
+// EnumerateExcluding is like Enumerate() but omits any address which is a
+// member of excl. Offset and size count only addresses that are not
+// excluded, so EnumerateExcluding(excl, 10, 0) returns the first 10 usable
+// addresses not in excl. If size is 0 every remaining address is returned,
+// and if either size or offset is negative nil is returned. For large blocks
+// see AddressIterExcluding(), which does not materialize the result
+func (n Net4) EnumerateExcluding(excl *IPSet, size, offset int) []net.IP {
+	if n.IP() == nil || size < 0 || offset < 0 {
 		return nil
 	}
 
-	count := int(n.Count())
+	addrs := []net.IP{}
+	n.AddressIterExcluding(excl)(func(ip net.IP) bool {
+		if offset > 0 {
+			offset--
+			return true
+		}
+		addrs = append(addrs, ip)
+		return size == 0 || len(addrs) < size
+	})
+	return addrs
+}
 
-	// offset exceeds total, return an empty array
-	if offset > count {
-		return []net.IP{}
+// EnumerateOpts is the general form of Enumerate() and EnumerateStride(),
+// returning the addresses of n selected by opts. The network and broadcast
+// addresses are treated as if they were at either end of the list of usable
+// addresses, so Offset, Size and Stride apply across them as well. In
+// networks where they are already usable, such as a /31 or /32, the Include
+// options have no effect. IncludeBroadcast is also ignored if n has a
+// hostmask, since the broadcast address has masked bits set and so is not
+// part of the enumerable space. If Offset, Size or Stride are negative nil is
+// returned
+func (n Net4) EnumerateOpts(opts EnumerateOptions) []net.IP {
+	if n.IP() == nil || opts.Offset < 0 || opts.Size < 0 || opts.Stride < 0 {
+		return nil
 	}
-
-	// size is greater than the number of addresses that can be returned,
-	// adjust the size of the slice but keep going
-	if size > (count-offset) || size == 0 {
-		size = count - offset
+	if opts.Stride <= 1 && !opts.IncludeNetwork && !opts.IncludeBroadcast {
+		return n.enumerate(opts.Size, opts.Offset)
 	}
 
-	// Handle edge-case mask sizes
-	if count == 1 { // Count() returns 1 if host-bits == 0
-		return []net.IP{CopyIP(n.IPNet.IP)}
+	stride := uint64(1)
+	if opts.Stride > 1 {
+		stride = uint64(opts.Stride)
 	}
 
-	addrs := make([]net.IP, size)
+	hmlen, _ := n.Hostmask.Size()
+	first := n.FirstAddress()
+	count := uint64(n.Count())
 
-	netu := IP4ToUint32(n.FirstAddress())
-	netu += uint32(offset)
+	var lead, trail net.IP
+	if opts.IncludeNetwork && !n.NetworkAddress().Equal(first) {
+		lead = CopyIP(n.NetworkAddress())
+	}
+	// with a hostmask the broadcast address lies outside the usable space, so
+	// it is never appended
+	if bc := n.BroadcastAddress(); opts.IncludeBroadcast && hmlen == 0 && !bc.Equal(n.LastAddress()) {
+		trail = bc
+	}
 
-	fip := Uint32ToIP4(netu)
+	total := count
+	if lead != nil {
+		total++
+	}
+	if trail != nil {
+		total++
+	}
 
-	limit := 65535
-	pos := 0
-	wg := sync.WaitGroup{}
-	for pos < size {
-		incr := limit
-		if limit > (size - pos) {
-			incr = size - pos
+	addrs := []net.IP{}
+	for pos := uint64(opts.Offset); pos < total; pos += stride {
+		if opts.Size > 0 && len(addrs) >= opts.Size {
+			break
 		}
-		wg.Add(1)
-		go func(fip net.IP, pos, count int) {
-			defer wg.Done()
-			addrs[pos] = IncrementIP4By(fip, uint32(pos))
-			for i := 1; i < count; i++ {
-				pos++
-				addrs[pos] = NextIP(addrs[pos-1])
+		idx := pos
+		if lead != nil {
+			if idx == 0 {
+				addrs = append(addrs, lead)
+				continue
 			}
-		}(fip, pos, incr)
-		pos = pos + incr
+			idx--
+		}
+		switch {
+		case idx >= count:
+			addrs = append(addrs, trail)
+		case hmlen > 0:
+			xip, _ := IncrementIP6WithinHostmask(first.To16(), n.Hostmask, uint128.From64(idx))
+			addrs = append(addrs, ForceIP4(xip))
+		default:
+			addrs = append(addrs, IncrementIP4By(first, uint32(idx)))
+		}
 	}
-	wg.Wait()
 	return addrs
 }
 
+// EnumerateOrIter returns every usable address in n, either as a slice or as
+// an iterator depending on how many there are. If Count() is less than or
+// equal to maxSlice the slice is populated, exactly as Enumerate(0, 0) would
+// return it, and the iterator is nil. Otherwise the slice is nil and the
+// iterator, which has the same signature as iter.Seq[net.IP], yields the same
+// addresses one at a time without materializing them
+func (n Net4) EnumerateOrIter(maxSlice int) ([]net.IP, func(yield func(net.IP) bool)) {
+	if n.IP() == nil {
+		return nil, nil
+	}
+	if maxSlice >= 0 && uint64(n.Count()) <= uint64(maxSlice) {
+		return n.Enumerate(0, 0), nil
+	}
+
+	if hmlen, _ := n.Hostmask.Size(); hmlen > 0 {
+		return nil, func(yield func(net.IP) bool) {
+			ip := n.FirstAddress()
+			for {
+				if !yield(ip) {
+					return
+				}
+				var err error
+				if ip, err = n.NextIP(ip); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	return nil, func(yield func(net.IP) bool) {
+		end := uint64(IP4ToUint32(n.LastAddress()))
+		for cur := uint64(IP4ToUint32(n.FirstAddress())); cur <= end; cur++ {
+			if !yield(Uint32ToIP4(uint32(cur))) {
+				return
+			}
+		}
+	}
+}
+
+// FreeRanges returns the spans of address space within n that are not covered
+// by any of the networks in used, sorted in ascending order. The entire
+// netblock is considered, including the network and broadcast addresses, and
+// used networks may overlap one another or extend beyond n. If used is empty
+// the result is a single Range4 covering all of n, and if n is completely
+// covered the result is empty
+func (n Net4) FreeRanges(used []Net4) []Range4 {
+	if n.IP() == nil {
+		return nil
+	}
+
+	type span struct{ first, last uint64 }
+	start := uint64(IP4ToUint32(n.IP()))
+	end := uint64(IP4ToUint32(n.BroadcastAddress()))
+
+	spans := []span{}
+	for _, u := range used {
+		if u.IP() == nil {
+			continue
+		}
+		first := uint64(IP4ToUint32(u.IP()))
+		last := uint64(IP4ToUint32(u.BroadcastAddress()))
+		if last < start || first > end {
+			continue
+		}
+		spans = append(spans, span{first, last})
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].first < spans[j].first
+	})
+
+	free := []Range4{}
+	cur := start
+	for _, sp := range spans {
+		if sp.first > cur {
+			free = append(free, Range4{
+				first: Uint32ToIP4(uint32(cur)),
+				last:  Uint32ToIP4(uint32(sp.first - 1)),
+			})
+		}
+		if sp.last+1 > cur {
+			cur = sp.last + 1
+		}
+	}
+	if cur <= end {
+		free = append(free, Range4{
+			first: Uint32ToIP4(uint32(cur)),
+			last:  Uint32ToIP4(uint32(end)),
+		})
+	}
+	return free
+}
+
+// EnumerateStride is like Enumerate but returns only every stride'th usable
+// address, beginning with the address at position offset. So a stride of 256
+// over a /16 returns one address in each /24. If size is non-zero no more than
+// size addresses are returned. If stride is less than 1, or if either offset
+// or size is negative, nil is returned
+func (n Net4) EnumerateStride(stride, offset, size int) []net.IP {
+	if stride < 1 {
+		return nil
+	}
+	return n.EnumerateOpts(EnumerateOptions{Stride: stride, Offset: offset, Size: size})
+}
+
+// EnumerateStrings is a convenience wrapper around Enumerate() which returns
+// each address formatted as a string rather than as a net.IP
+func (n Net4) EnumerateStrings(size, offset int) []string {
+	addrs := n.Enumerate(size, offset)
+	if addrs == nil {
+		return nil
+	}
+	strs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		strs[i] = addr.String()
+	}
+	return strs
+}
+
+// EnumerateStringsIter returns an iterator, with the same signature as
+// iter.Seq[string], yielding every usable address in n formatted as a
+// string. Unlike EnumerateStrings() it does not materialize the addresses
+// and so is suitable for large netblocks
+func (n Net4) EnumerateStringsIter() func(yield func(string) bool) {
+	return func(yield func(string) bool) {
+		_, seq := n.EnumerateOrIter(-1)
+		if seq == nil {
+			return
+		}
+		seq(func(ip net.IP) bool {
+			return yield(ip.String())
+		})
+	}
+}
+
+// Family returns the address family of the enclosed netblock as a string,
+// "IPv4" in this case
+func (n Net4) Family() string {
+	return "IPv4"
+}
+
+// FindFirst walks the usable addresses of n in ascending order, from
+// FirstAddress() to LastAddress(), and returns the first one for which pred
+// returns true. Addresses are generated lazily, so the walk stops as soon as
+// a match is found without enumerating the rest of the block. If no address
+// matches, or n is empty, nil and false are returned
+func (n Net4) FindFirst(pred func(net.IP) bool) (net.IP, bool) {
+	_, iter := n.EnumerateOrIter(-1)
+	if iter == nil {
+		return nil, false
+	}
+
+	var found net.IP
+	iter(func(ip net.IP) bool {
+		if pred(ip) {
+			found = ip
+			return false
+		}
+		return true
+	})
+	return found, found != nil
+}
+
 // FirstAddress returns the first usable address for the represented network
 func (n Net4) FirstAddress() net.IP {
 	ones, _ := n.Mask().Size()
 
-	// if it's either a single IP or RFC 3021, return the network address
-	if ones >= 31 {
+	// if it's either a single IP or RFC 3021, or if there is a hostmask,
+	// return the network address
+	if hmlen, _ := n.Hostmask.Size(); ones >= 31 || hmlen > 0 {
 		return n.IPNet.IP
 	}
 	return NextIP(n.IP())
 }
 
+// Hash returns a 64-bit FNV-1a hash of the network, computed over its IP
+// version, network address, mask length and hostmask length. The hash is
+// stable across runs and platforms, so it may be used to deterministically
+// distribute networks across shards without first rendering them to strings
+func (n Net4) Hash() uint64 {
+	ones, _ := n.Mask().Size()
+	hmlen, _ := n.Hostmask.Size()
+	return hashNet(IP4Version, ForceIP4(n.IP()), ones, hmlen)
+}
+
 // Is4in6 will return true if this Net4 object or any of its parents were
 // explicitly initialized with a 4in6 address (::ffff:xxxx.xxx)
 func (n Net4) Is4in6() bool {
 	return n.is4in6
 }
 
+// IsAssignable returns true if ip could be handed out to a host from this
+// netblock: it must fall inside the netblock and must be neither the network
+// address nor the broadcast address. The exceptions are RFC3021 /31 networks,
+// in which both addresses are assignable, and /32 networks, whose single
+// address is assignable. If n has a hostmask the network address is
+// assignable but addresses with bits set inside the hostmask are not
+func (n Net4) IsAssignable(ip net.IP) bool {
+	if !n.Contains(ip) {
+		return false
+	}
+	if hmlen, _ := n.Hostmask.Size(); hmlen > 0 {
+		_, ok := hostmaskOrdinal(ip.To16(), n.Hostmask)
+		return ok
+	}
+	return CompareIPs(ip, n.FirstAddress()) >= 0 && CompareIPs(ip, n.LastAddress()) <= 0
+}
+
 // LastAddress returns the last usable address for the represented network
 func (n Net4) LastAddress() net.IP {
 	xip, ones := n.finalAddress()
 
+	// with a hostmask the last address is the broadcast address with the
+	// masked bits cleared, mirroring Net6
+	if hmlen, _ := n.Hostmask.Size(); hmlen > 0 {
+		hm := n.Hostmask[len(n.Hostmask)-len(xip):]
+		for pos := range xip {
+			xip[pos] -= hm[pos]
+		}
+		return xip
+	}
+
 	// if it's either a single IP or RFC 3021, return the last address
 	if ones >= 31 {
 		return xip
@@ -181,12 +825,36 @@ func (n Net4) IP() net.IP {
 	return n.IPNet.IP
 }
 
+// Midpoint returns the address halfway through the represented network,
+// calculated as the network address plus half the total number of addresses
+// in the block, so the midpoint of 192.168.1.0/24 is 192.168.1.128. The
+// midpoint of a /32 is the address itself
+func (n Net4) Midpoint() net.IP {
+	ones, all := n.Mask().Size()
+	if ones == all {
+		return CopyIP(n.IP())
+	}
+	return IncrementIP4By(n.IP(), uint32(1)<<uint(all-ones-1))
+}
+
 // NetworkAddress returns the network address for the represented network, e.g.
 // the lowest IP address in the given block
 func (n Net4) NetworkAddress() net.IP {
 	return n.IPNet.IP
 }
 
+// NextAddress returns the address immediately following the final address
+// of the netblock, which is also the network address of the adjacent block of
+// the same size. So the next address after 192.168.0.0/24 is 192.168.1.0. If
+// the netblock ends at 255.255.255.255 that address is returned, since there
+// is nothing beyond it
+func (n Net4) NextAddress() net.IP {
+	if n.IP() == nil {
+		return nil
+	}
+	return NextIP(n.BroadcastAddress())
+}
+
 // NextIP takes a net.IP as an argument and attempts to increment it by one.
 // If the resulting address is outside of the range of the represented network
 // it will return an empty net.IP and an ErrAddressOutOfRange. If the result
@@ -197,6 +865,13 @@ func (n Net4) NextIP(ip net.IP) (net.IP, error) {
 	if !n.Contains(ip) {
 		return net.IP{}, ErrAddressOutOfRange
 	}
+	if hmlen, _ := n.Hostmask.Size(); hmlen > 0 {
+		xip, err := NextIP6WithinHostmask(ip.To16(), n.Hostmask)
+		if err != nil || !n.Contains(xip) {
+			return net.IP{}, ErrAddressOutOfRange
+		}
+		return ForceIP4(xip), nil
+	}
 	xip := NextIP(ip)
 	if !n.Contains(xip) {
 		return net.IP{}, ErrAddressOutOfRange
@@ -219,6 +894,64 @@ func (n Net4) NextNet(masklen int) Net4 {
 	return NewNet4(nextIP, masklen)
 }
 
+// NextNSubnets returns the first count subnets of n at the given masklen, in
+// ascending order, without materializing the rest of them the way Subnet()
+// would. As with Subnet() a masklen of 0 is treated as one bit longer than
+// n's own mask. If masklen is shorter than n's mask or longer than 32
+// ErrBadMaskLength is returned, and if n cannot hold count such subnets
+// ErrInsufficientSpace is returned. A count less than 1 returns an empty slice
+func (n Net4) NextNSubnets(masklen, count int) ([]Net4, error) {
+	ones, all := n.Mask().Size()
+	if masklen == 0 {
+		masklen = ones + 1
+	}
+	if ones > masklen || masklen > all {
+		return nil, ErrBadMaskLength
+	}
+	if count < 1 {
+		return []Net4{}, nil
+	}
+	if available := uint64(1) << uint(masklen-ones); uint64(count) > available {
+		return nil, ErrInsufficientSpace
+	}
+
+	mask := net.CIDRMask(masklen, all)
+	step := uint64(1) << uint(all-masklen)
+	base := uint64(IP4ToUint32(n.IP()))
+
+	subnets := make([]Net4, 0, count)
+	for i := uint64(0); i < uint64(count); i++ {
+		ng := net.IPNet{IP: Uint32ToIP4(uint32(base + i*step)), Mask: mask}
+		subnets = append(subnets, Net4{IPNet: ng, is4in6: n.is4in6})
+	}
+	return subnets, nil
+}
+
+// Packed returns n in a fixed-width binary form suitable for fixed-size
+// records: the 4 bytes of the network address followed by one byte holding
+// the mask length. Any hostmask is not included. See Net4FromPacked()
+func (n Net4) Packed() [5]byte {
+	var b [5]byte
+	if ip := ForceIP4(n.IP()); ip != nil {
+		copy(b[:4], ip)
+	}
+	ones, _ := n.Mask().Size()
+	b[4] = byte(ones)
+	return b
+}
+
+// PreviousAddress returns the address immediately preceding the network
+// address of the netblock, which is also the final address of the adjacent
+// block of the same size. So the previous address before 192.168.1.0/24 is
+// 192.168.0.255. If the netblock begins at 0.0.0.0 that address is returned,
+// since there is nothing before it
+func (n Net4) PreviousAddress() net.IP {
+	if n.IP() == nil {
+		return nil
+	}
+	return PreviousIP(n.IP())
+}
+
 // PreviousIP takes a net.IP as an argument and attempts to decrement it by
 // one. If the resulting address is outside of the range of the represented
 // network it will return an empty net.IP and an ErrAddressOutOfRange. If the
@@ -229,6 +962,13 @@ func (n Net4) PreviousIP(ip net.IP) (net.IP, error) {
 	if !n.Contains(ip) {
 		return net.IP{}, ErrAddressOutOfRange
 	}
+	if hmlen, _ := n.Hostmask.Size(); hmlen > 0 {
+		xip, err := PreviousIP6WithinHostmask(ip.To16(), n.Hostmask)
+		if err != nil || !n.Contains(xip) {
+			return net.IP{}, ErrAddressOutOfRange
+		}
+		return ForceIP4(xip), nil
+	}
 	xip := PreviousIP(ip)
 	if !n.Contains(xip) {
 		return net.IP{}, ErrAddressOutOfRange
@@ -252,10 +992,212 @@ func (n Net4) PreviousNet(masklen int) Net4 {
 	return NewNet4(PreviousIP(n.IP()), masklen)
 }
 
-// RandomIP returns a random address from this Net4
+// RandomIP returns a random address from this Net4. It uses crypto/rand and
+// is equivalent to RandomIPFrom(nil), use that to supply a different source
+// of randomness
 func (n Net4) RandomIP() net.IP {
-	z, _ := rand.Int(rand.Reader, big.NewInt(int64(n.Count())))
-	return IncrementIP4By(n.IP(), uint32(z.Uint64()))
+	ip, _ := n.RandomIPFrom(nil)
+	return ip
+}
+
+// RandomIPFrom returns a random address from this Net4, drawing randomness
+// from r. This allows a deterministic reader to be substituted in tests, or
+// the entropy source to be chosen explicitly. If r is nil crypto/rand.Reader
+// is used, and if r fails its error is returned
+func (n Net4) RandomIPFrom(r io.Reader) (net.IP, error) {
+	if r == nil {
+		r = rand.Reader
+	}
+	z, err := rand.Int(r, big.NewInt(int64(n.Count())))
+	if err != nil {
+		return nil, err
+	}
+	if hmlen, _ := n.Hostmask.Size(); hmlen > 0 {
+		xip, _ := IncrementIP6WithinHostmask(n.IP().To16(), n.Hostmask, uint128.From64(z.Uint64()))
+		return ForceIP4(xip), nil
+	}
+	return IncrementIP4By(n.IP(), uint32(z.Uint64())), nil
+}
+
+// RangeString returns a human-readable description of the usable addresses
+// in n, giving the first and last usable address and the usable count, e.g.
+// "192.168.1.1 - 192.168.1.254 (254 hosts)". String() continues to return the
+// network in CIDR notation
+func (n Net4) RangeString() string {
+	if n.IP() == nil {
+		return "<nil>"
+	}
+	return formatRangeString(n.FirstAddress(), n.LastAddress(), strconv.FormatUint(uint64(n.Count()), 10))
+}
+
+// Remaining returns the number of usable addresses from ip, inclusive,
+// through the last usable address in the netblock, which is useful for
+// reporting progress while stepping through it. If ip is the network address
+// the result is the same as Count() and if it is the broadcast address it is
+// zero. If ip is not in the netblock, or if it has bits set inside the
+// hostmask, ErrAddressOutOfRange is returned
+func (n Net4) Remaining(ip net.IP) (uint32, error) {
+	if !n.Contains(ip) {
+		return 0, ErrAddressOutOfRange
+	}
+
+	if hmlen, _ := n.Hostmask.Size(); hmlen > 0 {
+		z, ok := hostmaskOrdinal(ip.To16(), n.Hostmask)
+		if !ok {
+			return 0, ErrAddressOutOfRange
+		}
+		last, _ := hostmaskOrdinal(n.LastAddress().To16(), n.Hostmask)
+		return uint32(last.Sub(z).Lo) + 1, nil
+	}
+
+	if first := n.FirstAddress(); CompareIPs(ip, first) < 0 {
+		ip = first
+	}
+	last := n.LastAddress()
+	if CompareIPs(ip, last) > 0 {
+		return 0, nil
+	}
+	return DeltaIP4(ip, last) + 1, nil
+}
+
+// ReverseAddressIter returns an iterator, with the same signature as
+// iter.Seq[net.IP], which yields the usable addresses of n in descending
+// order from LastAddress() down to FirstAddress(). It is the counterpart of
+// walking the network upward with NextIP() and lets allocators hand out
+// addresses from the top of a block without reversing a slice. RFC3021 /31
+// and /32 networks are handled as in Enumerate(), and a hostmask is honored
+func (n Net4) ReverseAddressIter() func(yield func(net.IP) bool) {
+	return func(yield func(net.IP) bool) {
+		if n.IP() == nil {
+			return
+		}
+		first := n.FirstAddress()
+		ip := n.LastAddress()
+		for {
+			if !yield(ip) || ip.Equal(first) {
+				return
+			}
+			// PreviousIP flags the network address of a /31 with
+			// ErrNetworkAddress, but it is usable there
+			var err error
+			if ip, err = n.PreviousIP(ip); err != nil && err != ErrNetworkAddress {
+				return
+			}
+		}
+	}
+}
+
+// ScanUnits returns an iterator yielding, in ascending order, every subnet of
+// n at unitMasklen. This is intended for chunking large networks into fixed
+// sized pieces without materializing the entire list as Subnet() would. If
+// unitMasklen is 0 it defaults to 24. If unitMasklen is shorter than the mask
+// of n, or longer than 32, ErrBadMaskLength is returned.
+//
+// The iterator has the same signature as iter.Seq[Net4] and so can be ranged
+// over directly in Go 1.23 and later
+func (n Net4) ScanUnits(unitMasklen int) (func(yield func(Net4) bool), error) {
+	if unitMasklen == 0 {
+		unitMasklen = 24
+	}
+	ones, all := n.Mask().Size()
+	if unitMasklen < ones || unitMasklen > all {
+		return nil, ErrBadMaskLength
+	}
+
+	return func(yield func(Net4) bool) {
+		step := uint64(1) << uint(all-unitMasklen)
+		end := uint64(IP4ToUint32(n.BroadcastAddress()))
+		for cur := uint64(IP4ToUint32(n.IP())); cur <= end; cur += step {
+			unit := NewNet4(Uint32ToIP4(uint32(cur)), unitMasklen)
+			unit.is4in6 = n.is4in6
+			if !yield(unit) {
+				return
+			}
+		}
+	}, nil
+}
+
+// ShuffledAddressIter returns an iterator, with the same signature as
+// iter.Seq[net.IP], which yields every usable address of n exactly once in a
+// pseudo-random order. Rather than buffering and shuffling the block it walks
+// the index space through a keyed Feistel permutation, so memory use is
+// constant no matter how large n is. The keys are read from r when iteration
+// begins, so each pass over the iterator produces a new order; if r is nil
+// crypto/rand.Reader is used, and if r cannot supply the key material the
+// iterator yields nothing. A hostmask is honored as in Enumerate()
+func (n Net4) ShuffledAddressIter(r io.Reader) func(yield func(net.IP) bool) {
+	return func(yield func(net.IP) bool) {
+		if n.IP() == nil {
+			return
+		}
+		if r == nil {
+			r = rand.Reader
+		}
+
+		p, err := newFeistelPermutation(r, uint64(n.Count()))
+		if err != nil {
+			return
+		}
+
+		hmlen, _ := n.Hostmask.Size()
+		first := IP4ToUint32(n.FirstAddress())
+		for i := uint64(0); i < p.size; i++ {
+			idx := p.permute(i)
+
+			var ip net.IP
+			if hmlen > 0 {
+				xip, err := IncrementIP6WithinHostmask(n.IP().To16(), n.Hostmask, uint128.From64(idx))
+				if err != nil {
+					return
+				}
+				ip = ForceIP4(xip)
+			} else {
+				ip = Uint32ToIP4(first + uint32(idx))
+			}
+			if !yield(ip) {
+				return
+			}
+		}
+	}
+}
+
+// SplitAt divides the whole of n, from network address through broadcast
+// address, into consecutive ranges with each of points beginning a new range.
+// The ranges are returned in ascending order regardless of the order of
+// points; duplicate points, and a point equal to the network address, do not
+// produce an extra range. If any point falls outside of n ErrAddressOutOfRange
+// is returned. The resulting ranges need not be CIDR-aligned, use
+// Range4.CIDRs() to convert them into networks
+func (n Net4) SplitAt(points []net.IP) ([]Range4, error) {
+	if n.IP() == nil {
+		return nil, ErrNoValidRange
+	}
+
+	start := IP4ToUint32(n.IP())
+	end := IP4ToUint32(n.BroadcastAddress())
+
+	cuts := make([]uint32, 0, len(points))
+	for _, p := range points {
+		if !n.Contains(p) {
+			return nil, ErrAddressOutOfRange
+		}
+		cuts = append(cuts, IP4ToUint32(ForceIP4(p)))
+	}
+	sort.Slice(cuts, func(i, j int) bool {
+		return cuts[i] < cuts[j]
+	})
+
+	ranges := []Range4{}
+	cur := start
+	for _, c := range cuts {
+		if c == cur {
+			continue
+		}
+		ranges = append(ranges, Range4{first: Uint32ToIP4(cur), last: Uint32ToIP4(c - 1)})
+		cur = c
+	}
+	ranges = append(ranges, Range4{first: Uint32ToIP4(cur), last: Uint32ToIP4(end)})
+	return ranges, nil
 }
 
 // String returns the CIDR notation of the enclosed network e.g. 192.168.0.1/24
@@ -266,7 +1208,8 @@ func (n Net4) String() string {
 // Subnet takes a CIDR mask-size as an argument and carves the current Net
 // object into subnets of that size, returning them as a []Net. The mask
 // provided must be a larger-integer than the current mask. If set to 0 Subnet
-// will carve the network in half
+// will carve the network in half. The subnets are always returned in
+// ascending order as defined by CompareNets
 func (n Net4) Subnet(masklen int) ([]Net4, error) {
 	ones, all := n.Mask().Size()
 	if masklen == 0 {
@@ -282,11 +1225,105 @@ func (n Net4) Subnet(masklen int) ([]Net4, error) {
 
 	for CompareIPs(netlist[len(netlist)-1].BroadcastAddress(), n.BroadcastAddress()) == -1 {
 		ng := net.IPNet{IP: NextIP(netlist[len(netlist)-1].BroadcastAddress()), Mask: mask}
-		netlist = append(netlist, Net4{ng, n.is4in6})
+		netlist = append(netlist, Net4{IPNet: ng, is4in6: n.is4in6})
 	}
 	return netlist, nil
 }
 
+// SubnetWalk calls fn for each subnet of n at masklen, in ascending order,
+// along with that subnet's zero-based index within n, as SubnetIndex() would
+// report it. Subnets are generated one at a time rather than all at once as
+// with Subnet(). If fn returns an error the walk stops and that error is
+// returned. If masklen is shorter than n's mask or longer than 32
+// ErrBadMaskLength is returned without fn being called
+func (n Net4) SubnetWalk(masklen int, fn func(index uint32, sub Net4) error) error {
+	ones, all := n.Mask().Size()
+	if n.IP() == nil || ones > masklen || masklen > all {
+		return ErrBadMaskLength
+	}
+
+	mask := net.CIDRMask(masklen, all)
+	step := uint64(1) << uint(all-masklen)
+	base := uint64(IP4ToUint32(n.IP()))
+	count := uint64(1) << uint(masklen-ones)
+
+	for i := uint64(0); i < count; i++ {
+		ng := net.IPNet{IP: Uint32ToIP4(uint32(base + i*step)), Mask: mask}
+		if err := fn(uint32(i), Net4{IPNet: ng, is4in6: n.is4in6}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubnetIndex returns the zero-based position of n among the subnets of
+// parent that share n's mask length, so 192.168.1.128/26 is subnet 2 of
+// 192.168.1.0/24. If n's mask is shorter than parent's ErrBadMaskLength is
+// returned, and if parent does not contain n ErrAddressOutOfRange is returned
+func (n Net4) SubnetIndex(parent Net4) (uint32, error) {
+	ones, all := n.Mask().Size()
+	pones, _ := parent.Mask().Size()
+	if n.IP() == nil || parent.IP() == nil || ones < pones {
+		return 0, ErrBadMaskLength
+	}
+	if !parent.ContainsNet(n) {
+		return 0, ErrAddressOutOfRange
+	}
+	delta := IP4ToUint32(n.IP()) - IP4ToUint32(parent.IP())
+	return uint32(uint64(delta) >> uint(all-ones)), nil
+}
+
+// SubnetByBits returns the subnet of n addressed by packing segments, in
+// order, into the bits immediately following n's mask. This expresses
+// structured addressing plans directly: with segments of {4, 2} and {4, 5},
+// a region field followed by an availability zone field, 10.0.0.0/16 yields
+// 10.0.37.0/24. The mask of the result is n's mask plus the total width of
+// the segments, and no segments at all returns n. If a segment has a negative
+// width, or the segments need more bits than n has, ErrBadMaskLength is
+// returned; if a value does not fit in its segment ErrAddressOutOfRange is
+// returned
+func (n Net4) SubnetByBits(segments []SubnetBits) (Net4, error) {
+	ones, all := n.Mask().Size()
+	masklen := ones
+	for _, seg := range segments {
+		if seg.Bits < 0 || masklen+seg.Bits > all {
+			return Net4{}, ErrBadMaskLength
+		}
+		if uint64(seg.Value) >= uint64(1)<<uint(seg.Bits) {
+			return Net4{}, ErrAddressOutOfRange
+		}
+		masklen += seg.Bits
+	}
+
+	addr := IP4ToUint32(n.IP())
+	pos := ones
+	for _, seg := range segments {
+		pos += seg.Bits
+		if seg.Bits > 0 {
+			addr |= seg.Value << uint(all-pos)
+		}
+	}
+	return NewNet4(Uint32ToIP4(addr), masklen), nil
+}
+
+// SubnetToMaxSize carves n into equal-sized subnets, halving it until each
+// subnet has a Count() of no more than maxHosts usable addresses, and returns
+// the full set in ascending order. For example a maxHosts of 50 applied to a
+// /24 returns eight /27s, since a /26 has 62 usable addresses. If n already
+// fits it is returned on its own. If maxHosts is less than 1
+// ErrBadMaskLength is returned
+func (n Net4) SubnetToMaxSize(maxHosts int) ([]Net4, error) {
+	if maxHosts < 1 {
+		return nil, ErrBadMaskLength
+	}
+
+	masklen, all := n.Mask().Size()
+	for masklen < all && uint64(NewNet4(n.IP(), masklen).Count()) > uint64(maxHosts) {
+		masklen++
+	}
+	return n.Subnet(masklen)
+}
+
 // Supernet takes a CIDR mask-size as an argument and returns a Net object
 // containing the supernet of the current Net at the requested mask length.
 // The mask provided must be a smaller-integer than the current mask. If set
@@ -307,7 +1344,74 @@ func (n Net4) Supernet(masklen int) (Net4, error) {
 
 	mask := net.CIDRMask(masklen, all)
 	ng := net.IPNet{IP: n.IP().Mask(mask), Mask: mask}
-	return Net4{ng, n.is4in6}, nil
+	return Net4{IPNet: ng, is4in6: n.is4in6}, nil
+}
+
+// SupernetUpTo behaves like Supernet(0), returning the next-largest network
+// containing n, except that it will never grow beyond limit: if n is already
+// the same size as limit then limit itself is returned, so repeated calls stop
+// at the boundary rather than leaking an aggregate outside of it. If limit
+// does not contain n ErrAddressOutOfRange is returned
+//
+// Examples, with a limit of 192.168.0.0/22:
+// Net{192.168.1.0/24}.SupernetUpTo(limit) -> Net{192.168.0.0/23}
+// Net{192.168.0.0/22}.SupernetUpTo(limit) -> Net{192.168.0.0/22}
+func (n Net4) SupernetUpTo(limit Net4) (Net4, error) {
+	if n.IP() == nil || limit.IP() == nil || !limit.ContainsNet(n) {
+		return Net4{}, ErrAddressOutOfRange
+	}
+
+	ones, _ := n.Mask().Size()
+	limitOnes, _ := limit.Mask().Size()
+	if ones == limitOnes {
+		return limit, nil
+	}
+	return n.Supernet(0)
+}
+
+// TableRow returns n as a single line of fixed-width columns: the network in
+// CIDR notation, the first and last usable addresses and the usable count,
+// with the count right-aligned. The widths fit any v4 network, so rows for
+// different networks line up when printed one after another. See NetsTable()
+// for a complete table with a header
+func (n Net4) TableRow() string {
+	c := tableCells(n)
+	return fmt.Sprintf("%-18s  %-15s  %-15s  %10s", c[0], c[1], c[2], c[3])
+}
+
+// ToIPNet returns a copy of n as a *net.IPNet in 4-byte form, suitable for
+// handing to the standard library or other packages. It returns nil if n is
+// empty
+func (n Net4) ToIPNet() *net.IPNet {
+	if n.IP() == nil {
+		return nil
+	}
+	mask := make(net.IPMask, net.IPv4len)
+	copy(mask, n.Mask()[len(n.Mask())-net.IPv4len:])
+	return &net.IPNet{IP: CopyIP(ForceIP4(n.IP())), Mask: mask}
+}
+
+// UsableCount implements the Net interface, returning Count() as a *big.Int.
+// As with Count() a /31 is considered to have 2 usable addresses per RFC3021
+// and a /32 to have 1
+func (n Net4) UsableCount() *big.Int {
+	if n.IP() == nil {
+		return big.NewInt(0)
+	}
+	return new(big.Int).SetUint64(uint64(n.Count()))
+}
+
+// Utilization returns the fraction of n's address space, from 0.0 to 1.0,
+// which is covered by the networks in used. The whole netblock is considered,
+// including the network and broadcast addresses, and used is aggregated and
+// clipped to n first so that overlapping networks, or networks extending
+// beyond n, do not inflate the result
+func (n Net4) Utilization(used []Net4) float64 {
+	nets := make([]Net, len(used))
+	for i, u := range used {
+		nets[i] = u
+	}
+	return utilization(n, nets)
 }
 
 // Version returns the version of IP for the enclosed netblock, 4 in this case
@@ -324,6 +1428,121 @@ func (n Net4) Wildcard() net.IPMask {
 	return wc
 }
 
+// WouldExitBlock returns true if incrementing ip by count, as IncrementIPBy()
+// would, produces an address outside of the netblock. It also returns true if
+// ip is not in the netblock to begin with. The broadcast address is
+// considered part of the block
+func (n Net4) WouldExitBlock(ip net.IP, count uint32) bool {
+	if !n.Contains(ip) {
+		return true
+	}
+	final, _ := n.finalAddress()
+	return uint64(IP4ToUint32(ip))+uint64(count) > uint64(IP4ToUint32(final))
+}
+
+// enumerate implements Enumerate(), it is the fast path of EnumerateOpts()
+func (n Net4) enumerate(size, offset int) []net.IP {
+	if n.IP() == nil {
+		return nil
+	}
+
+	if hmlen, _ := n.Hostmask.Size(); hmlen > 0 {
+		return n.enumerateWithinHostmask(size, offset)
+	}
+
+	count := int(n.Count())
+
+	// offset exceeds total, return an empty array
+	if offset > count {
+		return []net.IP{}
+	}
+
+	// size is greater than the number of addresses that can be returned,
+	// adjust the size of the slice but keep going
+	if size > (count-offset) || size == 0 {
+		size = count - offset
+	}
+
+	// Handle edge-case mask sizes
+	if count == 1 { // Count() returns 1 if host-bits == 0
+		return []net.IP{CopyIP(n.IPNet.IP)}
+	}
+
+	addrs := make([]net.IP, size)
+
+	netu := IP4ToUint32(n.FirstAddress())
+	netu += uint32(offset)
+
+	fip := Uint32ToIP4(netu)
+
+	limit := 65535
+	pos := 0
+	wg := sync.WaitGroup{}
+	for pos < size {
+		incr := limit
+		if limit > (size - pos) {
+			incr = size - pos
+		}
+		wg.Add(1)
+		go func(fip net.IP, pos, count int) {
+			defer wg.Done()
+			addrs[pos] = IncrementIP4By(fip, uint32(pos))
+			for i := 1; i < count; i++ {
+				pos++
+				addrs[pos] = NextIP(addrs[pos-1])
+			}
+		}(fip, pos, incr)
+		pos = pos + incr
+	}
+	wg.Wait()
+	return addrs
+}
+
+// enumerateWithinHostmask is the Enumerate() implementation for a Net4 with a
+// hostmask, where addresses cannot simply be counted off one at a time
+func (n Net4) enumerateWithinHostmask(size, offset int) []net.IP {
+	count := int(n.Count())
+	if offset >= count {
+		return []net.IP{}
+	}
+	if size > (count-offset) || size == 0 {
+		size = count - offset
+	}
+
+	addrs := make([]net.IP, 0, size)
+	xip, err := IncrementIP6WithinHostmask(n.IP().To16(), n.Hostmask, uint128.From64(uint64(offset)))
+	for err == nil && len(addrs) < size {
+		addrs = append(addrs, ForceIP4(xip))
+		xip, err = NextIP6WithinHostmask(xip, n.Hostmask)
+	}
+	return addrs
+}
+
+// firstFree returns the lowest usable address in n that is not a member of
+// used. Without a hostmask the usable span is reduced by used as a set, so
+// large allocated blocks are skipped in one step rather than address by
+// address
+func (n Net4) firstFree(used *IPSet) (net.IP, bool) {
+	if n.IP() == nil {
+		return nil, false
+	}
+	if hmlen, _ := n.Hostmask.Size(); hmlen > 0 {
+		return n.FindFirst(func(ip net.IP) bool {
+			return !used.Contains(ip)
+		})
+	}
+
+	r, err := NewRange4(n.FirstAddress(), n.LastAddress())
+	if err != nil {
+		return nil, false
+	}
+	free := r.ToIPSet().Difference(used).Ranges()
+	if len(free) == 0 {
+		return nil, false
+	}
+	return free[0].FirstAddress(), true
+}
+
 // finalAddress returns the last address in the network. It is private
 // because both LastAddress() and BroadcastAddress() rely on it, and both use
 // it differently. It returns the last address in the block as well as the
@@ -339,3 +1558,52 @@ func (n Net4) finalAddress() (net.IP, int) {
 	}
 	return xip, ones
 }
+
+// masklenForHosts4 returns the longest v4 mask whose Count() is at least
+// hosts, or -1 if no network is large enough
+func masklenForHosts4(hosts int) int {
+	for masklen := 32; masklen >= 0; masklen-- {
+		exp := uint(32 - masklen)
+		usable := (uint64(1) << exp) - 2
+		if exp == 0 {
+			usable = 1 // /32
+		}
+		if exp == 1 {
+			usable = 2 // RFC3021 /31
+		}
+		if usable >= uint64(hosts) {
+			return masklen
+		}
+	}
+	return -1
+}
+
+// parseDecimal converts s to an int between 0 and limit, failing if s is
+// empty, contains anything other than the digits 0-9 or has a leading zero,
+// which some tools would read as octal
+func parseDecimal(s string, limit int) (int, error) {
+	if len(s) == 0 || strings.Trim(s, "0123456789") != "" {
+		return 0, strconv.ErrSyntax
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, strconv.ErrSyntax
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if v > limit {
+		return 0, strconv.ErrRange
+	}
+	return v, nil
+}
+
+// formatRangeString produces the output of RangeString() for both Net4 and
+// Net6
+func formatRangeString(first, last net.IP, count string) string {
+	noun := "hosts"
+	if count == "1" {
+		noun = "host"
+	}
+	return first.String() + " - " + last.String() + " (" + count + " " + noun + ")"
+}
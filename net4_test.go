@@ -1,6 +1,8 @@
 package iplib
 
 import (
+	"bytes"
+	"errors"
 	"net"
 	"sort"
 	"testing"
@@ -34,6 +36,107 @@ func TestNewNet4(t *testing.T) {
 	}
 }
 
+var NewNet4WithHostmaskTests = []struct {
+	addr    net.IP
+	masklen int
+	hmlen   int
+	first   net.IP
+	last    net.IP
+	count   uint32
+	err     error
+}{
+	{net.ParseIP("192.168.0.0"), 24, 0, net.ParseIP("192.168.0.1"), net.ParseIP("192.168.0.254"), 254, nil},
+	{net.ParseIP("192.168.0.0"), 24, 4, net.ParseIP("192.168.0.0"), net.ParseIP("192.168.0.15"), 16, nil},
+	{net.ParseIP("192.168.0.0"), 24, 8, net.ParseIP("192.168.0.0"), net.ParseIP("192.168.0.0"), 1, nil},
+	{net.ParseIP("192.168.0.0"), 16, 8, net.ParseIP("192.168.0.0"), net.ParseIP("192.168.255.0"), 256, nil},
+	{net.ParseIP("192.168.0.0"), 16, 10, net.ParseIP("192.168.0.0"), net.ParseIP("192.168.63.0"), 64, nil},
+	{net.ParseIP("192.168.0.0"), 24, 9, nil, nil, 0, ErrBadMaskLength},
+	{net.ParseIP("192.168.0.0"), 24, -1, nil, nil, 0, ErrBadMaskLength},
+	{net.ParseIP("192.168.0.0"), 33, 0, nil, nil, 0, ErrBadMaskLength},
+	{net.ParseIP("2001:db8::"), 24, 4, nil, nil, 0, ErrNoValidRange},
+}
+
+func TestNewNet4WithHostmask(t *testing.T) {
+	for i, tt := range NewNet4WithHostmaskTests {
+		ipn, err := NewNet4WithHostmask(tt.addr, tt.masklen, tt.hmlen)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if !ipn.FirstAddress().Equal(tt.first) {
+			t.Errorf("[%d] first address want %s got %s", i, tt.first, ipn.FirstAddress())
+		}
+		if !ipn.LastAddress().Equal(tt.last) {
+			t.Errorf("[%d] last address want %s got %s", i, tt.last, ipn.LastAddress())
+		}
+		if ipn.Count() != tt.count {
+			t.Errorf("[%d] count want %d got %d", i, tt.count, ipn.Count())
+		}
+
+		addrs := ipn.Enumerate(0, 0)
+		if uint32(len(addrs)) != tt.count {
+			t.Fatalf("[%d] enumerate want %d addresses got %d", i, tt.count, len(addrs))
+		}
+		if !addrs[0].Equal(tt.first) || !addrs[len(addrs)-1].Equal(tt.last) {
+			t.Errorf("[%d] enumerate want %s-%s got %s-%s", i, tt.first, tt.last, addrs[0], addrs[len(addrs)-1])
+		}
+
+		// walking with NextIP and PreviousIP visits the same addresses
+		ip := addrs[0]
+		for j := 1; j < len(addrs); j++ {
+			ip, err = ipn.NextIP(ip)
+			if err != nil && err != ErrBroadcastAddress || !ip.Equal(addrs[j]) {
+				t.Fatalf("[%d] NextIP step %d want %s got %s, '%v'", i, j, addrs[j], ip, err)
+			}
+		}
+		for j := len(addrs) - 2; j >= 0; j-- {
+			ip, err = ipn.PreviousIP(ip)
+			if err != nil && err != ErrNetworkAddress || !ip.Equal(addrs[j]) {
+				t.Fatalf("[%d] PreviousIP step %d want %s got %s, '%v'", i, j, addrs[j], ip, err)
+			}
+		}
+
+		if tt.hmlen > 0 {
+			if _, err := ipn.NextIP(tt.last); err != ErrAddressOutOfRange {
+				t.Errorf("[%d] NextIP past the last address want ErrAddressOutOfRange got '%v'", i, err)
+			}
+			if _, err := ipn.PreviousIP(tt.first); err != ErrAddressOutOfRange {
+				t.Errorf("[%d] PreviousIP before the first address want ErrAddressOutOfRange got '%v'", i, err)
+			}
+			if ip := ipn.RandomIP(); !ipn.Contains(ip) {
+				t.Errorf("[%d] RandomIP %s is outside the network", i, ip)
+			}
+		}
+	}
+}
+
+func TestNet4_EnumerateWithHostmaskOffset(t *testing.T) {
+	ipn, _ := NewNet4WithHostmask(net.ParseIP("10.0.0.0"), 16, 8)
+	addrs := ipn.Enumerate(3, 254)
+	want := []string{"10.0.254.0", "10.0.255.0"}
+	if len(addrs) != len(want) {
+		t.Fatalf("want %v got %v", want, addrs)
+	}
+	for i := range want {
+		if addrs[i].String() != want[i] {
+			t.Errorf("[%d] want %s got %s", i, want[i], addrs[i])
+		}
+	}
+
+	_, seq := ipn.EnumerateOrIter(0)
+	n := 0
+	seq(func(ip net.IP) bool {
+		n++
+		return true
+	})
+	if n != 256 {
+		t.Errorf("iterator want 256 addresses got %d", n)
+	}
+}
+
 var Net4FromStrTests = []struct {
 	ins     string
 	outs    string
@@ -58,6 +161,59 @@ func TestNet4FromStr(t *testing.T) {
 	}
 }
 
+var Net4FromShorthandTests = []struct {
+	ins   string
+	outs  string
+	err   bool
+	token string
+}{
+	{"10/8", "10.0.0.0/8", false, ""},
+	{"192.168/16", "192.168.0.0/16", false, ""},
+	{"172.16/12", "172.16.0.0/12", false, ""},
+	{"192.168.1/24", "192.168.1.0/24", false, ""},
+	{"192.168.1.0/24", "192.168.1.0/24", false, ""},
+	{"192.168.1.1/32", "192.168.1.1/32", false, ""},
+	{"0/0", "0.0.0.0/0", false, ""},
+	{"10.1/8", "", true, "10.1"},
+	{"192.168.1.5/24", "", true, "192.168.1.5"},
+	{"10", "", true, "10"},
+	{"10/", "", true, ""},
+	{"/8", "", true, ""},
+	{"10/33", "", true, "33"},
+	{"10/-1", "", true, "-1"},
+	{"10..1/24", "", true, ""},
+	{"256/8", "", true, "256"},
+	{"10.0.0.0.0/8", "", true, "10.0.0.0.0"},
+	{"10/8/8", "", true, "8/8"},
+	{"10.+1/16", "", true, "+1"},
+	{"2001:db8::/32", "", true, "2001:db8::"},
+	{"010/8", "", true, "010"},
+	{"10.010/16", "", true, "010"},
+	{"10/08", "", true, "08"},
+	{"00/0", "", true, "00"},
+}
+
+func TestNet4FromShorthand(t *testing.T) {
+	for i, tt := range Net4FromShorthandTests {
+		ipn, err := Net4FromShorthand(tt.ins)
+		if (err != nil) != tt.err {
+			t.Errorf("[%d] %s: want error %t got '%v'", i, tt.ins, tt.err, err)
+			continue
+		}
+		if !tt.err && ipn.String() != tt.outs {
+			t.Errorf("[%d] want %s got %s", i, tt.outs, ipn.String())
+		}
+		if tt.err {
+			perr, ok := err.(*ParseError)
+			if !ok {
+				t.Errorf("[%d] %s: want *ParseError got %T", i, tt.ins, err)
+			} else if perr.Token != tt.token {
+				t.Errorf("[%d] %s: want token '%s' got '%s'", i, tt.ins, tt.token, perr.Token)
+			}
+		}
+	}
+}
+
 var Net4Tests = []struct {
 	ip        net.IP
 	network   net.IP
@@ -159,6 +315,15 @@ func TestNet4_Version(t *testing.T) {
 	}
 }
 
+func TestNet4_Family(t *testing.T) {
+	for i, tt := range Net4Tests {
+		ipn := NewNet4(tt.ip, tt.masklen)
+		if ipn.Family() != "IPv4" {
+			t.Errorf("[%d] want family IPv4, got %s", i, ipn.Family())
+		}
+	}
+}
+
 func TestNet4_Count(t *testing.T) {
 	for i, tt := range Net4Tests {
 		ipn := NewNet4(tt.ip, tt.masklen)
@@ -195,6 +360,26 @@ func TestNet4_LastAddress(t *testing.T) {
 	}
 }
 
+var midpoint4Tests = []struct {
+	in       Net4
+	midpoint net.IP
+}{
+	{Net4FromStr("192.168.1.0/24"), net.ParseIP("192.168.1.128")},
+	{Net4FromStr("10.0.0.0/8"), net.ParseIP("10.128.0.0")},
+	{Net4FromStr("192.168.1.4/30"), net.ParseIP("192.168.1.6")},
+	{Net4FromStr("192.168.1.4/31"), net.ParseIP("192.168.1.5")},
+	{Net4FromStr("192.168.1.4/32"), net.ParseIP("192.168.1.4")},
+	{Net4FromStr("0.0.0.0/0"), net.ParseIP("128.0.0.0")},
+}
+
+func TestNet4_Midpoint(t *testing.T) {
+	for i, tt := range midpoint4Tests {
+		if addr := tt.in.Midpoint(); !tt.midpoint.Equal(addr) {
+			t.Errorf("[%d] want %s got %s", i, tt.midpoint, addr)
+		}
+	}
+}
+
 func TestNet4_NetworkAddress(t *testing.T) {
 	for i, tt := range Net4Tests {
 		ipn := NewNet4(tt.ip, tt.masklen)
@@ -251,6 +436,87 @@ func TestNet4_Enumerate(t *testing.T) {
 	}
 }
 
+var enumerateStride4Tests = []struct {
+	inaddr string
+	stride int
+	offset int
+	size   int
+	total  int
+	first  string
+	last   string
+}{
+	{"10.1.0.0/16", 256, 0, 0, 256, "10.1.0.1", "10.1.255.1"},
+	{"10.1.0.0/16", 256, 255, 0, 255, "10.1.1.0", "10.1.255.0"},
+	{"192.168.0.0/24", 4, 0, 0, 64, "192.168.0.1", "192.168.0.253"},
+	{"192.168.0.0/24", 4, 3, 0, 63, "192.168.0.4", "192.168.0.252"},
+	{"192.168.0.0/24", 4, 0, 10, 10, "192.168.0.1", "192.168.0.37"},
+	{"192.168.0.0/24", 1, 0, 0, 254, "192.168.0.1", "192.168.0.254"},
+	{"192.168.0.0/24", 1000, 0, 0, 1, "192.168.0.1", "192.168.0.1"},
+	{"192.168.0.0/24", 1, 254, 0, 0, "", ""},
+	{"192.168.0.0/31", 1, 0, 0, 2, "192.168.0.0", "192.168.0.1"},
+	{"192.168.0.0/24", 0, 0, 0, -1, "", ""},
+	{"192.168.0.0/24", 1, -1, 0, -1, "", ""},
+}
+
+func TestNet4_EnumerateStride(t *testing.T) {
+	for i, tt := range enumerateStride4Tests {
+		addrs := Net4FromStr(tt.inaddr).EnumerateStride(tt.stride, tt.offset, tt.size)
+		if tt.total == -1 {
+			if addrs != nil {
+				t.Errorf("[%d] want nil got %v", i, addrs)
+			}
+			continue
+		}
+		if len(addrs) != tt.total {
+			t.Errorf("[%d] want %d addresses got %d", i, tt.total, len(addrs))
+			continue
+		}
+		if tt.total > 0 && (addrs[0].String() != tt.first || addrs[len(addrs)-1].String() != tt.last) {
+			t.Errorf("[%d] want %s-%s got %s-%s", i, tt.first, tt.last, addrs[0], addrs[len(addrs)-1])
+		}
+	}
+
+	// with a hostmask the stride counts enumerable addresses
+	ipn, _ := NewNet4WithHostmask(net.ParseIP("10.0.0.0"), 16, 8)
+	addrs := ipn.EnumerateStride(64, 0, 0)
+	want := []string{"10.0.0.0", "10.0.64.0", "10.0.128.0", "10.0.192.0"}
+	if len(addrs) != len(want) {
+		t.Fatalf("want %v got %v", want, addrs)
+	}
+	for i := range want {
+		if addrs[i].String() != want[i] {
+			t.Errorf("[%d] want %s got %s", i, want[i], addrs[i])
+		}
+	}
+}
+
+func TestNet4_EnumerateStrings(t *testing.T) {
+	for i, tt := range enumerate4Tests {
+		ipn4 := Net4FromStr(tt.incidr)
+		addrlist := ipn4.Enumerate(0, 0)
+		strs := ipn4.EnumerateStrings(0, 0)
+		iterStrs := []string{}
+		ipn4.EnumerateStringsIter()(func(s string) bool {
+			iterStrs = append(iterStrs, s)
+			return true
+		})
+		if len(strs) != tt.total || len(iterStrs) != tt.total {
+			t.Errorf("[%d] want size %d got %d and %d", i, tt.total, len(strs), len(iterStrs))
+			continue
+		}
+		for ii, a := range addrlist {
+			if strs[ii] != a.String() || iterStrs[ii] != a.String() {
+				t.Errorf("[%d] address %d: want %s got %s and %s", i, ii, a, strs[ii], iterStrs[ii])
+				break
+			}
+		}
+	}
+
+	if strs := Net4FromStr("192.168.0.0/24").EnumerateStrings(2, 10); len(strs) != 2 || strs[0] != "192.168.0.11" {
+		t.Errorf("want [192.168.0.11 192.168.0.12] got %v", strs)
+	}
+}
+
 var enumerate4VariableTests = []struct {
 	offset int
 	size   int
@@ -324,6 +590,324 @@ func TestNet4_EnumerateRFC3021(t *testing.T) {
 	}
 }
 
+var deltaNetsTests = []struct {
+	a       string
+	b       string
+	masklen int
+	delta   uint32
+	err     error
+}{
+	{"10.0.0.0/24", "10.0.3.0/24", 24, 3, nil},
+	{"10.0.3.0/24", "10.0.0.0/24", 24, 3, nil},
+	{"10.0.0.0/24", "10.0.3.0/24", 25, 6, nil},
+	{"10.0.0.0/24", "10.0.0.0/24", 24, 0, nil},
+	{"10.0.0.0/16", "10.1.0.0/24", 24, 256, nil},
+	{"0.0.0.0/32", "255.255.255.255/32", 32, 4294967295, nil},
+	{"10.0.0.0/24", "10.0.3.0/24", 23, 0, ErrBadMaskLength},
+	{"10.0.0.0/24", "10.0.3.0/26", 25, 0, ErrBadMaskLength},
+	{"10.0.0.0/24", "10.0.3.0/24", 33, 0, ErrBadMaskLength},
+}
+
+func TestDeltaNets(t *testing.T) {
+	for i, tt := range deltaNetsTests {
+		delta, err := DeltaNets(Net4FromStr(tt.a), Net4FromStr(tt.b), tt.masklen)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+		}
+		if delta != tt.delta {
+			t.Errorf("[%d] want %d got %d", i, tt.delta, delta)
+		}
+	}
+
+	if _, err := DeltaNets(Net4{}, Net4FromStr("10.0.0.0/24"), 24); err != ErrNoValidRange {
+		t.Errorf("want ErrNoValidRange for empty network, got '%v'", err)
+	}
+}
+
+var freeRangesTests = []struct {
+	inaddr string
+	used   []string
+	free   []string
+}{
+	{
+		"192.168.0.0/24", []string{},
+		[]string{"192.168.0.0-192.168.0.255"},
+	},
+	{
+		"192.168.0.0/24", []string{"192.168.0.0/24"},
+		[]string{},
+	},
+	{
+		"192.168.0.0/24", []string{"192.168.0.0/16"},
+		[]string{},
+	},
+	{
+		"192.168.0.0/24", []string{"192.168.0.64/26", "192.168.0.16/28"},
+		[]string{"192.168.0.0-192.168.0.15", "192.168.0.32-192.168.0.63", "192.168.0.128-192.168.0.255"},
+	},
+	{
+		"192.168.0.0/24", []string{"192.168.0.0/25", "192.168.0.0/26", "192.168.0.128/32", "192.168.0.255/32"},
+		[]string{"192.168.0.129-192.168.0.254"},
+	},
+	{
+		"192.168.0.0/24", []string{"10.0.0.0/8", "192.168.1.0/24"},
+		[]string{"192.168.0.0-192.168.0.255"},
+	},
+	{
+		"255.255.255.0/24", []string{"255.255.255.0/25"},
+		[]string{"255.255.255.128-255.255.255.255"},
+	},
+	{
+		"0.0.0.0/0", []string{"128.0.0.0/1"},
+		[]string{"0.0.0.0-127.255.255.255"},
+	},
+}
+
+func TestNet4_FreeRanges(t *testing.T) {
+	for i, tt := range freeRangesTests {
+		used := []Net4{}
+		for _, u := range tt.used {
+			used = append(used, Net4FromStr(u))
+		}
+		free := Net4FromStr(tt.inaddr).FreeRanges(used)
+		if len(free) != len(tt.free) {
+			t.Errorf("[%d] want %v got %v", i, tt.free, free)
+			continue
+		}
+		for j := range free {
+			if free[j].String() != tt.free[j] {
+				t.Errorf("[%d] range %d: want %s got %s", i, j, tt.free[j], free[j])
+			}
+		}
+	}
+}
+
+var supernetUpTo4Tests = []struct {
+	inaddr string
+	limit  string
+	out    string
+	err    error
+}{
+	{"192.168.1.0/24", "192.168.0.0/22", "192.168.0.0/23", nil},
+	{"192.168.2.0/23", "192.168.0.0/22", "192.168.0.0/22", nil},
+	{"192.168.0.0/22", "192.168.0.0/22", "192.168.0.0/22", nil},
+	{"192.168.1.7/32", "192.168.1.0/24", "192.168.1.6/31", nil},
+	{"192.168.4.0/24", "192.168.0.0/22", "", ErrAddressOutOfRange},
+	{"192.168.0.0/21", "192.168.0.0/22", "", ErrAddressOutOfRange},
+}
+
+func TestNet4_SupernetUpTo(t *testing.T) {
+	for i, tt := range supernetUpTo4Tests {
+		out, err := Net4FromStr(tt.inaddr).SupernetUpTo(Net4FromStr(tt.limit))
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err == nil && out.String() != tt.out {
+			t.Errorf("[%d] want %s got %s", i, tt.out, out)
+		}
+	}
+
+	// walking up from a host route stops at the limit
+	limit := Net4FromStr("10.0.0.0/16")
+	n := Net4FromStr("10.0.37.5/32")
+	for i := 0; i < 32; i++ {
+		n, _ = n.SupernetUpTo(limit)
+	}
+	if n.String() != limit.String() {
+		t.Errorf("want %s got %s", limit, n)
+	}
+}
+
+var enumerateOrIter4Tests = []struct {
+	inaddr   string
+	maxSlice int
+	slice    bool
+	count    int
+}{
+	{"192.168.1.0/24", 254, true, 254},
+	{"192.168.1.0/24", 253, false, 254},
+	{"192.168.1.0/24", 0, false, 254},
+	{"192.168.1.0/24", -1, false, 254},
+	{"192.168.1.0/31", 2, true, 2},
+	{"192.168.1.1/32", 1, true, 1},
+	{"192.168.1.1/32", 0, false, 1},
+	{"10.0.0.0/20", 1024, false, 4094},
+}
+
+func TestNet4_EnumerateOrIter(t *testing.T) {
+	for i, tt := range enumerateOrIter4Tests {
+		ipn := Net4FromStr(tt.inaddr)
+		want := ipn.Enumerate(0, 0)
+		addrs, seq := ipn.EnumerateOrIter(tt.maxSlice)
+		if tt.slice {
+			if seq != nil {
+				t.Errorf("[%d] want nil iterator", i)
+			}
+		} else {
+			if addrs != nil {
+				t.Errorf("[%d] want nil slice got %d addresses", i, len(addrs))
+			}
+			if seq == nil {
+				t.Fatalf("[%d] want iterator got nil", i)
+			}
+			seq(func(ip net.IP) bool {
+				addrs = append(addrs, ip)
+				return true
+			})
+		}
+		if len(addrs) != tt.count {
+			t.Errorf("[%d] want %d addresses got %d", i, tt.count, len(addrs))
+			continue
+		}
+		for j := range want {
+			if !want[j].Equal(addrs[j]) {
+				t.Errorf("[%d] address %d: want %s got %s", i, j, want[j], addrs[j])
+				break
+			}
+		}
+	}
+}
+
+func TestNet4_EnumerateOrIterStop(t *testing.T) {
+	_, seq := Net4FromStr("10.0.0.0/8").EnumerateOrIter(0)
+	n := 0
+	seq(func(ip net.IP) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("want 3 addresses before stopping got %d", n)
+	}
+}
+
+var adjacentAddress4Tests = []struct {
+	inaddr string
+	next   net.IP
+	prev   net.IP
+}{
+	{"192.168.0.0/24", net.ParseIP("192.168.1.0"), net.ParseIP("192.167.255.255")},
+	{"192.168.1.0/24", net.ParseIP("192.168.2.0"), net.ParseIP("192.168.0.255")},
+	{"10.0.0.4/30", net.ParseIP("10.0.0.8"), net.ParseIP("10.0.0.3")},
+	{"10.0.0.1/32", net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.0")},
+	{"255.255.255.0/24", net.ParseIP("255.255.255.255"), net.ParseIP("255.255.254.255")},
+	{"0.0.0.0/8", net.ParseIP("1.0.0.0"), net.ParseIP("0.0.0.0")},
+	{"0.0.0.0/0", net.ParseIP("255.255.255.255"), net.ParseIP("0.0.0.0")},
+}
+
+func TestNet4_NextAddressPreviousAddress(t *testing.T) {
+	for i, tt := range adjacentAddress4Tests {
+		ipn := Net4FromStr(tt.inaddr)
+		if addr := ipn.NextAddress(); !addr.Equal(tt.next) {
+			t.Errorf("[%d] NextAddress want %s got %s", i, tt.next, addr)
+		}
+		if addr := ipn.PreviousAddress(); !addr.Equal(tt.prev) {
+			t.Errorf("[%d] PreviousAddress want %s got %s", i, tt.prev, addr)
+		}
+	}
+	if (Net4{}).NextAddress() != nil || (Net4{}).PreviousAddress() != nil {
+		t.Errorf("want nil for empty Net4")
+	}
+}
+
+var isAssignable4Tests = []struct {
+	inaddr string
+	hmlen  int
+	ip     net.IP
+	ok     bool
+}{
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.0.0"), false},
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.0.1"), true},
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.0.254"), true},
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.0.255"), false},
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.1.1"), false},
+	{"192.168.0.0/24", 0, net.ParseIP("2001:db8::1"), false},
+	{"192.168.0.0/31", 0, net.ParseIP("192.168.0.0"), true},
+	{"192.168.0.0/31", 0, net.ParseIP("192.168.0.1"), true},
+	{"192.168.0.0/32", 0, net.ParseIP("192.168.0.0"), true},
+	{"192.168.0.0/24", 4, net.ParseIP("192.168.0.0"), true},
+	{"192.168.0.0/24", 4, net.ParseIP("192.168.0.15"), true},
+	{"192.168.0.0/24", 4, net.ParseIP("192.168.0.16"), false},
+}
+
+func TestNet4_IsAssignable(t *testing.T) {
+	for i, tt := range isAssignable4Tests {
+		ipn := Net4FromStr(tt.inaddr)
+		if tt.hmlen > 0 {
+			ones, _ := ipn.Mask().Size()
+			ipn, _ = NewNet4WithHostmask(ipn.IP(), ones, tt.hmlen)
+		}
+		if v := ipn.IsAssignable(tt.ip); v != tt.ok {
+			t.Errorf("[%d] %s in %s: want %t got %t", i, tt.ip, tt.inaddr, tt.ok, v)
+		}
+	}
+}
+
+var remaining4Tests = []struct {
+	inaddr string
+	hmlen  int
+	ip     net.IP
+	left   uint32
+	err    error
+}{
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.0.0"), 254, nil},
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.0.1"), 254, nil},
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.0.100"), 155, nil},
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.0.254"), 1, nil},
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.0.255"), 0, nil},
+	{"192.168.0.0/24", 0, net.ParseIP("192.168.1.0"), 0, ErrAddressOutOfRange},
+	{"192.168.0.0/31", 0, net.ParseIP("192.168.0.0"), 2, nil},
+	{"192.168.0.0/32", 0, net.ParseIP("192.168.0.0"), 1, nil},
+	{"192.168.0.0/24", 4, net.ParseIP("192.168.0.0"), 16, nil},
+	{"192.168.0.0/24", 4, net.ParseIP("192.168.0.10"), 6, nil},
+	{"192.168.0.0/24", 4, net.ParseIP("192.168.0.15"), 1, nil},
+	{"192.168.0.0/24", 4, net.ParseIP("192.168.0.16"), 0, ErrAddressOutOfRange},
+	{"192.168.0.0/16", 8, net.ParseIP("192.168.10.0"), 246, nil},
+	{"192.168.0.0/16", 8, net.ParseIP("192.168.10.1"), 0, ErrAddressOutOfRange},
+}
+
+func TestNet4_Remaining(t *testing.T) {
+	for i, tt := range remaining4Tests {
+		ipn := Net4FromStr(tt.inaddr)
+		if tt.hmlen > 0 {
+			ones, _ := ipn.Mask().Size()
+			ipn, _ = NewNet4WithHostmask(ipn.IP(), ones, tt.hmlen)
+		}
+		left, err := ipn.Remaining(tt.ip)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+		}
+		if left != tt.left {
+			t.Errorf("[%d] %s: want %d got %d", i, tt.ip, tt.left, left)
+		}
+	}
+}
+
+var wouldExitBlock4Tests = []struct {
+	inaddr string
+	ip     net.IP
+	count  uint32
+	exits  bool
+}{
+	{"192.168.0.0/24", net.ParseIP("192.168.0.1"), 0, false},
+	{"192.168.0.0/24", net.ParseIP("192.168.0.1"), 254, false},
+	{"192.168.0.0/24", net.ParseIP("192.168.0.1"), 255, true},
+	{"192.168.0.0/24", net.ParseIP("192.168.0.255"), 1, true},
+	{"192.168.0.0/24", net.ParseIP("192.168.1.0"), 0, true},
+	{"255.255.255.0/24", net.ParseIP("255.255.255.250"), 5, false},
+	{"255.255.255.0/24", net.ParseIP("255.255.255.250"), 6, true},
+	{"0.0.0.0/0", net.ParseIP("0.0.0.1"), MaxIPv4, true},
+	{"0.0.0.0/0", net.ParseIP("0.0.0.0"), MaxIPv4, false},
+}
+
+func TestNet4_WouldExitBlock(t *testing.T) {
+	for i, tt := range wouldExitBlock4Tests {
+		if v := Net4FromStr(tt.inaddr).WouldExitBlock(tt.ip, tt.count); v != tt.exits {
+			t.Errorf("[%d] %s + %d: want %t got %t", i, tt.ip, tt.count, tt.exits, v)
+		}
+	}
+}
+
 var incr4Tests = []struct {
 	inaddr   string
 	thisaddr net.IP
@@ -543,64 +1127,229 @@ func TestNet4_Subnet(t *testing.T) {
 	}
 }
 
-var supernet4Tests = []struct {
-	in      Net4
-	masklen int
-	out     Net4
-	err     error
+func TestNet4_SubnetOrder(t *testing.T) {
+	for i, ipn := range []Net4{Net4FromStr("10.0.0.0/8"), Net4FromStr("255.255.255.0/24"), Net4FromStr("0.0.0.0/1")} {
+		ones, _ := ipn.Mask().Size()
+		subnets, err := ipn.Subnet(ones + 8)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+			continue
+		}
+		if len(subnets) != 256 {
+			t.Errorf("[%d] want 256 subnets got %d", i, len(subnets))
+		}
+		for ii := 1; ii < len(subnets); ii++ {
+			if v := CompareNets(subnets[ii-1], subnets[ii]); v != -1 {
+				t.Errorf("[%d] subnets %d and %d out of order: %s, %s", i, ii-1, ii, subnets[ii-1], subnets[ii])
+			}
+		}
+	}
+}
+
+var allocateVLSM4Tests = []struct {
+	netblock   Net4
+	hostCounts []int
+	allocated  []string
+	remainder  []string
+	err        error
 }{
 	{
-		Net4FromStr("192.168.1.0/24"), 23, Net4FromStr("192.168.0.0/23"),
+		Net4FromStr("192.168.0.0/24"), []int{50, 100, 10},
+		[]string{"192.168.0.128/26", "192.168.0.0/25", "192.168.0.192/28"},
+		[]string{"192.168.0.208/28", "192.168.0.224/27"},
 		nil,
 	},
 	{
-		Net4FromStr("192.168.1.0/24"), 0, Net4FromStr("192.168.0.0/23"),
+		Net4FromStr("192.168.0.0/24"), []int{126, 126},
+		[]string{"192.168.0.0/25", "192.168.0.128/25"},
+		[]string{},
 		nil,
 	},
 	{
-		Net4FromStr("192.168.1.0/24"), 22, Net4FromStr("192.168.1.0/22"),
+		Net4FromStr("10.0.0.0/29"), []int{1, 2, 2},
+		[]string{"10.0.0.4/32", "10.0.0.0/31", "10.0.0.2/31"},
+		[]string{"10.0.0.5/32", "10.0.0.6/31"},
 		nil,
 	},
 	{
-		Net4FromStr("192.168.1.4/30"), 24, Net4FromStr("192.168.1.0/24"),
+		Net4FromStr("192.168.0.0/24"), []int{},
+		[]string{},
+		[]string{"192.168.0.0/24"},
 		nil,
 	},
 	{
-		Net4FromStr("192.168.0.0/24"), 25, Net4{},
+		Net4FromStr("192.168.0.0/24"), []int{255},
+		[]string{}, []string{},
+		ErrInsufficientSpace,
+	},
+	{
+		Net4FromStr("192.168.0.0/24"), []int{100, 100, 10},
+		[]string{}, []string{},
+		ErrInsufficientSpace,
+	},
+	{
+		Net4FromStr("192.168.0.0/24"), []int{10, 0},
+		[]string{}, []string{},
 		ErrBadMaskLength,
 	},
 }
 
-func TestNet4_Supernet(t *testing.T) {
-	for i, tt := range supernet4Tests {
-		out, err := tt.in.Supernet(tt.masklen)
+func TestNet4_AllocateVLSMWithRemainder(t *testing.T) {
+	for i, tt := range allocateVLSM4Tests {
+		allocated, remainder, err := tt.netblock.AllocateVLSMWithRemainder(tt.hostCounts)
 		if e := compareErrors(err, tt.err); len(e) > 0 {
 			t.Errorf("[%d] %s", i, e)
-		} else {
-			if v := CompareNets(out, tt.out); v != 0 {
-				t.Errorf("[%d] want %s got %s", i, tt.out, out)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if v := compareNet4ArraysToStringRepresentation(allocated, tt.allocated); v == false {
+			t.Errorf("[%d] allocated: want %v got %v", i, tt.allocated, allocated)
+		}
+		if v := compareNet4ArraysToStringRepresentation(remainder, tt.remainder); v == false {
+			t.Errorf("[%d] remainder: want %v got %v", i, tt.remainder, remainder)
+		}
+		for ii, a := range allocated {
+			if a.Count() < uint32(tt.hostCounts[ii]) {
+				t.Errorf("[%d] %s cannot hold %d hosts", i, a, tt.hostCounts[ii])
+			}
+		}
+
+		// allocated and remainder together must exactly tile the netblock
+		tiles := ByNet{}
+		for _, a := range allocated {
+			tiles = append(tiles, a)
+		}
+		for _, r := range remainder {
+			tiles = append(tiles, r)
+		}
+		sort.Sort(tiles)
+		next := tt.netblock.IP()
+		for _, tile := range tiles {
+			if !tile.IP().Equal(next) {
+				t.Errorf("[%d] want tile starting at %s, got %s", i, next, tile)
 			}
+			next = NextIP(tile.BroadcastAddress())
+		}
+		if !tiles[len(tiles)-1].BroadcastAddress().Equal(tt.netblock.BroadcastAddress()) {
+			t.Errorf("[%d] tiles end at %s, want %s", i, tiles[len(tiles)-1].BroadcastAddress(), tt.netblock.BroadcastAddress())
 		}
 	}
 }
 
-func TestCompareNets(t *testing.T) {
-	net4map := map[int]Net4{
-		0: Net4FromStr("192.168.0.0/16"),
-		1: Net4FromStr("192.168.0.0/23"),
-		2: Net4FromStr("192.168.1.0/24"),
-		3: Net4FromStr("192.168.1.0/24"),
-		4: Net4FromStr("192.168.3.0/26"),
-		5: Net4FromStr("192.168.3.64/26"),
-		6: Net4FromStr("192.168.3.128/25"),
-		7: Net4FromStr("192.168.4.0/24"),
-	}
+var scanUnits4Tests = []struct {
+	netblock Net4
+	masklen  int
+	count    int
+	first    string
+	last     string
+	err      error
+}{
+	{Net4FromStr("10.0.0.0/16"), 0, 256, "10.0.0.0/24", "10.0.255.0/24", nil},
+	{Net4FromStr("10.0.0.0/16"), 24, 256, "10.0.0.0/24", "10.0.255.0/24", nil},
+	{Net4FromStr("10.0.0.0/16"), 16, 1, "10.0.0.0/16", "10.0.0.0/16", nil},
+	{Net4FromStr("10.0.0.0/24"), 26, 4, "10.0.0.0/26", "10.0.0.192/26", nil},
+	{Net4FromStr("255.255.0.0/16"), 24, 256, "255.255.0.0/24", "255.255.255.0/24", nil},
+	{Net4FromStr("10.0.0.0/26"), 0, 0, "", "", ErrBadMaskLength},
+	{Net4FromStr("10.0.0.0/16"), 33, 0, "", "", ErrBadMaskLength},
+}
 
-	net4list := ByNet{}
-	for _, ipn := range net4map {
-		net4list = append(net4list, ipn)
-	}
-	sort.Sort(ByNet(net4list))
+func TestNet4_ScanUnits(t *testing.T) {
+	for i, tt := range scanUnits4Tests {
+		units, err := tt.netblock.ScanUnits(tt.masklen)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		got := []Net4{}
+		units(func(unit Net4) bool {
+			got = append(got, unit)
+			return true
+		})
+		if len(got) != tt.count {
+			t.Errorf("[%d] want %d units got %d", i, tt.count, len(got))
+			continue
+		}
+		if got[0].String() != tt.first || got[len(got)-1].String() != tt.last {
+			t.Errorf("[%d] want %s ... %s got %s ... %s", i, tt.first, tt.last, got[0], got[len(got)-1])
+		}
+	}
+}
+
+func TestNet4_ScanUnitsStop(t *testing.T) {
+	units, _ := Net4FromStr("10.0.0.0/8").ScanUnits(24)
+	count := 0
+	units(func(unit Net4) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Errorf("want iteration to stop after 3 units, got %d", count)
+	}
+}
+
+var supernet4Tests = []struct {
+	in      Net4
+	masklen int
+	out     Net4
+	err     error
+}{
+	{
+		Net4FromStr("192.168.1.0/24"), 23, Net4FromStr("192.168.0.0/23"),
+		nil,
+	},
+	{
+		Net4FromStr("192.168.1.0/24"), 0, Net4FromStr("192.168.0.0/23"),
+		nil,
+	},
+	{
+		Net4FromStr("192.168.1.0/24"), 22, Net4FromStr("192.168.1.0/22"),
+		nil,
+	},
+	{
+		Net4FromStr("192.168.1.4/30"), 24, Net4FromStr("192.168.1.0/24"),
+		nil,
+	},
+	{
+		Net4FromStr("192.168.0.0/24"), 25, Net4{},
+		ErrBadMaskLength,
+	},
+}
+
+func TestNet4_Supernet(t *testing.T) {
+	for i, tt := range supernet4Tests {
+		out, err := tt.in.Supernet(tt.masklen)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+		} else {
+			if v := CompareNets(out, tt.out); v != 0 {
+				t.Errorf("[%d] want %s got %s", i, tt.out, out)
+			}
+		}
+	}
+}
+
+func TestCompareNets(t *testing.T) {
+	net4map := map[int]Net4{
+		0: Net4FromStr("192.168.0.0/16"),
+		1: Net4FromStr("192.168.0.0/23"),
+		2: Net4FromStr("192.168.1.0/24"),
+		3: Net4FromStr("192.168.1.0/24"),
+		4: Net4FromStr("192.168.3.0/26"),
+		5: Net4FromStr("192.168.3.64/26"),
+		6: Net4FromStr("192.168.3.128/25"),
+		7: Net4FromStr("192.168.4.0/24"),
+	}
+
+	net4list := ByNet{}
+	for _, ipn := range net4map {
+		net4list = append(net4list, ipn)
+	}
+	sort.Sort(ByNet(net4list))
 	for pos, ipn := range net4map {
 		if v := CompareNets(net4list[pos], ipn); v != 0 {
 			for i, aipn := range net4list {
@@ -642,6 +1391,30 @@ func TestNet4_RandomIP(t *testing.T) {
 	}
 }
 
+func TestNet4_RandomIPFrom(t *testing.T) {
+	n := Net4FromStr("10.0.0.0/8")
+	key := bytes.Repeat([]byte{0x3c, 0x81}, 16)
+
+	a, err := n.RandomIPFrom(bytes.NewReader(key))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b, _ := n.RandomIPFrom(bytes.NewReader(key))
+	if !a.Equal(b) {
+		t.Errorf("same reader contents should give the same address, got %s and %s", a, b)
+	}
+	if !n.Contains(a) {
+		t.Errorf("address %s not in %s", a, n)
+	}
+
+	if ip, err := n.RandomIPFrom(bytes.NewReader(nil)); err == nil || ip != nil {
+		t.Errorf("want an error from an empty reader, got %s, %v", ip, err)
+	}
+	if ip, err := n.RandomIPFrom(nil); err != nil || !n.Contains(ip) {
+		t.Errorf("nil reader: got %s, %v", ip, err)
+	}
+}
+
 func TestNet4_Is4in6(t *testing.T) {
 	nf := Net4FromStr("192.168.0.0./16")
 	if nf.Is4in6() != false {
@@ -653,6 +1426,1001 @@ func TestNet4_Is4in6(t *testing.T) {
 	}
 }
 
+func TestNet4_ToIPNet(t *testing.T) {
+	for i, xnet := range []string{"192.168.0.0/24", "::ffff:c0a8:0101/24", "0.0.0.0/0"} {
+		_, n, _ := ParseCIDR(xnet)
+		ipnet := n.(Net4).ToIPNet()
+		if len(ipnet.IP) != 4 || len(ipnet.Mask) != 4 {
+			t.Errorf("[%d] want 4-byte IP and mask got %d and %d", i, len(ipnet.IP), len(ipnet.Mask))
+		}
+		if ipnet.String() != n.String() {
+			t.Errorf("[%d] want %s got %s", i, n, ipnet)
+		}
+
+		// the result must not share memory with the Net4
+		ipnet.IP[0] ^= 0xff
+		if ipnet.IP.Equal(n.IP()) {
+			t.Errorf("[%d] ToIPNet returned a shared IP", i)
+		}
+	}
+
+	if (Net4{}).ToIPNet() != nil {
+		t.Errorf("want nil for an empty Net4")
+	}
+}
+
+var rangeString4Tests = []struct {
+	xnet string
+	out  string
+}{
+	{"192.168.1.0/24", "192.168.1.1 - 192.168.1.254 (254 hosts)"},
+	{"192.168.1.0/31", "192.168.1.0 - 192.168.1.1 (2 hosts)"},
+	{"192.168.1.7/32", "192.168.1.7 - 192.168.1.7 (1 host)"},
+}
+
+func TestNet4_RangeString(t *testing.T) {
+	for i, tt := range rangeString4Tests {
+		if s := Net4FromStr(tt.xnet).RangeString(); s != tt.out {
+			t.Errorf("[%d] want %q got %q", i, tt.out, s)
+		}
+	}
+
+	if s := (Net4{}).RangeString(); s != "<nil>" {
+		t.Errorf("want <nil> for an empty Net4 got %q", s)
+	}
+}
+
+func TestNet4_Hash(t *testing.T) {
+	// the hash is documented as stable, so pin a known value
+	if h := Net4FromStr("192.168.0.0/16").Hash(); h != 12409221113013847123 {
+		t.Errorf("want 12409221113013847123 got %d", h)
+	}
+
+	n4 := Net4FromStr("192.168.0.0/16")
+	if n4.Hash() != Net4FromStr("192.168.1.1/16").Hash() {
+		t.Errorf("same network with different host bits should hash the same")
+	}
+	if n4.Hash() == Net4FromStr("192.168.0.0/17").Hash() {
+		t.Errorf("different mask lengths should hash differently")
+	}
+	if n4.Hash() != NewNet4(net.ParseIP("::ffff:192.168.0.0"), 16).Hash() {
+		t.Errorf("4in6 form should hash the same as native v4")
+	}
+
+	hm, _ := NewNet4WithHostmask(net.ParseIP("192.168.0.0"), 16, 4)
+	if n4.Hash() == hm.Hash() {
+		t.Errorf("hostmask should change the hash")
+	}
+}
+
+var utilization4Tests = []struct {
+	xnet string
+	used []string
+	util float64
+}{
+	{"192.168.0.0/24", []string{}, 0},
+	{"192.168.0.0/24", []string{"192.168.0.0/25"}, 0.5},
+	{"192.168.0.0/24", []string{"192.168.0.0/25", "192.168.0.0/26", "192.168.0.64/26"}, 0.5},
+	{"192.168.0.0/24", []string{"192.168.0.0/25", "192.168.0.128/26"}, 0.75},
+	{"192.168.0.0/24", []string{"192.168.0.0/16"}, 1},
+	{"192.168.0.0/24", []string{"192.168.1.0/24", "10.0.0.0/8"}, 0},
+	{"192.168.0.0/24", []string{"192.168.0.7/32"}, 1.0 / 256},
+	{"0.0.0.0/0", []string{"0.0.0.0/2"}, 0.25},
+}
+
+func TestNet4_Utilization(t *testing.T) {
+	for i, tt := range utilization4Tests {
+		used := []Net4{}
+		for _, s := range tt.used {
+			used = append(used, Net4FromStr(s))
+		}
+		if u := Net4FromStr(tt.xnet).Utilization(used); u != tt.util {
+			t.Errorf("[%d] %s: want %f got %f", i, tt.xnet, tt.util, u)
+		}
+	}
+
+	if u := (Net4{}).Utilization(nil); u != 0 {
+		t.Errorf("want 0 for an empty network got %f", u)
+	}
+}
+
+func TestNet4_ReverseAddressIter(t *testing.T) {
+	for i, xnet := range []string{"192.168.1.0/29", "192.168.1.0/31", "192.168.1.1/32"} {
+		n := Net4FromStr(xnet)
+		forward := n.Enumerate(0, 0)
+		var reverse []net.IP
+		n.ReverseAddressIter()(func(ip net.IP) bool {
+			reverse = append(reverse, ip)
+			return true
+		})
+		if len(forward) != len(reverse) {
+			t.Errorf("[%d] %s: want %d addresses got %d", i, xnet, len(forward), len(reverse))
+			continue
+		}
+		for j := range reverse {
+			if !reverse[j].Equal(forward[len(forward)-1-j]) {
+				t.Errorf("[%d] %s: position %d want %s got %s", i, xnet, j, forward[len(forward)-1-j], reverse[j])
+			}
+		}
+	}
+
+	hm, _ := NewNet4WithHostmask(net.ParseIP("192.168.1.0"), 24, 4)
+	var got []string
+	hm.ReverseAddressIter()(func(ip net.IP) bool {
+		got = append(got, ip.String())
+		return len(got) < 3
+	})
+	if len(got) != 3 || got[0] != "192.168.1.15" || got[2] != "192.168.1.13" {
+		t.Errorf("want [192.168.1.15 192.168.1.14 192.168.1.13] got %v", got)
+	}
+}
+
+func TestNet4_Packed(t *testing.T) {
+	for i, xnet := range []string{"192.168.1.0/24", "0.0.0.0/0", "255.255.255.255/32", "10.1.2.0/31"} {
+		n := Net4FromStr(xnet)
+		p := n.Packed()
+		rt, err := Net4FromPacked(p)
+		if err != nil || rt.String() != n.String() {
+			t.Errorf("[%d] %s: round trip gave %s, %v", i, xnet, rt, err)
+		}
+	}
+	if p := Net4FromStr("192.168.1.0/24").Packed(); p != [5]byte{192, 168, 1, 0, 24} {
+		t.Errorf("want [192 168 1 0 24] got %v", p)
+	}
+	if _, err := Net4FromPacked([5]byte{192, 168, 1, 0, 33}); err != ErrBadMaskLength {
+		t.Errorf("want %v got %v", ErrBadMaskLength, err)
+	}
+}
+
+var splitAtTests = []struct {
+	xnet   string
+	points []string
+	ranges []string
+	err    error
+}{
+	{
+		"192.168.1.0/24", []string{},
+		[]string{"192.168.1.0-192.168.1.255"}, nil,
+	},
+	{
+		"192.168.1.0/24", []string{"192.168.1.100", "192.168.1.10"},
+		[]string{"192.168.1.0-192.168.1.9", "192.168.1.10-192.168.1.99", "192.168.1.100-192.168.1.255"}, nil,
+	},
+	{
+		"192.168.1.0/24", []string{"192.168.1.0", "192.168.1.128", "192.168.1.128"},
+		[]string{"192.168.1.0-192.168.1.127", "192.168.1.128-192.168.1.255"}, nil,
+	},
+	{
+		"192.168.1.0/24", []string{"192.168.1.255"},
+		[]string{"192.168.1.0-192.168.1.254", "192.168.1.255-192.168.1.255"}, nil,
+	},
+	{
+		"192.168.1.0/24", []string{"192.168.1.50", "192.168.2.1"},
+		nil, ErrAddressOutOfRange,
+	},
+	{
+		"0.0.0.0/0", []string{"128.0.0.0"},
+		[]string{"0.0.0.0-127.255.255.255", "128.0.0.0-255.255.255.255"}, nil,
+	},
+}
+
+func TestNet4_SplitAt(t *testing.T) {
+	for i, tt := range splitAtTests {
+		n := Net4FromStr(tt.xnet)
+		points := []net.IP{}
+		for _, p := range tt.points {
+			points = append(points, net.ParseIP(p))
+		}
+		ranges, err := n.SplitAt(points)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if len(ranges) != len(tt.ranges) {
+			t.Errorf("[%d] want %v got %v", i, tt.ranges, ranges)
+			continue
+		}
+		for j, r := range ranges {
+			if r.String() != tt.ranges[j] {
+				t.Errorf("[%d] range %d: want %s got %s", i, j, tt.ranges[j], r)
+			}
+		}
+	}
+}
+
+var nextNSubnetsTests = []struct {
+	xnet    string
+	masklen int
+	count   int
+	nets    []string
+	err     error
+}{
+	{
+		"192.168.0.0/16", 24, 3,
+		[]string{"192.168.0.0/24", "192.168.1.0/24", "192.168.2.0/24"}, nil,
+	},
+	{
+		"192.168.0.0/24", 0, 2,
+		[]string{"192.168.0.0/25", "192.168.0.128/25"}, nil,
+	},
+	{
+		"192.168.0.0/24", 26, 4,
+		[]string{"192.168.0.0/26", "192.168.0.64/26", "192.168.0.128/26", "192.168.0.192/26"}, nil,
+	},
+	{
+		"192.168.0.0/24", 26, 5,
+		nil, ErrInsufficientSpace,
+	},
+	{
+		"192.168.0.0/24", 23, 1,
+		nil, ErrBadMaskLength,
+	},
+	{
+		"192.168.0.0/24", 33, 1,
+		nil, ErrBadMaskLength,
+	},
+	{
+		"192.168.0.0/24", 28, 0,
+		[]string{}, nil,
+	},
+	{
+		"0.0.0.0/0", 32, 2,
+		[]string{"0.0.0.0/32", "0.0.0.1/32"}, nil,
+	},
+}
+
+func TestNet4_NextNSubnets(t *testing.T) {
+	for i, tt := range nextNSubnetsTests {
+		subnets, err := Net4FromStr(tt.xnet).NextNSubnets(tt.masklen, tt.count)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err == nil && !compareNet4ArraysToStringRepresentation(subnets, tt.nets) {
+			t.Errorf("[%d] want %v got %v", i, tt.nets, subnets)
+		}
+	}
+}
+
+var subnetIndexTests = []struct {
+	child  string
+	parent string
+	index  uint32
+	err    error
+}{
+	{"192.168.1.0/26", "192.168.1.0/24", 0, nil},
+	{"192.168.1.128/26", "192.168.1.0/24", 2, nil},
+	{"192.168.1.192/26", "192.168.1.0/24", 3, nil},
+	{"192.168.1.0/24", "192.168.1.0/24", 0, nil},
+	{"192.168.255.0/24", "192.168.0.0/16", 255, nil},
+	{"255.255.255.255/32", "0.0.0.0/0", 4294967295, nil},
+	{"10.0.0.0/32", "0.0.0.0/0", 167772160, nil},
+	{"192.168.2.0/26", "192.168.1.0/24", 0, ErrAddressOutOfRange},
+	{"192.168.0.0/23", "192.168.1.0/24", 0, ErrBadMaskLength},
+}
+
+func TestNet4_SubnetIndex(t *testing.T) {
+	for i, tt := range subnetIndexTests {
+		idx, err := Net4FromStr(tt.child).SubnetIndex(Net4FromStr(tt.parent))
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if idx != tt.index {
+			t.Errorf("[%d] want %d got %d", i, tt.index, idx)
+		}
+	}
+}
+
+var enumerateOptsTests = []struct {
+	xnet  string
+	opts  EnumerateOptions
+	addrs []string
+}{
+	{
+		"192.168.1.0/29", EnumerateOptions{},
+		[]string{"192.168.1.1", "192.168.1.2", "192.168.1.3", "192.168.1.4", "192.168.1.5", "192.168.1.6"},
+	},
+	{
+		"192.168.1.0/29", EnumerateOptions{IncludeNetwork: true, IncludeBroadcast: true},
+		[]string{"192.168.1.0", "192.168.1.1", "192.168.1.2", "192.168.1.3", "192.168.1.4", "192.168.1.5", "192.168.1.6", "192.168.1.7"},
+	},
+	{
+		"192.168.1.0/29", EnumerateOptions{IncludeBroadcast: true, Offset: 4},
+		[]string{"192.168.1.5", "192.168.1.6", "192.168.1.7"},
+	},
+	{
+		"192.168.1.0/29", EnumerateOptions{IncludeNetwork: true, Size: 2},
+		[]string{"192.168.1.0", "192.168.1.1"},
+	},
+	{
+		"192.168.1.0/29", EnumerateOptions{IncludeNetwork: true, IncludeBroadcast: true, Stride: 3},
+		[]string{"192.168.1.0", "192.168.1.3", "192.168.1.6"},
+	},
+	{
+		"192.168.1.0/29", EnumerateOptions{Stride: 2, Offset: 1, Size: 2},
+		[]string{"192.168.1.2", "192.168.1.4"},
+	},
+	{
+		"192.168.1.0/31", EnumerateOptions{IncludeNetwork: true, IncludeBroadcast: true},
+		[]string{"192.168.1.0", "192.168.1.1"},
+	},
+	{
+		"192.168.1.0/29", EnumerateOptions{IncludeNetwork: true, Offset: 9},
+		[]string{},
+	},
+	{
+		"192.168.1.0/29", EnumerateOptions{Offset: -1},
+		nil,
+	},
+}
+
+func TestNet4_EnumerateOpts(t *testing.T) {
+	for i, tt := range enumerateOptsTests {
+		addrs := Net4FromStr(tt.xnet).EnumerateOpts(tt.opts)
+		if tt.addrs == nil {
+			if addrs != nil {
+				t.Errorf("[%d] want nil got %v", i, addrs)
+			}
+			continue
+		}
+		if len(addrs) != len(tt.addrs) {
+			t.Errorf("[%d] want %v got %v", i, tt.addrs, addrs)
+			continue
+		}
+		for j, addr := range addrs {
+			if addr.String() != tt.addrs[j] {
+				t.Errorf("[%d] address %d: want %s got %s", i, j, tt.addrs[j], addr)
+			}
+		}
+	}
+
+	hm, _ := NewNet4WithHostmask(net.ParseIP("192.168.1.0"), 24, 4)
+	addrs := hm.EnumerateOpts(EnumerateOptions{IncludeNetwork: true, IncludeBroadcast: true, Offset: 14})
+	if len(addrs) != 2 || addrs[0].String() != "192.168.1.14" || addrs[1].String() != "192.168.1.15" {
+		t.Errorf("want [192.168.1.14 192.168.1.15] got %v", addrs)
+	}
+	for _, addr := range addrs {
+		if !hm.Contains(addr) {
+			t.Errorf("%s is outside the hostmasked network", addr)
+		}
+	}
+}
+
+func TestNet4_SubnetWalk(t *testing.T) {
+	n := Net4FromStr("192.168.0.0/24")
+	want, _ := n.Subnet(26)
+
+	var got []Net4
+	err := n.SubnetWalk(26, func(index uint32, sub Net4) error {
+		if int(index) != len(got) {
+			t.Errorf("want index %d got %d", len(got), index)
+		}
+		if idx, _ := sub.SubnetIndex(n); idx != index {
+			t.Errorf("%s: SubnetIndex() %d disagrees with walk index %d", sub, idx, index)
+		}
+		got = append(got, sub)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %v got %v", want, got)
+	}
+	for i := range got {
+		if got[i].String() != want[i].String() {
+			t.Errorf("[%d] want %s got %s", i, want[i], got[i])
+		}
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = n.SubnetWalk(28, func(index uint32, sub Net4) error {
+		calls++
+		if index == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || calls != 3 {
+		t.Errorf("want walk to stop after 3 calls with %v, got %d calls and %v", stop, calls, err)
+	}
+
+	for _, masklen := range []int{23, 33} {
+		err := n.SubnetWalk(masklen, func(index uint32, sub Net4) error {
+			t.Errorf("callback should not be called for masklen %d", masklen)
+			return nil
+		})
+		if err != ErrBadMaskLength {
+			t.Errorf("masklen %d: want %v got %v", masklen, ErrBadMaskLength, err)
+		}
+	}
+
+	calls = 0
+	_ = Net4FromStr("10.0.0.0/8").SubnetWalk(8, func(index uint32, sub Net4) error {
+		calls++
+		return nil
+	})
+	if calls != 1 {
+		t.Errorf("walking a network at its own mask length should yield it once, got %d", calls)
+	}
+}
+
+var containsRangeTests = []struct {
+	xnet  string
+	first string
+	last  string
+	in    bool
+}{
+	{"192.168.1.0/24", "192.168.1.10", "192.168.1.99", true},
+	{"192.168.1.0/24", "192.168.1.0", "192.168.1.255", true},
+	{"192.168.1.0/24", "192.168.1.200", "192.168.2.10", false}, // starts inside, ends outside
+	{"192.168.1.0/24", "192.168.0.200", "192.168.1.10", false}, // starts outside, ends inside
+	{"192.168.1.0/24", "192.168.0.0", "192.168.2.255", false},  // encloses the network
+	{"192.168.1.0/24", "10.0.0.1", "10.0.0.2", false},
+	{"0.0.0.0/0", "0.0.0.0", "255.255.255.255", true},
+	{"192.168.1.7/32", "192.168.1.7", "192.168.1.7", true},
+}
+
+func TestNet4_ContainsRange(t *testing.T) {
+	for i, tt := range containsRangeTests {
+		r, _ := NewRange4(net.ParseIP(tt.first), net.ParseIP(tt.last))
+		if v := Net4FromStr(tt.xnet).ContainsRange(r); v != tt.in {
+			t.Errorf("[%d] %s contains %s: want %t got %t", i, tt.xnet, r, tt.in, v)
+		}
+	}
+	if Net4FromStr("192.168.1.0/24").ContainsRange(Range4{}) {
+		t.Errorf("an empty range should not be contained")
+	}
+}
+
+var aggregateToSupernetTests = []struct {
+	nets  []string
+	super string
+	err   error
+}{
+	{[]string{"192.168.1.0/24"}, "192.168.1.0/24", nil},
+	{[]string{"192.168.1.0/24", "192.168.2.0/24"}, "192.168.0.0/22", nil},
+	{[]string{"192.168.2.0/24", "192.168.3.0/24"}, "192.168.2.0/23", nil},
+	{[]string{"192.168.3.0/24", "192.168.2.128/25", "192.168.2.7/32"}, "192.168.2.0/23", nil},
+	{[]string{"10.0.0.0/8", "10.1.2.0/24"}, "10.0.0.0/8", nil},
+	{[]string{"10.0.0.0/8", "192.168.0.0/16"}, "0.0.0.0/0", nil},
+	{[]string{"192.168.1.1/32", "192.168.1.1/32"}, "192.168.1.1/32", nil},
+	{[]string{}, "", ErrNoValidRange},
+}
+
+func TestAggregateToSupernet(t *testing.T) {
+	for i, tt := range aggregateToSupernetTests {
+		nets := []Net4{}
+		for _, s := range tt.nets {
+			nets = append(nets, Net4FromStr(s))
+		}
+		super, err := AggregateToSupernet(nets)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if super.String() != tt.super {
+			t.Errorf("[%d] want %s got %s", i, tt.super, super)
+		}
+		for _, n := range nets {
+			if !super.ContainsNet(n) {
+				t.Errorf("[%d] %s does not contain %s", i, super, n)
+			}
+		}
+	}
+
+	if _, err := AggregateToSupernet([]Net4{Net4FromStr("10.0.0.0/8"), {}}); err != ErrNoValidRange {
+		t.Errorf("want %v for an empty Net4 got %v", ErrNoValidRange, err)
+	}
+}
+
+var shareParentTests = []struct {
+	nets    []string
+	masklen int
+	parent  string
+	ok      bool
+}{
+	{[]string{"192.168.1.0/26", "192.168.1.64/26", "192.168.1.192/26"}, 24, "192.168.1.0/24", true},
+	{[]string{"192.168.1.0/26", "192.168.2.0/26"}, 24, "192.168.1.0/24", false},
+	{[]string{"192.168.1.0/26", "192.168.2.0/26"}, 22, "192.168.0.0/22", true},
+	{[]string{"192.168.0.0/23"}, 24, "192.168.0.0/24", false}, // input is larger than the parent
+	{[]string{"192.168.1.0/24"}, 24, "192.168.1.0/24", true},
+	{[]string{"10.0.0.0/8", "192.168.0.0/16"}, 0, "0.0.0.0/0", true},
+	{[]string{}, 24, "<nil>", false},
+	{[]string{"192.168.1.0/26"}, 33, "<nil>", false},
+}
+
+func TestShareParent(t *testing.T) {
+	for i, tt := range shareParentTests {
+		nets := []Net4{}
+		for _, s := range tt.nets {
+			nets = append(nets, Net4FromStr(s))
+		}
+		parent, ok := ShareParent(nets, tt.masklen)
+		if ok != tt.ok {
+			t.Errorf("[%d] want %t got %t", i, tt.ok, ok)
+		}
+		if parent.String() != tt.parent {
+			t.Errorf("[%d] want %s got %s", i, tt.parent, parent)
+		}
+	}
+}
+
+var enumerateExcludingTests = []struct {
+	xnet   string
+	size   int
+	offset int
+	addrs  []string
+}{
+	{"192.168.1.0/29", 0, 0, []string{"192.168.1.1", "192.168.1.4", "192.168.1.6"}},
+	{"192.168.1.0/29", 2, 0, []string{"192.168.1.1", "192.168.1.4"}},
+	{"192.168.1.0/29", 0, 1, []string{"192.168.1.4", "192.168.1.6"}},
+	{"192.168.1.0/29", 1, 2, []string{"192.168.1.6"}},
+	{"192.168.1.0/29", 0, 3, []string{}},
+	{"192.168.1.2/31", 0, 0, []string{}},
+	{"192.168.1.8/30", 0, 0, []string{"192.168.1.9", "192.168.1.10"}},
+}
+
+func TestNet4_EnumerateExcluding(t *testing.T) {
+	excl := NewIPSet([]Net{
+		Net4FromStr("192.168.1.2/31"),
+		Net4FromStr("192.168.1.5/32"),
+	})
+	for i, tt := range enumerateExcludingTests {
+		addrs := Net4FromStr(tt.xnet).EnumerateExcluding(excl, tt.size, tt.offset)
+		if len(addrs) != len(tt.addrs) {
+			t.Errorf("[%d] want %v got %v", i, tt.addrs, addrs)
+			continue
+		}
+		for j, addr := range addrs {
+			if addr.String() != tt.addrs[j] {
+				t.Errorf("[%d] address %d: want %s got %s", i, j, tt.addrs[j], addr)
+			}
+		}
+	}
+
+	n := Net4FromStr("192.168.1.0/24")
+	if addrs := n.EnumerateExcluding(nil, 0, 0); len(addrs) != 254 {
+		t.Errorf("a nil exclusion set should exclude nothing, got %d addresses", len(addrs))
+	}
+	if addrs := n.EnumerateExcluding(excl, -1, 0); addrs != nil {
+		t.Errorf("want nil for a negative size got %v", addrs)
+	}
+
+	count := 0
+	Net4FromStr("10.0.0.0/8").AddressIterExcluding(NewIPSet([]Net{Net4FromStr("10.0.0.0/16")}))(func(ip net.IP) bool {
+		if count == 0 && ip.String() != "10.1.0.0" {
+			t.Errorf("want first address 10.1.0.0 got %s", ip)
+		}
+		count++
+		return count < 5
+	})
+	if count != 5 {
+		t.Errorf("iterator did not stop when asked, got %d", count)
+	}
+}
+
+var findFirstTests = []struct {
+	xnet  string
+	pred  func(net.IP) bool
+	found string
+	ok    bool
+}{
+	{
+		"192.168.1.0/24",
+		func(ip net.IP) bool { return true },
+		"192.168.1.1", true,
+	},
+	{
+		"192.168.1.0/24",
+		func(ip net.IP) bool { return ip[3]%100 == 0 },
+		"192.168.1.100", true,
+	},
+	{
+		"192.168.1.0/24",
+		func(ip net.IP) bool { return ip[3] == 255 }, // broadcast is not usable
+		"<nil>", false,
+	},
+	{
+		"192.168.1.0/31",
+		func(ip net.IP) bool { return ip[3] == 0 }, // RFC3021
+		"192.168.1.0", true,
+	},
+	{
+		"10.0.0.0/8",
+		func(ip net.IP) bool { return ip[1] == 200 && ip[3] == 7 },
+		"10.200.0.7", true,
+	},
+}
+
+func TestNet4_FindFirst(t *testing.T) {
+	for i, tt := range findFirstTests {
+		ip, ok := Net4FromStr(tt.xnet).FindFirst(tt.pred)
+		if ok != tt.ok {
+			t.Errorf("[%d] want %t got %t", i, tt.ok, ok)
+		}
+		if ip.String() != tt.found {
+			t.Errorf("[%d] want %s got %s", i, tt.found, ip)
+		}
+	}
+
+	calls := 0
+	Net4FromStr("10.0.0.0/8").FindFirst(func(ip net.IP) bool {
+		calls++
+		return calls == 3
+	})
+	if calls != 3 {
+		t.Errorf("want the walk to stop after the first match, got %d calls", calls)
+	}
+
+	if ip, ok := (Net4{}).FindFirst(func(ip net.IP) bool { return true }); ok || ip != nil {
+		t.Errorf("empty Net4: want nil, false got %s, %t", ip, ok)
+	}
+}
+
+var subnetToMaxSizeTests = []struct {
+	xnet     string
+	maxHosts int
+	masklen  int
+	count    int
+	err      error
+}{
+	{"192.168.0.0/24", 50, 27, 8, nil},
+	{"192.168.0.0/24", 62, 26, 4, nil},
+	{"192.168.0.0/24", 254, 24, 1, nil},
+	{"192.168.0.0/24", 1000, 24, 1, nil},
+	{"192.168.0.0/24", 2, 30, 64, nil},
+	{"192.168.0.0/24", 1, 32, 256, nil},
+	{"10.0.0.0/8", 65534, 16, 256, nil},
+	{"192.168.0.0/24", 0, 0, 0, ErrBadMaskLength},
+	{"192.168.0.0/24", -5, 0, 0, ErrBadMaskLength},
+}
+
+func TestNet4_SubnetToMaxSize(t *testing.T) {
+	for i, tt := range subnetToMaxSizeTests {
+		n := Net4FromStr(tt.xnet)
+		subs, err := n.SubnetToMaxSize(tt.maxHosts)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if len(subs) != tt.count {
+			t.Errorf("[%d] want %d subnets got %d", i, tt.count, len(subs))
+			continue
+		}
+		if subs[0].IP().String() != n.IP().String() || subs[len(subs)-1].BroadcastAddress().String() != n.BroadcastAddress().String() {
+			t.Errorf("[%d] subnets %s-%s do not tile %s", i, subs[0], subs[len(subs)-1], n)
+		}
+		for _, sub := range subs {
+			if ones, _ := sub.Mask().Size(); ones != tt.masklen {
+				t.Errorf("[%d] %s: want /%d", i, sub, tt.masklen)
+				break
+			}
+			if int(sub.Count()) > tt.maxHosts {
+				t.Errorf("[%d] %s has more than %d hosts", i, sub, tt.maxHosts)
+				break
+			}
+		}
+	}
+}
+
+func TestNet4_ShuffledAddressIter(t *testing.T) {
+	key := bytes.Repeat([]byte{0x5a, 0x17, 0xc3, 0x08}, 8)
+	hm, _ := NewNet4WithHostmask(net.ParseIP("10.0.0.0"), 24, 4)
+
+	for i, n := range []Net4{
+		Net4FromStr("192.168.1.0/24"),
+		Net4FromStr("10.0.0.0/20"),
+		Net4FromStr("192.168.1.0/25"),
+		Net4FromStr("192.168.1.0/30"),
+		Net4FromStr("192.168.1.0/31"),
+		Net4FromStr("192.168.1.1/32"),
+		hm,
+	} {
+		want := n.Enumerate(0, 0)
+		seen := map[string]int{}
+		got := []net.IP{}
+		n.ShuffledAddressIter(bytes.NewReader(key))(func(ip net.IP) bool {
+			if !n.Contains(ip) {
+				t.Errorf("[%d] %s is not in %s", i, ip, n)
+			}
+			seen[ip.String()]++
+			got = append(got, ip)
+			return true
+		})
+		if len(got) != len(want) {
+			t.Errorf("[%d] %s: want %d addresses got %d", i, n, len(want), len(got))
+		}
+		for _, ip := range want {
+			if seen[ip.String()] != 1 {
+				t.Errorf("[%d] %s: %s seen %d times", i, n, ip, seen[ip.String()])
+			}
+		}
+
+		if len(want) > 8 {
+			inOrder := true
+			for j := range want {
+				if !got[j].Equal(want[j]) {
+					inOrder = false
+					break
+				}
+			}
+			if inOrder {
+				t.Errorf("[%d] %s: addresses were not shuffled", i, n)
+			}
+		}
+	}
+
+	count := 0
+	Net4FromStr("10.0.0.0/8").ShuffledAddressIter(nil)(func(ip net.IP) bool {
+		count++
+		return count < 10
+	})
+	if count != 10 {
+		t.Errorf("iterator did not stop when asked, got %d", count)
+	}
+
+	count = 0
+	Net4FromStr("10.0.0.0/24").ShuffledAddressIter(bytes.NewReader([]byte{1, 2, 3}))(func(ip net.IP) bool {
+		count++
+		return true
+	})
+	if count != 0 {
+		t.Errorf("want nothing from a short key reader, got %d addresses", count)
+	}
+}
+
+var descendantsAtDepthTests = []struct {
+	xnet  string
+	depth int
+	first string
+	last  string
+	count int
+	err   error
+}{
+	{"192.168.1.0/24", 2, "192.168.1.0/26", "192.168.1.192/26", 4, nil},
+	{"192.168.1.0/24", 1, "192.168.1.0/25", "192.168.1.128/25", 2, nil},
+	{"192.168.1.0/24", 0, "192.168.1.0/24", "192.168.1.0/24", 1, nil},
+	{"192.168.1.0/24", 8, "192.168.1.0/32", "192.168.1.255/32", 256, nil},
+	{"0.0.0.0/0", 4, "0.0.0.0/4", "240.0.0.0/4", 16, nil},
+	{"192.168.1.0/24", 9, "", "", 0, ErrBadMaskLength},
+	{"192.168.1.0/24", -1, "", "", 0, ErrBadMaskLength},
+}
+
+func TestNet4_DescendantsAtDepth(t *testing.T) {
+	for i, tt := range descendantsAtDepthTests {
+		subs, err := Net4FromStr(tt.xnet).DescendantsAtDepth(tt.depth)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if len(subs) != tt.count {
+			t.Errorf("[%d] want %d subnets got %d", i, tt.count, len(subs))
+			continue
+		}
+		if subs[0].String() != tt.first || subs[len(subs)-1].String() != tt.last {
+			t.Errorf("[%d] want %s ... %s got %s ... %s", i, tt.first, tt.last, subs[0], subs[len(subs)-1])
+		}
+	}
+}
+
+var canAggregateWithTests = []struct {
+	a, b string
+	ok   bool
+}{
+	{"10.0.0.0/24", "10.0.1.0/24", true},
+	{"10.0.1.0/24", "10.0.2.0/24", false}, // adjacent but not siblings
+	{"10.0.0.0/24", "10.0.2.0/24", false},
+	{"10.0.0.0/16", "10.0.5.0/24", true},
+	{"10.0.0.0/24", "10.0.0.0/24", true},
+	{"10.0.0.0/24", "10.0.1.0/25", false},
+	{"10.0.0.0/23", "10.0.2.0/24", false},
+	{"0.0.0.0/1", "128.0.0.0/1", true},
+	{"192.168.1.4/32", "192.168.1.5/32", true},
+	{"192.168.1.5/32", "192.168.1.6/32", false},
+}
+
+func TestNet4_CanAggregateWith(t *testing.T) {
+	for i, tt := range canAggregateWithTests {
+		a, b := Net4FromStr(tt.a), Net4FromStr(tt.b)
+		if ok := a.CanAggregateWith(b); ok != tt.ok {
+			t.Errorf("[%d] %s, %s: want %t got %t", i, a, b, tt.ok, ok)
+		}
+		if ok := b.CanAggregateWith(a); ok != tt.ok {
+			t.Errorf("[%d] %s, %s: want %t got %t", i, b, a, tt.ok, ok)
+		}
+	}
+	if (Net4{}).CanAggregateWith(Net4FromStr("10.0.0.0/8")) {
+		t.Errorf("empty Net4 should not aggregate")
+	}
+}
+
+var subnetByBitsTests = []struct {
+	xnet     string
+	segments []SubnetBits
+	out      string
+	err      error
+}{
+	{"10.0.0.0/16", []SubnetBits{{4, 2}, {4, 5}}, "10.0.37.0/24", nil},
+	{"10.0.0.0/8", []SubnetBits{{4, 15}, {4, 0}, {8, 200}}, "10.240.200.0/24", nil},
+	{"10.0.0.0/16", []SubnetBits{{2, 3}, {0, 0}, {6, 1}}, "10.0.193.0/24", nil},
+	{"10.0.0.0/16", []SubnetBits{}, "10.0.0.0/16", nil},
+	{"10.0.0.0/16", []SubnetBits{{16, 65535}}, "10.0.255.255/32", nil},
+	{"0.0.0.0/0", []SubnetBits{{8, 192}, {8, 168}}, "192.168.0.0/16", nil},
+	{"10.0.0.0/16", []SubnetBits{{8, 1}, {9, 1}}, "", ErrBadMaskLength},
+	{"10.0.0.0/16", []SubnetBits{{-1, 0}}, "", ErrBadMaskLength},
+	{"10.0.0.0/16", []SubnetBits{{4, 16}}, "", ErrAddressOutOfRange},
+	{"10.0.0.0/16", []SubnetBits{{0, 1}}, "", ErrAddressOutOfRange},
+}
+
+func TestNet4_SubnetByBits(t *testing.T) {
+	for i, tt := range subnetByBitsTests {
+		n, err := Net4FromStr(tt.xnet).SubnetByBits(tt.segments)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err == nil && n.String() != tt.out {
+			t.Errorf("[%d] want %s got %s", i, tt.out, n)
+		}
+	}
+}
+
+var gapBetweenTests = []struct {
+	a, b string
+	gap  []string
+	err  error
+}{
+	{"10.0.0.0/24", "10.0.4.0/24", []string{"10.0.1.0/24", "10.0.2.0/23"}, nil},
+	{"10.0.0.0/24", "10.0.1.0/24", []string{}, nil},
+	{"10.0.0.0/25", "10.0.1.0/24", []string{"10.0.0.128/25"}, nil},
+	{"10.0.0.0/30", "10.0.0.8/30", []string{"10.0.0.4/30"}, nil},
+	{"10.0.0.0/32", "10.0.0.2/32", []string{"10.0.0.1/32"}, nil},
+	{"0.0.0.0/1", "224.0.0.0/3", []string{"128.0.0.0/2", "192.0.0.0/3"}, nil},
+	{"10.0.4.0/24", "10.0.0.0/24", nil, ErrNoValidRange},
+	{"10.0.0.0/16", "10.0.4.0/24", nil, ErrNoValidRange},
+	{"10.0.0.0/24", "10.0.0.0/24", nil, ErrNoValidRange},
+}
+
+func TestGapBetween(t *testing.T) {
+	for i, tt := range gapBetweenTests {
+		gap, err := GapBetween(Net4FromStr(tt.a), Net4FromStr(tt.b))
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if len(gap) != len(tt.gap) {
+			t.Errorf("[%d] want %v got %v", i, tt.gap, gap)
+			continue
+		}
+		for j, n := range gap {
+			if n.String() != tt.gap[j] {
+				t.Errorf("[%d] network %d: want %s got %s", i, j, tt.gap[j], n)
+			}
+		}
+	}
+
+	if _, err := GapBetween(Net4{}, Net4FromStr("10.0.0.0/8")); err != ErrNoValidRange {
+		t.Errorf("empty Net4: want ErrNoValidRange got %v", err)
+	}
+}
+
+var nextFreeAcrossTests = []struct {
+	pools []string
+	used  []string
+	ip    string
+	pool  string
+	err   error
+}{
+	{[]string{"10.0.0.0/24"}, []string{}, "10.0.0.1", "10.0.0.0/24", nil},
+	{[]string{"10.0.0.0/24"}, []string{"10.0.0.0/25"}, "10.0.0.128", "10.0.0.0/24", nil},
+	{[]string{"10.0.0.0/24", "10.0.1.0/24"}, []string{"10.0.0.0/24"}, "10.0.1.1", "10.0.1.0/24", nil},
+	{[]string{"10.0.0.0/24", "10.0.1.0/24"}, []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30"}, "10.0.0.8", "10.0.0.0/24", nil},
+	{[]string{"10.0.0.0/30", "192.168.0.0/16"}, []string{"10.0.0.1/32", "10.0.0.2/32", "192.168.0.0/17"}, "192.168.128.0", "192.168.0.0/16", nil},
+	{[]string{"10.0.0.0/31"}, []string{"10.0.0.0/32"}, "10.0.0.1", "10.0.0.0/31", nil},
+	{[]string{"10.0.0.0/24", "10.0.1.0/24"}, []string{"10.0.0.0/23"}, "", "", ErrInsufficientSpace},
+	{[]string{"10.0.0.0/30"}, []string{"10.0.0.1/32", "10.0.0.2/32"}, "", "", ErrInsufficientSpace},
+	{[]string{}, []string{}, "", "", ErrInsufficientSpace},
+}
+
+func TestNextFreeAcross(t *testing.T) {
+	for i, tt := range nextFreeAcrossTests {
+		pools := []Net4{}
+		for _, s := range tt.pools {
+			pools = append(pools, Net4FromStr(s))
+		}
+		ip, pool, err := NextFreeAcross(pools, newIPSetFromStrings(tt.used))
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if ip.String() != tt.ip || pool.String() != tt.pool {
+			t.Errorf("[%d] want %s from %s got %s from %s", i, tt.ip, tt.pool, ip, pool)
+		}
+	}
+
+	hm, _ := NewNet4WithHostmask(net.ParseIP("10.0.0.0"), 24, 4)
+	used := NewIPSet([]Net{Net4FromStr("10.0.0.0/29")})
+	want, _ := hm.FindFirst(func(ip net.IP) bool { return !used.Contains(ip) })
+	if ip, _, err := NextFreeAcross([]Net4{hm}, used); err != nil || want == nil || !ip.Equal(want) {
+		t.Errorf("hostmask: want %s got %s, %v", want, ip, err)
+	}
+
+	if ip, _, err := NextFreeAcross([]Net4{Net4FromStr("10.0.0.0/30")}, nil); err != nil || ip.String() != "10.0.0.1" {
+		t.Errorf("nil used set: want 10.0.0.1 got %s, %v", ip, err)
+	}
+}
+
+func TestNet4_TableRow(t *testing.T) {
+	rows := []string{
+		Net4FromStr("192.168.1.0/24").TableRow(),
+		Net4FromStr("10.0.0.0/8").TableRow(),
+		Net4FromStr("255.255.255.255/32").TableRow(),
+	}
+	want := []string{
+		"192.168.1.0/24      192.168.1.1      192.168.1.254           254",
+		"10.0.0.0/8          10.0.0.1         10.255.255.254     16777214",
+		"255.255.255.255/32  255.255.255.255  255.255.255.255           1",
+	}
+	for i := range rows {
+		if rows[i] != want[i] {
+			t.Errorf("[%d] want %q got %q", i, want[i], rows[i])
+		}
+	}
+	if len(Net4FromStr("0.0.0.0/0").TableRow()) != len(rows[0]) {
+		t.Errorf("rows should be fixed-width")
+	}
+}
+
+var alignNetTests = []struct {
+	ip      string
+	masklen int
+	down    string
+	up      string
+}{
+	{"10.0.0.77", 26, "10.0.0.64/26", "10.0.0.128/26"},
+	{"10.0.0.64", 26, "10.0.0.64/26", "10.0.0.64/26"},
+	{"10.0.0.1", 24, "10.0.0.0/24", "10.0.1.0/24"},
+	{"10.0.255.1", 24, "10.0.255.0/24", "10.1.0.0/24"},
+	{"10.0.0.1", 32, "10.0.0.1/32", "10.0.0.1/32"},
+	{"10.0.0.1", 0, "0.0.0.0/0", "<nil>"},
+	{"0.0.0.0", 0, "0.0.0.0/0", "0.0.0.0/0"},
+	{"255.255.255.1", 24, "255.255.255.0/24", "<nil>"},
+	{"::ffff:10.0.0.77", 26, "10.0.0.64/26", "10.0.0.128/26"},
+	{"10.0.0.1", 33, "<nil>", "<nil>"},
+	{"10.0.0.1", -1, "<nil>", "<nil>"},
+	{"2001:db8::1", 24, "<nil>", "<nil>"},
+}
+
+func TestAlignNet(t *testing.T) {
+	for i, tt := range alignNetTests {
+		ip := net.ParseIP(tt.ip)
+		if n := AlignNet(ip, tt.masklen); n.String() != tt.down {
+			t.Errorf("[%d] AlignNet(%s, %d): want %s got %s", i, tt.ip, tt.masklen, tt.down, n)
+		}
+		if n := AlignNetUp(ip, tt.masklen); n.String() != tt.up {
+			t.Errorf("[%d] AlignNetUp(%s, %d): want %s got %s", i, tt.ip, tt.masklen, tt.up, n)
+		}
+	}
+}
+
 func compareNet4ArraysToStringRepresentation(a []Net4, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -2,9 +2,13 @@ package iplib
 
 import (
 	"crypto/rand"
+	"io"
 	"math"
+	"math/big"
 	"net"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"lukechampine.com/uint128"
@@ -68,6 +72,54 @@ func Net6FromStr(s string) Net6 {
 	return Net6{}
 }
 
+// Net6FromStrErr is like Net6FromStr but returns an error rather than an
+// empty Net6 if the string can't be used. If the string is not a valid CIDR
+// or is a v4 network the error will be a *ParseError
+func Net6FromStrErr(s string) (Net6, error) {
+	_, n, err := ParseCIDR(s)
+	if err != nil {
+		return Net6{}, err
+	}
+	if n6, ok := n.(Net6); ok {
+		return n6, nil
+	}
+	return Net6{}, &ParseError{Input: s, Token: s, Err: &net.ParseError{Type: "IPv6 CIDR address", Text: s}}
+}
+
+// Net6FromPacked is the inverse of Net6.Packed(), returning the Net6 encoded
+// in b. If the masklen and hostmask bytes do not describe a valid Net6
+// ErrBadMaskLength is returned
+func Net6FromPacked(b [18]byte) (Net6, error) {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, b[:16])
+	if int(b[16])+int(b[17]) > 128 {
+		return Net6{}, ErrBadMaskLength
+	}
+	n := NewNet6(ip, int(b[16]), int(b[17]))
+	if n.IP() == nil {
+		return Net6{}, ErrBadMaskLength
+	}
+	return n, nil
+}
+
+// BroadcastAddress returns the address with every host bit set, which is
+// the upper bound of the netblock. IPv6 has no concept of a broadcast address
+// but having it allows the Net interface to expose the end of a netblock for
+// both versions. Unlike LastAddress() this ignores any hostmask, so for
+// 2001:db8::/56 with a hostmask of 60 it returns 2001:db8:0:ff:ffff:ffff:ffff:ffff
+// rather than 2001:db8:0:ff:f00::
+func (n Net6) BroadcastAddress() net.IP {
+	if n.IP() == nil {
+		return nil
+	}
+	xip := make(net.IP, len(n.IP()))
+	wc := n.wildcard()
+	for pos := range xip {
+		xip[pos] = n.IP()[pos] | wc[pos]
+	}
+	return xip
+}
+
 // Contains returns true if ip is contained in the represented netblock
 func (n Net6) Contains(ip net.IP) bool {
 	return n.IPNet.Contains(ip)
@@ -95,7 +147,10 @@ func (n Net6) Controls(ip net.IP) bool {
 	return true
 }
 
-// Count returns the number of IP addresses in the represented netblock
+// Count returns the number of IP addresses in the represented netblock. Only
+// addresses outside of the hostmask are counted. The count is returned as a
+// uint128.Uint128, the same type used by IncrementIP6WithinHostmask() and
+// friends, so no big.Int is involved; use its Big() method if one is needed
 func (n Net6) Count() uint128.Uint128 {
 	ones, all := n.Mask().Size()
 
@@ -180,11 +235,96 @@ func (n Net6) Enumerate(size, offset int) []net.IP {
 	return addrs
 }
 
+// EnumerateStrings is a convenience wrapper around Enumerate() which returns
+// each address formatted as a string rather than as a net.IP. Addresses are
+// in the RFC5952 canonical form produced by net.IP.String()
+func (n Net6) EnumerateStrings(size, offset int) []string {
+	addrs := n.Enumerate(size, offset)
+	if addrs == nil {
+		return nil
+	}
+	strs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		strs[i] = addr.String()
+	}
+	return strs
+}
+
+// EnumerateStringsIter returns an iterator, with the same signature as
+// iter.Seq[string], yielding every usable address in n, within the hostmask,
+// formatted as in EnumerateStrings(). Unlike EnumerateStrings() it does not
+// materialize the addresses and is not limited to MaxUint32 results
+func (n Net6) EnumerateStringsIter() func(yield func(string) bool) {
+	return func(yield func(string) bool) {
+		if n.IP() == nil {
+			return
+		}
+		last := n.LastAddress()
+		ip := n.FirstAddress()
+		for {
+			if !yield(ip.String()) || ip.Equal(last) {
+				return
+			}
+			var err error
+			if ip, err = NextIP6WithinHostmask(ip, n.Hostmask); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ExpandedString returns n in CIDR notation with the network address fully
+// expanded, as ExpandIP6() would render it, such as
+// "2001:0db8:0000:0000:0000:0000:0000:0000/32". IPv4-mapped networks are also
+// expanded rather than being shown in dotted-decimal form as String() does.
+// An empty Net6 returns "<nil>"
+func (n Net6) ExpandedString() string {
+	if n.IP() == nil {
+		return "<nil>"
+	}
+	ones, _ := n.Mask().Size()
+	return ExpandIP6(n.IP().To16()) + "/" + strconv.Itoa(ones)
+}
+
+// Family returns the address family of the enclosed netblock as a string,
+// "IPv6" in this case
+func (n Net6) Family() string {
+	return "IPv6"
+}
+
 // FirstAddress returns the first usable address for the represented network
 func (n Net6) FirstAddress() net.IP {
 	return CopyIP(n.IP())
 }
 
+// Hash returns a 64-bit FNV-1a hash of the network, computed over its IP
+// version, network address, mask length and hostmask length. The hash is
+// stable across runs and platforms, so it may be used to deterministically
+// distribute networks across shards without first rendering them to strings
+func (n Net6) Hash() uint64 {
+	ones, _ := n.Mask().Size()
+	hmlen, _ := n.Hostmask.Size()
+	return hashNet(IP6Version, n.IP().To16(), ones, hmlen)
+}
+
+// IsAssignable returns true if ip could be handed out to a host from this
+// netblock: it must fall inside the netblock, must not have any bits set
+// inside the hostmask (i.e. it would be returned by Enumerate()) and must not
+// be the RFC4291 Subnet-Router anycast address, which is the network address
+// when there is no hostmask. Following RFC6164 the anycast address is not
+// excluded from /127 networks, and the single address of a /128 is always
+// assignable
+func (n Net6) IsAssignable(ip net.IP) bool {
+	if !n.Contains(ip) {
+		return false
+	}
+	if _, ok := hostmaskOrdinal(ip.To16(), n.Hostmask); !ok {
+		return false
+	}
+	ones, _ := n.Mask().Size()
+	return ones >= 127 || !IsSubnetRouterAnycast(ip, ones)
+}
+
 // LastAddress returns the last usable address for the represented network
 func (n Net6) LastAddress() net.IP {
 	xip, _ := n.finalAddress()
@@ -196,12 +336,52 @@ func (n Net6) Mask() net.IPMask {
 	return n.IPNet.Mask
 }
 
+// HostmaskString returns the CIDR notation of the enclosed network followed by
+// a plus-sign and the length of the hostmask, e.g. 2001:db8::/56+60. If there
+// is no hostmask the result is identical to String(), except that a network
+// inside ::ffff:0:0/96 keeps its v6 form and prefix length, e.g.
+// ::ffff:192.168.0.0/120 rather than 192.168.0.0/24. Unlike String() this
+// form can be parsed back into an identical Net6, see UnmarshalText()
+func (n Net6) HostmaskString() string {
+	s := n.String()
+	if n.IP().To4() != nil {
+		ones, _ := n.Mask().Size()
+		s = "::ffff:" + ForceIP4(n.IP()).String() + "/" + strconv.Itoa(ones)
+	}
+
+	hmlen, _ := n.Hostmask.Size()
+	if hmlen == 0 {
+		return s
+	}
+	return s + "+" + strconv.Itoa(hmlen)
+}
+
 // IP returns the network address for the represented network, e.g.
 // the lowest IP address in the given block
 func (n Net6) IP() net.IP {
 	return n.IPNet.IP
 }
 
+// MarshalText implements encoding.TextMarshaler, rendering the Net6 in the
+// form returned by HostmaskString() so that the hostmask is preserved
+func (n Net6) MarshalText() ([]byte, error) {
+	return []byte(n.HostmaskString()), nil
+}
+
+// Midpoint returns the address halfway through the represented network,
+// calculated as the network address plus half the total number of addresses
+// covered by the netmask, so the midpoint of 2001:db8::/64 is
+// 2001:db8::8000:0:0:0. The hostmask is not considered. The midpoint of a
+// /128 is the address itself
+func (n Net6) Midpoint() net.IP {
+	ones, all := n.Mask().Size()
+	if ones == all {
+		return CopyIP(n.IP())
+	}
+	z := uint128.From64(1).Lsh(uint(all - ones - 1))
+	return IncrementIP6By(n.IP(), z)
+}
+
 // NextIP takes a net.IP as an argument and attempts to increment it by one
 // within the boundary of allocated network-bytes. If the resulting address is
 // outside of the range of the represented network it will return an empty
@@ -227,6 +407,19 @@ func (n Net6) NextNet(masklen int) Net6 {
 	return NewNet6(xip, masklen, hmlen)
 }
 
+// Packed returns n in a fixed-width binary form suitable for fixed-size
+// records: the 16 bytes of the network address followed by one byte holding
+// the netmask length and one holding the hostmask length. See
+// Net6FromPacked()
+func (n Net6) Packed() [18]byte {
+	var b [18]byte
+	copy(b[:16], n.IP().To16())
+	ones, _ := n.Mask().Size()
+	hmlen, _ := n.Hostmask.Size()
+	b[16], b[17] = byte(ones), byte(hmlen)
+	return b
+}
+
 // PreviousIP takes a net.IP as an argument and attempts to decrement it by
 // one within the boundary of the allocated network-bytes. If the resulting
 // address is outside the range of the represented netblock it will return an
@@ -254,11 +447,122 @@ func (n Net6) PreviousNet(masklen int) Net6 {
 }
 
 // RandomIP returns a random address from this Net6. It uses crypto/rand and
-// so is not the most performant implementation possible
+// so is not the most performant implementation possible. It is equivalent to
+// RandomIPFrom(nil), use that to supply a different source of randomness
 func (n Net6) RandomIP() net.IP {
-	bigz, _ := rand.Int(rand.Reader, n.Count().Big())
+	ip, _ := n.RandomIPFrom(nil)
+	return ip
+}
+
+// RandomIPFrom returns a random address from this Net6, drawing randomness
+// from r. This allows a deterministic reader to be substituted in tests, or
+// the entropy source to be chosen explicitly. If r is nil crypto/rand.Reader
+// is used, and if r fails its error is returned
+func (n Net6) RandomIPFrom(r io.Reader) (net.IP, error) {
+	if r == nil {
+		r = rand.Reader
+	}
+	bigz, err := rand.Int(r, n.Count().Big())
+	if err != nil {
+		return nil, err
+	}
 	z := uint128.FromBig(bigz)
-	return IncrementIP6By(n.FirstAddress(), z)
+	return IncrementIP6By(n.FirstAddress(), z), nil
+}
+
+// RangeString returns a human-readable description of the usable addresses
+// in n, giving the first and last address in RFC5952 canonical form and the
+// number of usable addresses within the hostmask, e.g. "2001:db8:: -
+// 2001:db8::ff (256 hosts)". String() continues to return the network in CIDR
+// notation
+func (n Net6) RangeString() string {
+	if n.IP() == nil {
+		return "<nil>"
+	}
+	return formatRangeString(n.FirstAddress(), n.LastAddress(), n.Count().Big().String())
+}
+
+// Remaining returns the number of usable addresses from ip, inclusive,
+// through the last address in the netblock, counting only the addresses that
+// can be reached by stepping within the hostmask. If ip is not in the
+// netblock, or if it has bits set inside the hostmask, ErrAddressOutOfRange is
+// returned. As with Count() the result for ::/0 is capped at uint128.Max
+func (n Net6) Remaining(ip net.IP) (uint128.Uint128, error) {
+	if !n.Contains(ip) {
+		return uint128.Zero, ErrAddressOutOfRange
+	}
+
+	z, ok := hostmaskOrdinal(ip.To16(), n.Hostmask)
+	if !ok {
+		return uint128.Zero, ErrAddressOutOfRange
+	}
+	last, _ := hostmaskOrdinal(n.LastAddress(), n.Hostmask)
+
+	delta := last.Sub(z)
+	if delta.Equals(uint128.Max) {
+		return delta, nil
+	}
+	return delta.Add64(1), nil
+}
+
+// ReverseAddressIter returns an iterator, with the same signature as
+// iter.Seq[net.IP], which yields the usable addresses of n in descending
+// order from LastAddress() down to FirstAddress(). It is the counterpart of
+// walking the network upward with NextIP() and lets allocators hand out
+// addresses from the top of a block without reversing a slice. A hostmask is
+// honored, so addresses outside of it are skipped
+func (n Net6) ReverseAddressIter() func(yield func(net.IP) bool) {
+	return func(yield func(net.IP) bool) {
+		if n.IP() == nil {
+			return
+		}
+		first := n.FirstAddress()
+		ip := n.LastAddress()
+		for {
+			if !yield(ip) || ip.Equal(first) {
+				return
+			}
+			var err error
+			if ip, err = n.PreviousIP(ip); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ScanUnits returns an iterator yielding, in ascending order, every subnet of
+// n at unitMasklen. This is intended for chunking large networks into fixed
+// sized pieces without materializing the entire list as Subnet() would. If
+// unitMasklen is 0 it defaults to 64. If unitMasklen is shorter than the mask
+// of n, or longer than 128, ErrBadMaskLength is returned. The yielded subnets
+// do not have a hostmask.
+//
+// The iterator has the same signature as iter.Seq[Net6] and so can be ranged
+// over directly in Go 1.23 and later
+func (n Net6) ScanUnits(unitMasklen int) (func(yield func(Net6) bool), error) {
+	if unitMasklen == 0 {
+		unitMasklen = 64
+	}
+	ones, all := n.Mask().Size()
+	if unitMasklen < ones || unitMasklen > all {
+		return nil, ErrBadMaskLength
+	}
+
+	return func(yield func(Net6) bool) {
+		step := uint128.From64(1).Lsh(uint(all - unitMasklen))
+		cur := IP6ToUint128(n.IP())
+		last := cur.Or(IP6ToUint128(net.IP(n.wildcard())))
+		for {
+			if !yield(NewNet6(Uint128ToIP6(cur), unitMasklen, 0)) {
+				return
+			}
+			next := cur.AddWrap(step)
+			if next.Cmp(cur) <= 0 || next.Cmp(last) > 0 {
+				return
+			}
+			cur = next
+		}
+	}, nil
 }
 
 // String returns the CIDR notation of the enclosed network e.g. 2001:db8::/16
@@ -269,7 +573,11 @@ func (n Net6) String() string {
 // Subnet takes a CIDR mask-size as an argument and carves the current Net
 // object into subnets of that size, returning them as a []Net. The mask
 // provided must be a larger-integer than the current mask. If set to 0 Subnet
-// will carve the network in half. Hostmask must be provided if desired
+// will carve the network in half. Hostmask must be provided if desired.
+//
+// The subnets tile the entire netmask of the current Net and are always
+// returned in ascending order as defined by CompareNets, regardless of the
+// hostmask of either the current Net or the subnets
 func (n Net6) Subnet(netmasklen, hostmasklen int) ([]Net6, error) {
 	ones, all := n.Mask().Size()
 	if netmasklen == 0 {
@@ -282,15 +590,20 @@ func (n Net6) Subnet(netmasklen, hostmasklen int) ([]Net6, error) {
 	mask := net.CIDRMask(netmasklen, all)
 	netlist := []Net6{{IPNet: net.IPNet{IP: n.IP(), Mask: mask}, Hostmask: NewHostMask(hostmasklen)}}
 
-	for CompareIPs(netlist[len(netlist)-1].LastAddress(), n.LastAddress()) == -1 {
-		xip, _ := NextIP6WithinHostmask(netlist[len(netlist)-1].LastAddress(), n.Hostmask)
-		if len(xip) == 0 || xip == nil {
+	// step through the parent one subnet-sized block at a time, stopping at
+	// the end of the parent's netmask or if we wrap around the address space
+	step := uint128.From64(1).Lsh(uint(all - netmasklen))
+	cur := IP6ToUint128(n.IP())
+	last := cur.Or(IP6ToUint128(net.IP(n.wildcard())))
+	for {
+		next := cur.AddWrap(step)
+		if next.Cmp(cur) <= 0 || next.Cmp(last) > 0 {
 			return netlist, nil
 		}
-		ng := net.IPNet{IP: xip, Mask: mask}
+		ng := net.IPNet{IP: Uint128ToIP6(next), Mask: mask}
 		netlist = append(netlist, Net6{ng, NewHostMask(hostmasklen)})
+		cur = next
 	}
-	return netlist, nil
 }
 
 // Supernet takes a CIDR mask-size as an argument and returns a Net object
@@ -312,12 +625,107 @@ func (n Net6) Supernet(netmasklen, hostmasklen int) (Net6, error) {
 	return Net6{ng, NewHostMask(hostmasklen)}, nil
 }
 
+// ToIPNet returns a copy of n as a *net.IPNet in 16-byte form, suitable for
+// handing to the standard library or other packages. The hostmask has no
+// equivalent in net.IPNet and is dropped. It returns nil if n is empty
+func (n Net6) ToIPNet() *net.IPNet {
+	if n.IP() == nil {
+		return nil
+	}
+	mask := make(net.IPMask, net.IPv6len)
+	copy(mask, n.Mask())
+	return &net.IPNet{IP: CopyIP(n.IP().To16()), Mask: mask}
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, accepting either a plain
+// v6 CIDR string or the CIDR+hostmask form produced by HostmaskString(),
+// including v4-mapped networks such as ::ffff:192.168.0.0/120. If
+// the string cannot be parsed as a v6 network a *ParseError is returned, and
+// if the netmask and hostmask together are too long ErrBadMaskLength
+func (n *Net6) UnmarshalText(text []byte) error {
+	s := string(text)
+
+	cidr, hm, hasHostmask := strings.Cut(s, "+")
+	hmlen := 0
+	if hasHostmask {
+		var err error
+		if hmlen, err = parseDecimal(hm, 128); err != nil {
+			return &ParseError{Input: s, Token: hm, Err: &net.ParseError{Type: "CIDR address", Text: s}}
+		}
+	}
+
+	n6, err := Net6FromStrErr(cidr)
+	if err != nil {
+		// ParseCIDR() treats ::ffff:0:0/96 networks as v4, so accept them here
+		// as long as they were written in v6 notation
+		_, ipnet, perr := net.ParseCIDR(cidr)
+		if perr != nil || ipnet.IP.To4() == nil || !strings.Contains(cidr, ":") {
+			return err
+		}
+		n6 = Net6{IPNet: *ipnet}
+	}
+
+	netmasklen, _ := n6.Mask().Size()
+	n6 = NewNet6(n6.IP(), netmasklen, hmlen)
+	if n6.IP() == nil {
+		return ErrBadMaskLength
+	}
+
+	*n = n6
+	return nil
+}
+
+// UsableCount implements the Net interface, returning Count() as a *big.Int.
+// As with Count() a /127 is considered to have 2 usable addresses per RFC6164
+// and a /128 to have 1. Unlike Count() it is not limited to 128 bits, so ::/0
+// correctly returns 2^128 rather than uint128.Max
+func (n Net6) UsableCount() *big.Int {
+	if n.IP() == nil {
+		return big.NewInt(0)
+	}
+	ones, _ := n.Mask().Size()
+	if hmlen, _ := n.Hostmask.Size(); ones == 0 && hmlen == 0 {
+		return new(big.Int).Lsh(big.NewInt(1), 128)
+	}
+	return n.Count().Big()
+}
+
+// Utilization returns the fraction of n's address space, from 0.0 to 1.0,
+// which is covered by the networks in used. The hostmask is not considered,
+// and used is aggregated and clipped to n first so that overlapping networks,
+// or networks extending beyond n, do not inflate the result. The calculation
+// is done with a big.Rat so that small allocations within very large blocks
+// are not lost to rounding until the final conversion to float64
+func (n Net6) Utilization(used []Net6) float64 {
+	nets := make([]Net, len(used))
+	for i, u := range used {
+		nets[i] = u
+	}
+	return utilization(n, nets)
+}
+
 // Version returns the version of IP for the enclosed netblock as an int. 6
 // in this case
 func (n Net6) Version() int {
 	return IP6Version
 }
 
+// WouldExitBlock returns true if incrementing ip by count, as
+// IncrementIP6By() would, produces an address outside of the netblock. It
+// also returns true if ip is not in the netblock to begin with. Note that the
+// increment is a plain addition, the hostmask is not considered
+func (n Net6) WouldExitBlock(ip net.IP, count uint128.Uint128) bool {
+	if !n.Contains(ip) {
+		return true
+	}
+	z := IP6ToUint128(ip)
+	sum := z.AddWrap(count)
+	if sum.Cmp(z) < 0 {
+		return true // wrapped past the end of the address space
+	}
+	return !n.Contains(Uint128ToIP6(sum))
+}
+
 // return true if 'ip' is within the hostmask of n
 func (n Net6) contained(ip net.IP) bool {
 	b, pos := n.Hostmask.BoundaryByte()
@@ -1,9 +1,13 @@
 package iplib
 
 import (
+	"bytes"
+	"encoding/json"
 	"net"
 	"sort"
 	"testing"
+
+	"lukechampine.com/uint128"
 )
 
 var NewNet6Tests = []struct {
@@ -66,6 +70,98 @@ func TestNet6FromStr(t *testing.T) {
 	}
 }
 
+var hostmaskString6Tests = []struct {
+	in  Net6
+	out string
+}{
+	{NewNet6(net.ParseIP("2001:db8::"), 64, 0), "2001:db8::/64"},
+	{NewNet6(net.ParseIP("2001:db8::"), 56, 60), "2001:db8::/56+60"},
+	{NewNet6(net.ParseIP("2001:db8::"), 32, 1), "2001:db8::/32+1"},
+	{NewNet6(net.ParseIP("2001:db8::1"), 128, 0), "2001:db8::1/128"},
+	{NewNet6(net.ParseIP("::"), 0, 127), "::/0+127"},
+	{NewNet6(net.ParseIP("::ffff:c0a8:0"), 120, 0), "::ffff:192.168.0.0/120"},
+	{NewNet6(net.ParseIP("::ffff:c0a8:0"), 96, 8), "::ffff:0.0.0.0/96+8"},
+}
+
+func TestNet6_HostmaskString(t *testing.T) {
+	for i, tt := range hostmaskString6Tests {
+		if s := tt.in.HostmaskString(); s != tt.out {
+			t.Errorf("[%d] want %s got %s", i, tt.out, s)
+		}
+	}
+}
+
+func TestNet6_MarshalTextRoundTrip(t *testing.T) {
+	for i, tt := range hostmaskString6Tests {
+		b, err := tt.in.MarshalText()
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+			continue
+		}
+		var n6 Net6
+		if err := n6.UnmarshalText(b); err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+			continue
+		}
+		if n6.HostmaskString() != tt.in.HostmaskString() {
+			t.Errorf("[%d] want %s got %s", i, tt.in.HostmaskString(), n6.HostmaskString())
+		}
+		if CompareNets(n6, tt.in) != 0 || !bytes.Equal(n6.Mask(), tt.in.Mask()) {
+			t.Errorf("[%d] want %s got %s", i, tt.in.IPNet.String(), n6.IPNet.String())
+		}
+		if n6.Count().Cmp(tt.in.Count()) != 0 {
+			t.Errorf("[%d] want count %s got %s", i, tt.in.Count(), n6.Count())
+		}
+	}
+}
+
+func TestNet6_MarshalJSON(t *testing.T) {
+	type wrapper struct {
+		Net Net6 `json:"net"`
+	}
+	in := wrapper{NewNet6(net.ParseIP("2001:db8::"), 56, 60)}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(b) != `{"net":"2001:db8::/56+60"}` {
+		t.Errorf("got unexpected JSON %s", b)
+	}
+	out := wrapper{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.Net.HostmaskString() != in.Net.HostmaskString() {
+		t.Errorf("want %s got %s", in.Net.HostmaskString(), out.Net.HostmaskString())
+	}
+}
+
+var unmarshalText6Tests = []struct {
+	in  string
+	err bool
+}{
+	{"2001:db8::/64+", true},
+	{"2001:db8::/64+x", true},
+	{"2001:db8::/64+129", true},
+	{"2001:db8::/64+64", true},
+	{"2001:db8::+8", true},
+	{"192.168.0.0/24", true},
+	{"192.168.0.0/24+4", true},
+	{"::ffff:192.168.0.0/120", false},
+	{"::ffff:c0a8:0/120+4", false},
+	{"2001:db8::/64+63", false},
+}
+
+func TestNet6_UnmarshalTextErrors(t *testing.T) {
+	for i, tt := range unmarshalText6Tests {
+		var n6 Net6
+		err := n6.UnmarshalText([]byte(tt.in))
+		if (err != nil) != tt.err {
+			t.Errorf("[%d] %s: want error %t got '%v'", i, tt.in, tt.err, err)
+		}
+	}
+}
+
 var Net6Tests = []struct {
 	ip          string
 	firstaddr   string
@@ -237,6 +333,15 @@ func TestNet6_Version(t *testing.T) {
 	}
 }
 
+func TestNet6_Family(t *testing.T) {
+	for i, tt := range Net6Tests {
+		ipn := NewNet6(net.ParseIP(tt.ip), tt.netmasklen, tt.hostmask)
+		if ipn.Family() != "IPv6" {
+			t.Errorf("[%d] want family IPv6, got %s", i, ipn.Family())
+		}
+	}
+}
+
 func TestNet6_Count(t *testing.T) {
 	for i, tt := range Net6Tests {
 		ipn := NewNet6(net.ParseIP(tt.ip), tt.netmasklen, tt.hostmask)
@@ -289,6 +394,47 @@ func TestNet6_LastAddress(t *testing.T) {
 	}
 }
 
+func TestNet6_BroadcastAddress(t *testing.T) {
+	for i, tt := range Net6Tests {
+		ipn := NewNet6(net.ParseIP(tt.ip), tt.netmasklen, tt.hostmask)
+		if ipn.IP() == nil {
+			continue
+		}
+		lastAddr := NewNet6(net.ParseIP(tt.ip), tt.netmasklen, 0).LastAddress()
+
+		if v := CompareIPs(lastAddr, ipn.BroadcastAddress()); v != 0 {
+			t.Errorf("[%d] broadcast address: want %s got %s", i, lastAddr, ipn.BroadcastAddress())
+		}
+	}
+
+	ipn := NewNet6(net.ParseIP("2001:db8::"), 56, 60)
+	if bc := ipn.BroadcastAddress().String(); bc != "2001:db8:0:ff:ffff:ffff:ffff:ffff" {
+		t.Errorf("hostmasked broadcast address: want 2001:db8:0:ff:ffff:ffff:ffff:ffff got %s", bc)
+	}
+	if bc := (Net6{}).BroadcastAddress(); bc != nil {
+		t.Errorf("empty Net6: want nil got %s", bc)
+	}
+}
+
+var midpoint6Tests = []struct {
+	in       Net6
+	midpoint string
+}{
+	{Net6FromStr("2001:db8::/64"), "2001:db8::8000:0:0:0"},
+	{NewNet6(net.ParseIP("2001:db8::"), 64, 56), "2001:db8::8000:0:0:0"},
+	{Net6FromStr("2001:db8::/127"), "2001:db8::1"},
+	{Net6FromStr("2001:db8::1/128"), "2001:db8::1"},
+	{Net6FromStr("::/0"), "8000::"},
+}
+
+func TestNet6_Midpoint(t *testing.T) {
+	for i, tt := range midpoint6Tests {
+		if addr := tt.in.Midpoint(); !net.ParseIP(tt.midpoint).Equal(addr) {
+			t.Errorf("[%d] want %s got %s", i, tt.midpoint, addr)
+		}
+	}
+}
+
 func TestNet6_BoundaryByte(t *testing.T) {
 	for i, tt := range Net6Tests {
 		ipn := NewNet6(net.ParseIP(tt.ip), tt.netmasklen, tt.hostmask)
@@ -355,6 +501,47 @@ func TestNet6_Enumerate(t *testing.T) {
 	}
 }
 
+func TestNet6_EnumerateStrings(t *testing.T) {
+	for i, tt := range enumerate6Tests {
+		n := NewNet6(tt.inaddr, tt.netmasklen, tt.hostmasklen)
+		addrlist := n.Enumerate(0, 0)
+		strs := n.EnumerateStrings(0, 0)
+		iterStrs := []string{}
+		n.EnumerateStringsIter()(func(s string) bool {
+			iterStrs = append(iterStrs, s)
+			return true
+		})
+		if len(strs) != tt.total || len(iterStrs) != tt.total {
+			t.Errorf("[%d] total want %d got %d and %d", i, tt.total, len(strs), len(iterStrs))
+			continue
+		}
+		for ii, a := range addrlist {
+			if strs[ii] != a.String() || iterStrs[ii] != a.String() {
+				t.Errorf("[%d] address %d: want %s got %s and %s", i, ii, a, strs[ii], iterStrs[ii])
+				break
+			}
+		}
+	}
+}
+
+func TestNet6_EnumerateStringsIterStop(t *testing.T) {
+	n := Net6FromStr("2001:db8::/32")
+	strs := []string{}
+	n.EnumerateStringsIter()(func(s string) bool {
+		strs = append(strs, s)
+		return len(strs) < 3
+	})
+	want := []string{"2001:db8::", "2001:db8::1", "2001:db8::2"}
+	if len(strs) != len(want) {
+		t.Fatalf("want %v got %v", want, strs)
+	}
+	for i := range want {
+		if strs[i] != want[i] {
+			t.Errorf("[%d] want %s got %s", i, want[i], strs[i])
+		}
+	}
+}
+
 var enumerate6VariableTests = []struct {
 	hostmasklen int
 	netmasklen  int
@@ -412,6 +599,109 @@ func TestNet6_EnumerateWithVariables(t *testing.T) {
 	}
 }
 
+var isAssignable6Tests = []struct {
+	inaddr string
+	hmlen  int
+	ip     net.IP
+	ok     bool
+}{
+	{"2001:db8::/64", 0, net.ParseIP("2001:db8::"), false},
+	{"2001:db8::/64", 0, net.ParseIP("2001:db8::1"), true},
+	{"2001:db8::/64", 0, net.ParseIP("2001:db8::ffff:ffff:ffff:ffff"), true},
+	{"2001:db8::/64", 0, net.ParseIP("2001:db8:0:1::1"), false},
+	{"2001:db8::/64", 0, net.ParseIP("192.168.0.1"), false},
+	{"2001:db8::/127", 0, net.ParseIP("2001:db8::"), true},
+	{"2001:db8::/127", 0, net.ParseIP("2001:db8::1"), true},
+	{"2001:db8::1/128", 0, net.ParseIP("2001:db8::1"), true},
+	{"2001:db8::/56", 60, net.ParseIP("2001:db8::"), false},
+	{"2001:db8::/56", 60, net.ParseIP("2001:db8:0:1::"), true},
+	{"2001:db8::/56", 60, net.ParseIP("2001:db8::1"), false},
+}
+
+func TestNet6_IsAssignable(t *testing.T) {
+	for i, tt := range isAssignable6Tests {
+		n := Net6FromStr(tt.inaddr)
+		if tt.hmlen > 0 {
+			ones, _ := n.Mask().Size()
+			n = NewNet6(n.IP(), ones, tt.hmlen)
+		}
+		if v := n.IsAssignable(tt.ip); v != tt.ok {
+			t.Errorf("[%d] %s in %s: want %t got %t", i, tt.ip, tt.inaddr, tt.ok, v)
+		}
+	}
+}
+
+var remaining6Tests = []struct {
+	inaddr string
+	hmlen  int
+	ip     net.IP
+	left   uint128.Uint128
+	err    error
+}{
+	{"2001:db8::/64", 0, net.ParseIP("2001:db8::"), uint128.From64(1).Lsh(64), nil},
+	{"2001:db8::/64", 0, net.ParseIP("2001:db8::ffff:ffff:ffff:fff0"), uint128.From64(16), nil},
+	{"2001:db8::/64", 0, net.ParseIP("2001:db8:0:1::"), uint128.Zero, ErrAddressOutOfRange},
+	{"2001:db8::/127", 0, net.ParseIP("2001:db8::1"), uint128.From64(1), nil},
+	{"2001:db8::/56", 60, net.ParseIP("2001:db8::"), uint128.From64(4096), nil},
+	{"2001:db8::/56", 60, net.ParseIP("2001:db8:0:ff:f00::"), uint128.From64(1), nil},
+	{"2001:db8::/56", 60, net.ParseIP("2001:db8:0:fe::"), uint128.From64(32), nil},
+	{"2001:db8::/56", 60, net.ParseIP("2001:db8::1"), uint128.Zero, ErrAddressOutOfRange},
+	{"::/0", 0, net.ParseIP("::"), uint128.Max, nil},
+	{"::/0", 0, net.ParseIP("::1"), uint128.Max, nil},
+}
+
+func TestNet6_Remaining(t *testing.T) {
+	for i, tt := range remaining6Tests {
+		n := Net6FromStr(tt.inaddr)
+		if tt.hmlen > 0 {
+			ones, _ := n.Mask().Size()
+			n = NewNet6(n.IP(), ones, tt.hmlen)
+		}
+		left, err := n.Remaining(tt.ip)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+		}
+		if !left.Equals(tt.left) {
+			t.Errorf("[%d] %s: want %s got %s", i, tt.ip, tt.left, left)
+		}
+	}
+
+	// the remaining count from the first address always matches Count()
+	for i, tt := range enumerate6Tests {
+		n := NewNet6(tt.inaddr, tt.netmasklen, tt.hostmasklen)
+		if n.IP() == nil {
+			continue
+		}
+		left, err := n.Remaining(n.FirstAddress())
+		if err != nil || !left.Equals(n.Count()) {
+			t.Errorf("[%d] want %s got %s, '%v'", i, n.Count(), left, err)
+		}
+	}
+}
+
+var wouldExitBlock6Tests = []struct {
+	inaddr string
+	ip     net.IP
+	count  uint128.Uint128
+	exits  bool
+}{
+	{"2001:db8::/64", net.ParseIP("2001:db8::"), uint128.Zero, false},
+	{"2001:db8::/64", net.ParseIP("2001:db8::"), uint128.New(0xffffffffffffffff, 0), false},
+	{"2001:db8::/64", net.ParseIP("2001:db8::1"), uint128.New(0xffffffffffffffff, 0), true},
+	{"2001:db8::/64", net.ParseIP("2001:db8:0:1::"), uint128.Zero, true},
+	{"ffff::/16", net.ParseIP("ffff::1"), uint128.Max, true},
+	{"::/0", net.ParseIP("::"), uint128.Max, false},
+	{"::/0", net.ParseIP("::1"), uint128.Max, true},
+}
+
+func TestNet6_WouldExitBlock(t *testing.T) {
+	for i, tt := range wouldExitBlock6Tests {
+		if v := Net6FromStr(tt.inaddr).WouldExitBlock(tt.ip, tt.count); v != tt.exits {
+			t.Errorf("[%d] %s + %s: want %t got %t", i, tt.ip, tt.count, tt.exits, v)
+		}
+	}
+}
+
 var incr6Tests = []struct {
 	netmask  int
 	hostmask int
@@ -657,6 +947,96 @@ func TestNet6_Subnet(t *testing.T) {
 	}
 }
 
+var subnet6OrderTests = []struct {
+	parent      Net6
+	netmasklen  int
+	hostmasklen int
+	count       int
+	first       string
+	last        string
+}{
+	{NewNet6(net.ParseIP("2001:db8::"), 64, 0), 66, 0, 4, "2001:db8::/66", "2001:db8:0:0:c000::/66"},
+	{NewNet6(net.ParseIP("2001:db8::"), 64, 0), 66, 60, 4, "2001:db8::/66", "2001:db8:0:0:c000::/66"},
+	{NewNet6(net.ParseIP("2001:db8::"), 64, 60), 66, 60, 4, "2001:db8::/66", "2001:db8:0:0:c000::/66"},
+	{NewNet6(net.ParseIP("2001:db8::"), 64, 60), 66, 0, 4, "2001:db8::/66", "2001:db8:0:0:c000::/66"},
+	{NewNet6(net.ParseIP("2001:db8::"), 64, 8), 72, 8, 256, "2001:db8::/72", "2001:db8:0:0:ff00::/72"},
+	{NewNet6(net.ParseIP("2001:db8::"), 64, 12), 72, 0, 256, "2001:db8::/72", "2001:db8:0:0:ff00::/72"},
+	{NewNet6(net.ParseIP("2001:db8::"), 120, 4), 122, 0, 4, "2001:db8::/122", "2001:db8::c0/122"},
+	{NewNet6(net.ParseIP("2001:db8::"), 120, 0), 122, 4, 4, "2001:db8::/122", "2001:db8::c0/122"},
+	{NewNet6(net.ParseIP("2001:db8::"), 126, 0), 128, 0, 4, "2001:db8::/128", "2001:db8::3/128"},
+	{NewNet6(net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff0"), 124, 0), 126, 0, 4, "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff0/126", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc/126"},
+	{NewNet6(net.ParseIP("::"), 0, 0), 2, 0, 4, "::/2", "c000::/2"},
+}
+
+func TestNet6_SubnetOrder(t *testing.T) {
+	for i, tt := range subnet6OrderTests {
+		subnets, err := tt.parent.Subnet(tt.netmasklen, tt.hostmasklen)
+		if err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+			continue
+		}
+		if len(subnets) != tt.count {
+			t.Errorf("[%d] want %d subnets got %d", i, tt.count, len(subnets))
+			continue
+		}
+		if subnets[0].String() != tt.first {
+			t.Errorf("[%d] want first subnet %s got %s", i, tt.first, subnets[0])
+		}
+		if subnets[len(subnets)-1].String() != tt.last {
+			t.Errorf("[%d] want last subnet %s got %s", i, tt.last, subnets[len(subnets)-1])
+		}
+		for ii := 1; ii < len(subnets); ii++ {
+			if v := CompareNets(subnets[ii-1], subnets[ii]); v != -1 {
+				t.Errorf("[%d] subnets %d and %d out of order: %s, %s", i, ii-1, ii, subnets[ii-1], subnets[ii])
+			}
+			if hm, _ := subnets[ii].Hostmask.Size(); hm != tt.hostmasklen {
+				t.Errorf("[%d] subnet %d: want hostmask %d got %d", i, ii, tt.hostmasklen, hm)
+			}
+		}
+	}
+}
+
+var scanUnits6Tests = []struct {
+	netblock Net6
+	masklen  int
+	count    int
+	first    string
+	last     string
+	err      error
+}{
+	{Net6FromStr("2001:db8::/56"), 0, 256, "2001:db8::/64", "2001:db8:0:ff::/64", nil},
+	{NewNet6(net.ParseIP("2001:db8::"), 56, 60), 64, 256, "2001:db8::/64", "2001:db8:0:ff::/64", nil},
+	{Net6FromStr("2001:db8::/120"), 122, 4, "2001:db8::/122", "2001:db8::c0/122", nil},
+	{Net6FromStr("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/120"), 122, 4, "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/122", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffc0/122", nil},
+	{Net6FromStr("2001:db8::/72"), 0, 0, "", "", ErrBadMaskLength},
+	{Net6FromStr("2001:db8::/64"), 129, 0, "", "", ErrBadMaskLength},
+}
+
+func TestNet6_ScanUnits(t *testing.T) {
+	for i, tt := range scanUnits6Tests {
+		units, err := tt.netblock.ScanUnits(tt.masklen)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		got := []Net6{}
+		units(func(unit Net6) bool {
+			got = append(got, unit)
+			return true
+		})
+		if len(got) != tt.count {
+			t.Errorf("[%d] want %d units got %d", i, tt.count, len(got))
+			continue
+		}
+		if got[0].String() != tt.first || got[len(got)-1].String() != tt.last {
+			t.Errorf("[%d] want %s ... %s got %s ... %s", i, tt.first, tt.last, got[0], got[len(got)-1])
+		}
+	}
+}
+
 var supernet6Tests = []struct {
 	in         Net6
 	netmasklen int
@@ -770,6 +1150,30 @@ func TestNet6_RandomIP(t *testing.T) {
 	}
 }
 
+func TestNet6_RandomIPFrom(t *testing.T) {
+	n := Net6FromStr("2001:db8::/64")
+	key := bytes.Repeat([]byte{0x3c, 0x81}, 16)
+
+	a, err := n.RandomIPFrom(bytes.NewReader(key))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b, _ := n.RandomIPFrom(bytes.NewReader(key))
+	if !a.Equal(b) {
+		t.Errorf("same reader contents should give the same address, got %s and %s", a, b)
+	}
+	if !n.Contains(a) {
+		t.Errorf("address %s not in %s", a, n)
+	}
+
+	if ip, err := n.RandomIPFrom(bytes.NewReader(nil)); err == nil || ip != nil {
+		t.Errorf("want an error from an empty reader, got %s, %v", ip, err)
+	}
+	if ip, err := n.RandomIPFrom(nil); err != nil || !n.Contains(ip) {
+		t.Errorf("nil reader: got %s, %v", ip, err)
+	}
+}
+
 var controlsTests = []struct {
 	ipn   Net6
 	addrs map[string]bool
@@ -794,6 +1198,175 @@ func TestNet6_Controls(t *testing.T) {
 	}
 }
 
+func TestNet6_ToIPNet(t *testing.T) {
+	for i, xnet := range []string{"2001:db8::/64", "::/0"} {
+		n := Net6FromStr(xnet)
+		ipnet := n.ToIPNet()
+		if len(ipnet.IP) != 16 || len(ipnet.Mask) != 16 {
+			t.Errorf("[%d] want 16-byte IP and mask got %d and %d", i, len(ipnet.IP), len(ipnet.Mask))
+		}
+		if ipnet.String() != n.String() {
+			t.Errorf("[%d] want %s got %s", i, n, ipnet)
+		}
+
+		// the result must not share memory with the Net6
+		ipnet.IP[0] ^= 0xff
+		if ipnet.IP.Equal(n.IP()) {
+			t.Errorf("[%d] ToIPNet returned a shared IP", i)
+		}
+	}
+
+	if (Net6{}).ToIPNet() != nil {
+		t.Errorf("want nil for an empty Net6")
+	}
+}
+
+var rangeString6Tests = []struct {
+	xnet string
+	out  string
+}{
+	{"2001:db8::/120", "2001:db8:: - 2001:db8::ff (256 hosts)"},
+	{"2001:db8::/64", "2001:db8:: - 2001:db8::ffff:ffff:ffff:ffff (18446744073709551616 hosts)"},
+	{"2001:db8::1/128", "2001:db8::1 - 2001:db8::1 (1 host)"},
+}
+
+func TestNet6_RangeString(t *testing.T) {
+	for i, tt := range rangeString6Tests {
+		if s := Net6FromStr(tt.xnet).RangeString(); s != tt.out {
+			t.Errorf("[%d] want %q got %q", i, tt.out, s)
+		}
+	}
+
+	n6 := NewNet6(net.ParseIP("2001:db8::"), 56, 60)
+	if s := n6.RangeString(); s != "2001:db8:: - 2001:db8:0:ff:f00:: (4096 hosts)" {
+		t.Errorf("got %q", s)
+	}
+	if s := (Net6{}).RangeString(); s != "<nil>" {
+		t.Errorf("want <nil> for an empty Net6 got %q", s)
+	}
+}
+
+func TestNet6_Hash(t *testing.T) {
+	n6 := Net6FromStr("2001:db8::/32")
+	if n6.Hash() != Net6FromStr("2001:db8::/32").Hash() {
+		t.Errorf("hash should be deterministic")
+	}
+	if n6.Hash() == NewNet6(net.ParseIP("2001:db8::"), 32, 8).Hash() {
+		t.Errorf("hostmask should change the hash")
+	}
+	if Net6FromStr("::c0a8:0/112").Hash() == NewNet4(net.ParseIP("0.0.192.168"), 16).Hash() {
+		t.Errorf("v4 and v6 networks should not collide")
+	}
+}
+
+var utilization6Tests = []struct {
+	xnet string
+	used []string
+	util float64
+}{
+	{"2001:db8::/32", []string{"2001:db8::/33", "2001:db8::/34"}, 0.5},
+	{"2001:db8::/32", []string{"2001:db8:8000::/34", "2001:db9::/32"}, 0.25},
+	{"::/0", []string{"8000::/1"}, 0.5},
+	{"2001:db8::/32", []string{"::/0"}, 1},
+}
+
+func TestNet6_Utilization(t *testing.T) {
+	for i, tt := range utilization6Tests {
+		used := []Net6{}
+		for _, s := range tt.used {
+			used = append(used, Net6FromStr(s))
+		}
+		if u := Net6FromStr(tt.xnet).Utilization(used); u != tt.util {
+			t.Errorf("[%d] %s: want %f got %f", i, tt.xnet, tt.util, u)
+		}
+	}
+
+	n6 := Net6FromStr("2001:db8::/32")
+	if u := n6.Utilization([]Net6{Net6FromStr("2001:db8::1/128")}); u <= 0 {
+		t.Errorf("a single address in a /32 should not round to zero, got %g", u)
+	}
+}
+
+func TestNet6_ReverseAddressIter(t *testing.T) {
+	for i, xnet := range []string{"2001:db8::/125", "2001:db8::/127"} {
+		n := Net6FromStr(xnet)
+		forward := n.Enumerate(0, 0)
+		var reverse []net.IP
+		n.ReverseAddressIter()(func(ip net.IP) bool {
+			reverse = append(reverse, ip)
+			return true
+		})
+		if len(forward) != len(reverse) {
+			t.Errorf("[%d] %s: want %d addresses got %d", i, xnet, len(forward), len(reverse))
+			continue
+		}
+		for j := range reverse {
+			if !reverse[j].Equal(forward[len(forward)-1-j]) {
+				t.Errorf("[%d] %s: position %d want %s got %s", i, xnet, j, forward[len(forward)-1-j], reverse[j])
+			}
+		}
+	}
+
+	n6 := NewNet6(net.ParseIP("2001:db8::"), 56, 60)
+	var got []string
+	n6.ReverseAddressIter()(func(ip net.IP) bool {
+		got = append(got, ip.String())
+		return len(got) < 2
+	})
+	if len(got) != 2 || got[0] != "2001:db8:0:ff:f00::" || got[1] != "2001:db8:0:ff:e00::" {
+		t.Errorf("want [2001:db8:0:ff:f00:: 2001:db8:0:ff:e00::] got %v", got)
+	}
+}
+
+func TestNet6_Packed(t *testing.T) {
+	for i, n := range []Net6{
+		Net6FromStr("2001:db8::/32"),
+		Net6FromStr("::/0"),
+		Net6FromStr("2001:db8::1/128"),
+		NewNet6(net.ParseIP("2001:db8::"), 56, 60),
+	} {
+		p := n.Packed()
+		rt, err := Net6FromPacked(p)
+		if err != nil || rt.String() != n.String() || !bytes.Equal(rt.Hostmask, n.Hostmask) {
+			t.Errorf("[%d] %s: round trip gave %s, %v", i, n, rt, err)
+		}
+	}
+	p := NewNet6(net.ParseIP("2001:db8::"), 56, 60).Packed()
+	if p[16] != 56 || p[17] != 60 {
+		t.Errorf("want masklen 56 and hostmask 60 got %d and %d", p[16], p[17])
+	}
+	if _, err := Net6FromPacked([18]byte{0x20, 0x01, 16: 64, 17: 65}); err != ErrBadMaskLength {
+		t.Errorf("want %v got %v", ErrBadMaskLength, err)
+	}
+}
+
+var net6ExpandedStringTests = []struct {
+	xnet string
+	out  string
+}{
+	{"2001:db8::/32", "2001:0db8:0000:0000:0000:0000:0000:0000/32"},
+	{"2001:db8:1234:5678::/64", "2001:0db8:1234:5678:0000:0000:0000:0000/64"},
+	{"::/0", "0000:0000:0000:0000:0000:0000:0000:0000/0"},
+	{"fe80::1/128", "fe80:0000:0000:0000:0000:0000:0000:0001/128"},
+}
+
+func TestNet6_ExpandedString(t *testing.T) {
+	for i, tt := range net6ExpandedStringTests {
+		n := Net6FromStr(tt.xnet)
+		if s := n.ExpandedString(); s != tt.out {
+			t.Errorf("[%d] want %s got %s", i, tt.out, s)
+		}
+	}
+
+	n := NewNet6(net.ParseIP("::ffff:c0a8:0"), 120, 0)
+	if s := n.ExpandedString(); s != "0000:0000:0000:0000:0000:ffff:c0a8:0000/120" {
+		t.Errorf("4in6: got %s", s)
+	}
+	if s := (Net6{}).ExpandedString(); s != "<nil>" {
+		t.Errorf("empty Net6: want <nil> got %s", s)
+	}
+}
+
 func compareNet6Arrays(a []Net6, b []Net6) bool {
 	if len(a) != len(b) {
 		return false
@@ -1,8 +1,10 @@
 package iplib
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"testing"
 )
 
@@ -134,6 +136,89 @@ func TestNewNetBetween(t *testing.T) {
 	}
 }
 
+var findOverlapsTests = []struct {
+	nets     []string
+	overlaps [][2]string
+}{
+	{
+		[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24"},
+		[][2]string{},
+	},
+	{
+		[]string{"10.0.1.0/24", "10.0.0.0/16"},
+		[][2]string{{"10.0.0.0/16", "10.0.1.0/24"}},
+	},
+	{
+		[]string{"10.0.0.0/24", "10.0.0.0/24"},
+		[][2]string{{"10.0.0.0/24", "10.0.0.0/24"}},
+	},
+	{
+		[]string{"10.0.0.0/8", "10.1.0.0/16", "10.200.0.0/16", "11.0.0.0/8", "10.1.2.0/24"},
+		[][2]string{
+			{"10.0.0.0/8", "10.1.0.0/16"},
+			{"10.0.0.0/8", "10.1.2.0/24"},
+			{"10.0.0.0/8", "10.200.0.0/16"},
+			{"10.1.0.0/16", "10.1.2.0/24"},
+		},
+	},
+	{
+		[]string{"2001:db8::/32", "10.0.0.0/8", "2001:db8:1::/48", "2001:db9::/32"},
+		[][2]string{{"2001:db8::/32", "2001:db8:1::/48"}},
+	},
+	{
+		[]string{"::/0", "0.0.0.0/0"},
+		[][2]string{},
+	},
+}
+
+func TestFindOverlaps(t *testing.T) {
+	for i, tt := range findOverlapsTests {
+		nets := []Net{}
+		for _, s := range tt.nets {
+			_, n, _ := ParseCIDR(s)
+			nets = append(nets, n)
+		}
+		nets = append(nets, nil, Net4{})
+
+		overlaps := FindOverlaps(nets)
+		if len(overlaps) != len(tt.overlaps) {
+			t.Errorf("[%d] want %v got %v", i, tt.overlaps, overlaps)
+			continue
+		}
+		for j, pair := range overlaps {
+			if pair[0].String() != tt.overlaps[j][0] || pair[1].String() != tt.overlaps[j][1] {
+				t.Errorf("[%d] pair %d: want %v got %v", i, j, tt.overlaps[j], pair)
+			}
+		}
+	}
+
+	a := NewNet6(net.ParseIP("2001:db8::"), 56, 60)
+	b := NewNet6(net.ParseIP("2001:db8:0:ff:ff00::"), 72, 0)
+	if overlaps := FindOverlaps([]Net{b, a}); len(overlaps) != 1 {
+		t.Errorf("hostmasked Net6: want 1 overlap, got %v", overlaps)
+	}
+}
+
+func TestLargestNetFrom(t *testing.T) {
+	for i, tt := range NewNetBetweenTests {
+		xnet, exact, err := LargestNetFrom(tt.start, tt.end)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] LargestNetFrom(%s, %s) expected error '%v', got '%v'", i, tt.start, tt.end, tt.err, err)
+		} else if tt.err == nil {
+			if xnet.String() != tt.xnet {
+				t.Errorf("[%d] LargestNetFrom(%s, %s) expected '%s', got '%s'", i, tt.start, tt.end, tt.xnet, xnet.String())
+			}
+			if exact != tt.exact {
+				t.Errorf("[%d] LargestNetFrom(%s, %s) expected '%t', got '%t'", i, tt.start, tt.end, tt.exact, exact)
+			}
+		}
+	}
+
+	if _, _, err := LargestNetFrom(nil, nil); err != ErrNoValidRange {
+		t.Errorf("LargestNetFrom(nil, nil) expected error '%v', got '%v'", ErrNoValidRange, err)
+	}
+}
+
 func TestAllNetsBetween(t *testing.T) {
 	for i, tt := range NewNetBetweenTests {
 		//t.Logf("[%d] nets between %s and %s", i, tt.start, tt.end)
@@ -151,6 +236,106 @@ func TestAllNetsBetween(t *testing.T) {
 	}
 }
 
+func TestNetsBetweenIter(t *testing.T) {
+	for i, tt := range NewNetBetweenTests {
+		var xnets []Net
+		var err error
+		NetsBetweenIter(tt.start, tt.end)(func(n Net, e error) bool {
+			if e != nil {
+				if n != nil {
+					t.Errorf("[%d] expected nil Net alongside error, got %s", i, n)
+				}
+				err = e
+				return true
+			}
+			if err != nil {
+				t.Errorf("[%d] network yielded after error", i)
+			}
+			xnets = append(xnets, n)
+			return true
+		})
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] expected error '%v', got '%v'", i, tt.err, err)
+		}
+		if tt.err == nil && len(xnets) != tt.netslen {
+			t.Errorf("[%d] expected %d networks, got %d", i, tt.netslen, len(xnets))
+		}
+
+		count := 0
+		NetsBetweenIter(tt.start, tt.end)(func(n Net, e error) bool {
+			count++
+			return false
+		})
+		if count != 1 {
+			t.Errorf("[%d] expected iteration to stop after 1 value, got %d", i, count)
+		}
+	}
+}
+
+func TestExactNet(t *testing.T) {
+	for i, tt := range NewNetBetweenTests {
+		xnet, err := ExactNet(tt.start, tt.end)
+		want := tt.err
+		if want == nil && !tt.exact {
+			want = ErrNoValidRange
+		}
+		if e := compareErrors(err, want); len(e) > 0 {
+			t.Errorf("[%d] ExactNet(%s, %s) expected error '%v', got '%v'", i, tt.start, tt.end, want, err)
+			continue
+		}
+		if want == nil && xnet.String() != tt.xnet {
+			t.Errorf("[%d] ExactNet(%s, %s) expected '%s', got '%s'", i, tt.start, tt.end, tt.xnet, xnet.String())
+		}
+		if want != nil && xnet != nil {
+			t.Errorf("[%d] ExactNet(%s, %s) expected nil Net, got '%s'", i, tt.start, tt.end, xnet.String())
+		}
+	}
+}
+
+func TestAllNet4sBetweenAllNet6sBetween(t *testing.T) {
+	for i, tt := range NewNetBetweenTests {
+		xnets, xerr := AllNetsBetween(tt.start, tt.end)
+
+		var strs []string
+		var err error
+		if EffectiveVersion(tt.start) == IP4Version {
+			var nets []Net4
+			nets, err = AllNet4sBetween(tt.start, tt.end)
+			for _, n := range nets {
+				strs = append(strs, n.String())
+			}
+			if _, err6 := AllNet6sBetween(tt.start, tt.end); err6 != ErrNoValidRange {
+				t.Errorf("[%d] AllNet6sBetween(%s, %s) expected error '%v', got '%v'", i, tt.start, tt.end, ErrNoValidRange, err6)
+			}
+		} else {
+			var nets []Net6
+			nets, err = AllNet6sBetween(tt.start, tt.end)
+			for _, n := range nets {
+				strs = append(strs, n.String())
+			}
+			if _, err4 := AllNet4sBetween(tt.start, tt.end); err4 != ErrNoValidRange {
+				t.Errorf("[%d] AllNet4sBetween(%s, %s) expected error '%v', got '%v'", i, tt.start, tt.end, ErrNoValidRange, err4)
+			}
+		}
+
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] expected error '%v', got '%v'", i, tt.err, err)
+		}
+		if tt.err != nil || xerr != nil {
+			continue
+		}
+		if len(strs) != len(xnets) {
+			t.Errorf("[%d] expected %d networks, got %d", i, len(xnets), len(strs))
+			continue
+		}
+		for j := range xnets {
+			if strs[j] != xnets[j].String() {
+				t.Errorf("[%d] network %d expected %s got %s", i, j, xnets[j], strs[j])
+			}
+		}
+	}
+}
+
 var ParseCIDRTests = []struct {
 	s    string
 	xnet string
@@ -182,3 +367,391 @@ func TestParseCIDR(t *testing.T) {
 		}
 	}
 }
+
+func TestParseCIDRPreferV4(t *testing.T) {
+	for i, tt := range ParseCIDRTests {
+		wantErr := tt.err
+		if tt.s == "::ffff:c0a8:0101/64" {
+			wantErr = ErrBadMaskLength
+		}
+		_, n, err := ParseCIDRPreferV4(tt.s)
+		if e := compareErrors(err, wantErr); len(e) > 0 {
+			t.Errorf("[%d] ParseCIDRPreferV4(%s) expected error '%v', got '%v'", i, tt.s, wantErr, err)
+		} else if wantErr == nil {
+			if n.Version() != tt.ver {
+				t.Errorf("[%d] expected IPNet version '%d' got '%d'", i, tt.ver, n.Version())
+			}
+			if n.String() != tt.xnet {
+				t.Errorf("[%d] expected '%s' for '%s'", i, tt.xnet, n.String())
+			}
+		}
+	}
+}
+
+var parseErrorTests = []struct {
+	s     string
+	token string
+}{
+	{"not.legit/22", "not.legit"},
+	{"192.168.1.1", "192.168.1.1"},
+	{"192.168.1.0/33", "33"},
+	{"192.168.1.0/x", "x"},
+	{"2001:db8::/129", "129"},
+	{"2001:db8:::/64", "2001:db8:::"},
+}
+
+func TestParseCIDR_ParseError(t *testing.T) {
+	for i, tt := range parseErrorTests {
+		_, _, err := ParseCIDR(tt.s)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("[%d] %s: want *ParseError got %T", i, tt.s, err)
+			continue
+		}
+		if perr.Input != tt.s || perr.Token != tt.token {
+			t.Errorf("[%d] want input %q token %q got %q, %q", i, tt.s, tt.token, perr.Input, perr.Token)
+		}
+		var nerr *net.ParseError
+		if !errors.As(err, &nerr) {
+			t.Errorf("[%d] %s: want to unwrap to *net.ParseError", i, tt.s)
+		}
+	}
+}
+
+var netFromStrErrTests = []struct {
+	s   string
+	ver int
+	err bool
+}{
+	{"192.168.1.0/24", 4, false},
+	{"2001:db8::/64", 6, false},
+	{"192.168.1.0/33", 4, true},
+	{"2001:db8::/129", 6, true},
+	{"not.legit", 4, true},
+}
+
+func TestNetFromStrErr(t *testing.T) {
+	for i, tt := range netFromStrErrTests {
+		n4, err4 := Net4FromStrErr(tt.s)
+		n6, err6 := Net6FromStrErr(tt.s)
+
+		var perr *ParseError
+		if tt.err || tt.ver != 4 {
+			if !errors.As(err4, &perr) || n4.IP() != nil {
+				t.Errorf("[%d] Net4FromStrErr(%s) want *ParseError and empty Net4 got '%v', %s", i, tt.s, err4, n4)
+			}
+		} else if err4 != nil || n4.String() != tt.s {
+			t.Errorf("[%d] Net4FromStrErr(%s) want %s got %s, '%v'", i, tt.s, tt.s, n4, err4)
+		}
+
+		if tt.err || tt.ver != 6 {
+			if !errors.As(err6, &perr) || n6.IP() != nil {
+				t.Errorf("[%d] Net6FromStrErr(%s) want *ParseError and empty Net6 got '%v', %s", i, tt.s, err6, n6)
+			}
+		} else if err6 != nil || n6.String() != tt.s {
+			t.Errorf("[%d] Net6FromStrErr(%s) want %s got %s, '%v'", i, tt.s, tt.s, n6, err6)
+		}
+	}
+}
+
+var netBroadcastTests = []struct {
+	xnet      string
+	broadcast net.IP
+}{
+	{"192.168.0.0/24", net.ParseIP("192.168.0.255")},
+	{"192.168.0.0/31", net.ParseIP("192.168.0.1")},
+	{"192.168.0.7/32", net.ParseIP("192.168.0.7")},
+	{"2001:db8::/64", net.ParseIP("2001:db8::ffff:ffff:ffff:ffff")},
+	{"2001:db8::/127", net.ParseIP("2001:db8::1")},
+}
+
+func TestNet_BroadcastAddress(t *testing.T) {
+	for i, tt := range netBroadcastTests {
+		_, n, _ := ParseCIDR(tt.xnet)
+		if v := CompareIPs(n.BroadcastAddress(), tt.broadcast); v != 0 {
+			t.Errorf("[%d] %s: want %s got %s", i, tt.xnet, tt.broadcast, n.BroadcastAddress())
+		}
+	}
+}
+
+func TestBySize(t *testing.T) {
+	in := []string{
+		"10.0.0.0/24",
+		"10.1.0.0/30",
+		"2001:db8::/120",
+		"10.2.0.0/16",
+		"10.0.1.0/24",
+		"192.168.0.0/32",
+	}
+	ascending := []string{
+		"192.168.0.0/32",
+		"10.1.0.0/30",
+		"10.0.0.0/24",
+		"10.0.1.0/24",
+		"2001:db8::/120",
+		"10.2.0.0/16",
+	}
+	descending := []string{
+		"10.2.0.0/16",
+		"2001:db8::/120",
+		"10.0.0.0/24",
+		"10.0.1.0/24",
+		"10.1.0.0/30",
+		"192.168.0.0/32",
+	}
+
+	for _, tt := range []struct {
+		desc bool
+		want []string
+	}{{false, ascending}, {true, descending}} {
+		nets := []Net{}
+		for _, s := range in {
+			_, n, _ := ParseCIDR(s)
+			nets = append(nets, n)
+		}
+		sort.Sort(BySize{Nets: nets, Descending: tt.desc})
+		for i, n := range nets {
+			if n.String() != tt.want[i] {
+				t.Errorf("descending=%t [%d] want %s got %s", tt.desc, i, tt.want[i], n)
+			}
+		}
+	}
+}
+
+var whichNetTests = []struct {
+	ip   string
+	want string
+	ok   bool
+}{
+	{"10.1.2.3", "10.1.2.0/24", true},
+	{"10.1.3.3", "10.1.0.0/16", true},
+	{"10.2.0.1", "10.0.0.0/8", true},
+	{"192.168.1.1", "", false},
+	{"2001:db8::1", "2001:db8::/64", true},
+	{"2001:db8:1::1", "2001:db8::/32", true},
+	{"2001:db9::1", "", false},
+}
+
+func TestWhichNet(t *testing.T) {
+	nets := []Net{nil}
+	for _, s := range []string{"10.0.0.0/8", "10.1.2.0/24", "10.1.0.0/16", "2001:db8::/32", "2001:db8::/64"} {
+		_, n, _ := ParseCIDR(s)
+		nets = append(nets, n)
+	}
+	for i, tt := range whichNetTests {
+		n, ok := WhichNet(net.ParseIP(tt.ip), nets)
+		if ok != tt.ok {
+			t.Errorf("[%d] %s: want %t got %t", i, tt.ip, tt.ok, ok)
+			continue
+		}
+		if ok && n.String() != tt.want {
+			t.Errorf("[%d] %s: want %s got %s", i, tt.ip, tt.want, n)
+		}
+	}
+}
+
+var netUsableCountTests = []struct {
+	xnet  string
+	count string
+}{
+	{"192.168.0.0/24", "254"},
+	{"192.168.0.0/31", "2"},
+	{"192.168.0.0/32", "1"},
+	{"0.0.0.0/0", "4294967294"},
+	{"2001:db8::/64", "18446744073709551616"},
+	{"2001:db8::/127", "2"},
+	{"::/0", "340282366920938463463374607431768211456"},
+}
+
+func TestNet_UsableCount(t *testing.T) {
+	for i, tt := range netUsableCountTests {
+		_, n, _ := ParseCIDR(tt.xnet)
+		if c := n.UsableCount(); c.String() != tt.count {
+			t.Errorf("[%d] %s: want %s got %s", i, tt.xnet, tt.count, c)
+		}
+	}
+	if c := (Net4{}).UsableCount(); c.Sign() != 0 {
+		t.Errorf("want 0 for empty Net4 got %s", c)
+	}
+	if c := (Net6{}).UsableCount(); c.Sign() != 0 {
+		t.Errorf("want 0 for empty Net6 got %s", c)
+	}
+	hm := NewNet6(net.ParseIP("2001:db8::"), 56, 60)
+	if c := hm.UsableCount(); c.String() != "4096" {
+		t.Errorf("want 4096 for hostmasked Net6 got %s", c)
+	}
+}
+
+var parseCIDRAsTests = []struct {
+	s       string
+	version int
+	xnet    string
+	err     error
+}{
+	{"192.168.1.1/24", 4, "192.168.1.0/24", nil},
+	{"::ffff:c0a8:0101/24", 4, "192.168.1.0/24", nil},
+	{"::ffff:c0a8:0101/64", 4, "", &ParseError{Err: &net.ParseError{Type: "IPv4 CIDR address", Text: "::ffff:c0a8:0101/64"}}},
+	{"2001:db8::/32", 4, "", &ParseError{Err: &net.ParseError{Type: "IPv4 CIDR address", Text: "2001:db8::/32"}}},
+	{"2001:db8::1/32", 6, "2001:db8::/32", nil},
+	{"::ffff:c0a8:0101/24", 6, "::/24", nil},
+	{"::ffff:c0a8:0101/120", 6, "192.168.1.0/24", nil}, // a v6 /120, but net.IPNet prints it in v4 form
+	{"::ffff:192.168.1.1/24", 6, "::/24", nil},
+	{"192.168.1.1/24", 6, "", &ParseError{Err: &net.ParseError{Type: "IPv6 CIDR address", Text: "192.168.1.1/24"}}},
+	{"2001:db8::/129", 6, "", &ParseError{Err: &net.ParseError{Type: "CIDR address", Text: "2001:db8::/129"}}},
+	{"192.168.1.1/24", 5, "", ErrNoValidRange},
+}
+
+func TestParseCIDRAs(t *testing.T) {
+	for i, tt := range parseCIDRAsTests {
+		n, err := ParseCIDRAs(tt.s, tt.version)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] ParseCIDRAs(%s, %d): %s", i, tt.s, tt.version, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if n.String() != tt.xnet || n.Version() != tt.version {
+			t.Errorf("[%d] ParseCIDRAs(%s, %d): want %s got v%d %s", i, tt.s, tt.version, tt.xnet, n.Version(), n)
+		}
+	}
+}
+
+func TestByNetSpecificity(t *testing.T) {
+	in := []string{"10.0.0.0/8", "192.168.0.0/16", "10.0.0.0/24", "10.0.0.0/16", "10.1.0.0/16", "192.168.0.0/24"}
+	bySpecificity := []string{"10.0.0.0/24", "10.0.0.0/16", "10.0.0.0/8", "10.1.0.0/16", "192.168.0.0/24", "192.168.0.0/16"}
+	byNet := []string{"10.0.0.0/8", "10.0.0.0/16", "10.0.0.0/24", "10.1.0.0/16", "192.168.0.0/16", "192.168.0.0/24"}
+
+	nets := []Net{}
+	for _, s := range in {
+		_, n, _ := ParseCIDR(s)
+		nets = append(nets, n)
+	}
+
+	sort.Sort(ByNetSpecificity(nets))
+	for i, n := range nets {
+		if n.String() != bySpecificity[i] {
+			t.Errorf("ByNetSpecificity [%d] want %s got %s", i, bySpecificity[i], n)
+		}
+	}
+
+	// CompareNets is unchanged
+	sort.Sort(ByNet(nets))
+	for i, n := range nets {
+		if n.String() != byNet[i] {
+			t.Errorf("ByNet [%d] want %s got %s", i, byNet[i], n)
+		}
+	}
+
+	a, b := Net4FromStr("10.0.0.0/8"), Net4FromStr("10.0.0.0/8")
+	if v := CompareNetsBySpecificity(a, b); v != 0 {
+		t.Errorf("want 0 for identical networks got %d", v)
+	}
+}
+
+var netBroadcastFuncTests = []struct {
+	n  Net
+	bc string
+}{
+	{Net4FromStr("192.168.1.0/24"), "192.168.1.255"},
+	{Net4FromStr("192.168.1.0/31"), "192.168.1.1"},
+	{Net4FromStr("192.168.1.1/32"), "192.168.1.1"},
+	{Net6FromStr("2001:db8::/64"), "2001:db8::ffff:ffff:ffff:ffff"},
+	{NewNet6(net.ParseIP("2001:db8::"), 56, 60), "2001:db8:0:ff:ffff:ffff:ffff:ffff"},
+	{Net4{}, "<nil>"},
+	{nil, "<nil>"},
+}
+
+func TestNetBroadcast(t *testing.T) {
+	for i, tt := range netBroadcastFuncTests {
+		if bc := NetBroadcast(tt.n); bc.String() != tt.bc {
+			t.Errorf("[%d] want %s got %s", i, tt.bc, bc)
+		}
+	}
+}
+
+var sameNetworkTests = []struct {
+	a, b Net
+	same bool
+}{
+	{Net4FromStr("192.168.0.0/24"), Net4FromStr("192.168.0.0/24"), true},
+	{Net4FromStr("192.168.0.0/24"), NewNet6(net.ParseIP("::ffff:c0a8:0"), 120, 0), true},
+	{NewNet6(net.ParseIP("::ffff:c0a8:0"), 120, 0), Net4FromStr("192.168.0.0/24"), true},
+	{Net4FromStr("192.168.0.0/24"), NewNet4(net.ParseIP("::ffff:c0a8:0"), 24), true},
+	{Net4FromStr("192.168.0.0/24"), Net4FromStr("192.168.0.0/23"), false},
+	{Net4FromStr("192.168.0.0/24"), NewNet6(net.ParseIP("::ffff:c0a8:0"), 112, 0), false},
+	{Net4FromStr("0.0.0.0/0"), Net6FromStr("::/0"), false},
+	{Net6FromStr("2001:db8::/32"), NewNet6(net.ParseIP("2001:db8::"), 32, 64), true},
+	{Net6FromStr("2001:db8::/32"), Net6FromStr("2001:db8::/33"), false},
+	{Net6FromStr("::/96"), Net4FromStr("0.0.0.0/0"), false},
+	{nil, nil, true},
+	{Net4FromStr("192.168.0.0/24"), nil, false},
+}
+
+func TestSameNetwork(t *testing.T) {
+	for i, tt := range sameNetworkTests {
+		if same := SameNetwork(tt.a, tt.b); same != tt.same {
+			t.Errorf("[%d] %v, %v: want %t got %t", i, tt.a, tt.b, tt.same, same)
+		}
+	}
+}
+
+var fitNetTests = []struct {
+	ips    []string
+	xnet   string
+	wasted float64
+	err    error
+}{
+	{[]string{"10.0.0.1", "10.0.0.2"}, "10.0.0.0/30", 0.5, nil},
+	{[]string{"10.0.0.0", "10.0.0.1", "10.0.0.2", "10.0.0.3"}, "10.0.0.0/30", 0, nil},
+	{[]string{"10.0.0.3", "10.0.0.0", "10.0.0.3"}, "10.0.0.0/30", 0.5, nil},
+	{[]string{"192.168.1.1"}, "192.168.1.1/32", 0, nil},
+	{[]string{"192.168.1.1", "192.168.2.1"}, "192.168.0.0/22", 1 - 2.0/1024, nil},
+	{[]string{"0.0.0.0", "255.255.255.255"}, "0.0.0.0/0", 1 - 2.0/4294967296, nil},
+	{[]string{"::ffff:10.0.0.1", "10.0.0.2"}, "10.0.0.0/30", 0.5, nil},
+	{[]string{"2001:db8::1", "2001:db8::6"}, "2001:db8::/125", 0.75, nil},
+	{[]string{"2001:db8::1", "10.0.0.1"}, "", 0, ErrNoValidRange},
+	{[]string{"10.0.0.1", "bogus"}, "", 0, ErrNoValidRange},
+	{[]string{}, "", 0, ErrNoValidRange},
+}
+
+func TestFitNet(t *testing.T) {
+	for i, tt := range fitNetTests {
+		ips := []net.IP{}
+		for _, s := range tt.ips {
+			ips = append(ips, net.ParseIP(s))
+		}
+		n, wasted, err := FitNet(ips)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err != nil {
+			continue
+		}
+		if n.String() != tt.xnet {
+			t.Errorf("[%d] want %s got %s", i, tt.xnet, n)
+		}
+		if wasted != tt.wasted {
+			t.Errorf("[%d] want wasted %v got %v", i, tt.wasted, wasted)
+		}
+	}
+}
+
+func TestNetsTable(t *testing.T) {
+	nets := []Net{
+		Net4FromStr("192.168.1.0/24"),
+		nil,
+		Net6FromStr("2001:db8::/126"),
+	}
+	want := "NETWORK         FIRST        LAST           COUNT\n" +
+		"192.168.1.0/24  192.168.1.1  192.168.1.254    254\n" +
+		"2001:db8::/126  2001:db8::   2001:db8::3        4\n"
+	if s := NetsTable(nets); s != want {
+		t.Errorf("want\n%s\ngot\n%s", want, s)
+	}
+
+	if s := NetsTable(nil); s != "NETWORK  FIRST  LAST  COUNT\n" {
+		t.Errorf("empty table: got %q", s)
+	}
+}
@@ -0,0 +1,70 @@
+package iplib
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LineError is returned by ReadNets when a line of its input cannot be
+// parsed. Line is the 1-based line number at fault and Err is the error
+// returned when parsing it, usually a *ParseError
+type LineError struct {
+	Line int
+	Err  error
+}
+
+// Error implements the error interface
+func (e *LineError) Error() string {
+	return "line " + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error, for use with errors.Is and errors.As
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// ReadNets parses r as a list of networks in CIDR notation, one per line, as
+// found in allowlists, blocklists and firewall exports. Leading and trailing
+// whitespace is ignored, as are blank lines and anything following a '#',
+// so both whole-line and trailing comments are permitted. The networks are
+// returned in the order they appear. If a line cannot be parsed the networks
+// read so far are discarded and a *LineError identifying the line is
+// returned; if r itself fails its error is returned
+func ReadNets(r io.Reader) ([]Net, error) {
+	nets := []Net{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s, _, _ := strings.Cut(scanner.Text(), "#")
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		_, n, err := ParseCIDR(s)
+		if err != nil {
+			return nil, &LineError{Line: line, Err: err}
+		}
+		nets = append(nets, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nets, nil
+}
+
+// WriteNets writes nets to w in CIDR notation, one per line, in the format
+// read by ReadNets. Nil entries are skipped. Any error from w is returned
+func WriteNets(w io.Writer, nets []Net) error {
+	bw := bufio.NewWriter(w)
+	for _, n := range nets {
+		if n == nil {
+			continue
+		}
+		if _, err := bw.WriteString(n.String() + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
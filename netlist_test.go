@@ -0,0 +1,93 @@
+package iplib
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var readNetsTests = []struct {
+	in   string
+	nets []string
+	line int
+}{
+	{"", []string{}, 0},
+	{
+		"10.0.0.0/8\n192.168.0.0/16\n2001:db8::/32\n",
+		[]string{"10.0.0.0/8", "192.168.0.0/16", "2001:db8::/32"},
+		0,
+	},
+	{
+		"# blocklist\n\n  10.0.0.0/8  \n192.168.1.0/24 # office\n\t\n#2001:db8::/32\n",
+		[]string{"10.0.0.0/8", "192.168.1.0/24"},
+		0,
+	},
+	{"10.0.0.0/8\r\n172.16.0.0/12\r\n", []string{"10.0.0.0/8", "172.16.0.0/12"}, 0},
+	{"10.0.0.0/8", []string{"10.0.0.0/8"}, 0},
+	{"10.0.0.0/8\n# comment\n192.168.0.0/33\n", nil, 3},
+	{"\n\nnot-a-network\n", nil, 3},
+}
+
+func TestReadNets(t *testing.T) {
+	for i, tt := range readNetsTests {
+		nets, err := ReadNets(strings.NewReader(tt.in))
+		if tt.line > 0 {
+			var le *LineError
+			if !errors.As(err, &le) {
+				t.Errorf("[%d] want *LineError got %v", i, err)
+				continue
+			}
+			if le.Line != tt.line {
+				t.Errorf("[%d] want line %d got %d", i, tt.line, le.Line)
+			}
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Errorf("[%d] want wrapped *ParseError got %v", i, le.Err)
+			}
+			if nets != nil {
+				t.Errorf("[%d] want nil networks on error got %v", i, nets)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%d] unexpected error %v", i, err)
+			continue
+		}
+		if len(nets) != len(tt.nets) {
+			t.Errorf("[%d] want %v got %v", i, tt.nets, nets)
+			continue
+		}
+		for j, n := range nets {
+			if n.String() != tt.nets[j] {
+				t.Errorf("[%d] network %d: want %s got %s", i, j, tt.nets[j], n)
+			}
+		}
+	}
+}
+
+func TestWriteNets(t *testing.T) {
+	nets := []Net{
+		Net4FromStr("10.0.0.0/8"),
+		nil,
+		Net6FromStr("2001:db8::/32"),
+		Net4FromStr("192.168.1.0/24"),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteNets(&buf, nets); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := "10.0.0.0/8\n2001:db8::/32\n192.168.1.0/24\n"
+	if buf.String() != want {
+		t.Errorf("want %q got %q", want, buf.String())
+	}
+
+	back, err := ReadNets(&buf)
+	if err != nil {
+		t.Fatalf("round trip: unexpected error %v", err)
+	}
+	if len(back) != 3 || !SameNetwork(back[1], nets[2]) {
+		t.Errorf("round trip: want %v got %v", nets, back)
+	}
+}
@@ -0,0 +1,203 @@
+package iplib
+
+import (
+	"net"
+)
+
+// Range4 describes an arbitrary, inclusive span of IPv4 addresses. Unlike a
+// Net4 the endpoints of a Range4 do not need to fall on a CIDR boundary, so
+// it can represent spans such as 192.168.1.10-192.168.1.99 which would
+// otherwise require several networks to express
+type Range4 struct {
+	first net.IP
+	last  net.IP
+}
+
+// NewRange4 returns a Range4 spanning first through last, inclusive. If
+// either address is not IPv4, or if first is greater than last, an empty
+// Range4 and ErrNoValidRange will be returned
+func NewRange4(first, last net.IP) (Range4, error) {
+	if EffectiveVersion(first) != IP4Version || EffectiveVersion(last) != IP4Version {
+		return Range4{}, ErrNoValidRange
+	}
+	if CompareIPs(first, last) > 0 {
+		return Range4{}, ErrNoValidRange
+	}
+	return Range4{first: CopyIP(ForceIP4(first)), last: CopyIP(ForceIP4(last))}, nil
+}
+
+// CIDRs returns the smallest set of networks that exactly covers the range,
+// in ascending order. It is equivalent to CIDRsMaxSize(0)
+func (r Range4) CIDRs() []Net4 {
+	return r.CIDRsMaxSize(0)
+}
+
+// CIDRsMaxSize returns the smallest set of networks that exactly covers the
+// range without any network being larger than maxMasklen, e.g. a maxMasklen
+// of 24 will break up anything that could otherwise be expressed as a /23 or
+// larger. The networks are returned in ascending order. If maxMasklen is
+// outside of 0-32 nil is returned
+func (r Range4) CIDRsMaxSize(maxMasklen int) []Net4 {
+	if r.first == nil || maxMasklen < 0 || maxMasklen > 32 {
+		return nil
+	}
+
+	// uint64 so that the block following 255.255.255.255 doesn't wrap
+	cur := uint64(IP4ToUint32(r.first))
+	end := uint64(IP4ToUint32(r.last))
+
+	nets := []Net4{}
+	for cur <= end {
+		masklen := 32
+		for masklen > maxMasklen {
+			size := uint64(1) << uint(32-masklen+1)
+			if cur%size != 0 || cur+size-1 > end {
+				break
+			}
+			masklen--
+		}
+		nets = append(nets, NewNet4(Uint32ToIP4(uint32(cur)), masklen))
+		cur += uint64(1) << uint(32-masklen)
+	}
+	return nets
+}
+
+// Contains returns true if ip falls within the range, inclusive of its
+// endpoints. It will always return false for v6 addresses
+func (r Range4) Contains(ip net.IP) bool {
+	if r.first == nil || EffectiveVersion(ip) != IP4Version {
+		return false
+	}
+	return CompareIPs(ip, r.first) >= 0 && CompareIPs(ip, r.last) <= 0
+}
+
+// ContainsNet returns true if every address in network, from its network
+// address through its broadcast address, falls within the range. A network
+// which only partially overlaps the range is not contained by it
+func (r Range4) ContainsNet(network Net4) bool {
+	if network.IP() == nil {
+		return false
+	}
+	return r.Contains(network.IP()) && r.Contains(network.BroadcastAddress())
+}
+
+// FirstAddress returns the first address in the range
+func (r Range4) FirstAddress() net.IP {
+	return r.first
+}
+
+// Intersect returns the portion of the address space common to both r and
+// other. If the two ranges do not overlap an empty Range4 and false will be
+// returned. Note that ranges which are adjacent but share no addresses, such
+// as 10.0.0.0-10.0.0.9 and 10.0.0.10-10.0.0.19, are considered disjoint
+func (r Range4) Intersect(other Range4) (Range4, bool) {
+	if r.first == nil || other.first == nil {
+		return Range4{}, false
+	}
+
+	first, last := r.first, r.last
+	if CompareIPs(other.first, first) > 0 {
+		first = other.first
+	}
+	if CompareIPs(other.last, last) < 0 {
+		last = other.last
+	}
+
+	if CompareIPs(first, last) > 0 {
+		return Range4{}, false
+	}
+	return Range4{first: CopyIP(first), last: CopyIP(last)}, true
+}
+
+// LastAddress returns the last address in the range
+func (r Range4) LastAddress() net.IP {
+	return r.last
+}
+
+// Midpoint returns the address halfway through the range, calculated as the
+// first address plus half the total number of addresses in the range. So the
+// midpoint of 10.0.0.0-10.0.0.255 is 10.0.0.128 and the midpoint of a range
+// containing a single address is that address
+func (r Range4) Midpoint() net.IP {
+	if r.first == nil {
+		return nil
+	}
+	first := uint64(IP4ToUint32(r.first))
+	last := uint64(IP4ToUint32(r.last))
+	return Uint32ToIP4(uint32(first + (last-first+1)/2))
+}
+
+// String returns the range as a pair of hyphen-separated addresses, e.g.
+// 192.168.1.10-192.168.1.99
+func (r Range4) String() string {
+	if r.first == nil {
+		return "<nil>"
+	}
+	return r.first.String() + "-" + r.last.String()
+}
+
+// SubdivideTo returns every network of length masklen which overlaps the
+// range, in ascending order. Unlike CIDRs() the result does not exactly cover
+// the range: where the range does not begin or end on a masklen boundary the
+// first and last networks overhang it, so 10.0.0.10-10.0.1.5 subdivided to
+// /24 returns 10.0.0.0/24 and 10.0.1.0/24. If the range is empty
+// ErrNoValidRange is returned, and if masklen is outside of 0-32
+// ErrBadMaskLength is returned
+func (r Range4) SubdivideTo(masklen int) ([]Net4, error) {
+	if r.first == nil {
+		return nil, ErrNoValidRange
+	}
+	if masklen < 0 || masklen > 32 {
+		return nil, ErrBadMaskLength
+	}
+
+	step := uint64(1) << uint(32-masklen)
+	cur := uint64(IP4ToUint32(r.first)) &^ (step - 1)
+	end := uint64(IP4ToUint32(r.last))
+
+	nets := []Net4{}
+	for ; cur <= end; cur += step {
+		nets = append(nets, NewNet4(Uint32ToIP4(uint32(cur)), masklen))
+	}
+	return nets, nil
+}
+
+// ToIPSet returns an IPSet containing every address in the range, built from
+// the networks returned by CIDRs(). An empty Range4 returns an empty IPSet
+func (r Range4) ToIPSet() *IPSet {
+	if r.first == nil {
+		return &IPSet{}
+	}
+
+	nets := []Net{}
+	for _, n := range r.CIDRs() {
+		nets = append(nets, n)
+	}
+	return NewIPSet(nets)
+}
+
+// Union returns a single Range4 covering both r and other, so long as the two
+// either overlap or are directly adjacent to one another. If there is a gap
+// between them they cannot be expressed as a single range and an empty
+// Range4 and false will be returned
+func (r Range4) Union(other Range4) (Range4, bool) {
+	if r.first == nil || other.first == nil {
+		return Range4{}, false
+	}
+
+	a, b := r, other
+	if CompareIPs(a.first, b.first) > 0 {
+		a, b = b, a
+	}
+
+	// b begins beyond the end of a and is not directly adjacent to it
+	if CompareIPs(b.first, a.last) > 0 && IP4ToUint32(b.first)-IP4ToUint32(a.last) > 1 {
+		return Range4{}, false
+	}
+
+	last := a.last
+	if CompareIPs(b.last, last) > 0 {
+		last = b.last
+	}
+	return Range4{first: CopyIP(a.first), last: CopyIP(last)}, true
+}
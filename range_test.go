@@ -0,0 +1,340 @@
+package iplib
+
+import (
+	"net"
+	"testing"
+)
+
+var NewRange4Tests = []struct {
+	first net.IP
+	last  net.IP
+	s     string
+	err   error
+}{
+	{net.ParseIP("192.168.1.10"), net.ParseIP("192.168.1.99"), "192.168.1.10-192.168.1.99", nil},
+	{net.ParseIP("192.168.1.10"), net.ParseIP("192.168.1.10"), "192.168.1.10-192.168.1.10", nil},
+	{net.ParseIP("192.168.1.99"), net.ParseIP("192.168.1.10"), "<nil>", ErrNoValidRange},
+	{net.ParseIP("2001:db8::"), net.ParseIP("2001:db8::1"), "<nil>", ErrNoValidRange},
+	{net.ParseIP("192.168.1.10"), net.ParseIP("2001:db8::1"), "<nil>", ErrNoValidRange},
+}
+
+func TestNewRange4(t *testing.T) {
+	for i, tt := range NewRange4Tests {
+		r, err := NewRange4(tt.first, tt.last)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+		}
+		if r.String() != tt.s {
+			t.Errorf("[%d] want %s got %s", i, tt.s, r.String())
+		}
+	}
+}
+
+var range4ContainsTests = []struct {
+	ip net.IP
+	in bool
+}{
+	{net.ParseIP("192.168.1.9"), false},
+	{net.ParseIP("192.168.1.10"), true},
+	{net.ParseIP("192.168.1.50"), true},
+	{net.ParseIP("192.168.1.99"), true},
+	{net.ParseIP("192.168.1.100"), false},
+	{net.IP{192, 168, 1, 50}, true},
+	{net.ParseIP("::ffff:192.168.1.50"), true},
+	{net.ParseIP("2001:db8::"), false},
+	{nil, false},
+}
+
+func TestRange4_Contains(t *testing.T) {
+	r, _ := NewRange4(net.ParseIP("192.168.1.10"), net.ParseIP("192.168.1.99"))
+	for i, tt := range range4ContainsTests {
+		if v := r.Contains(tt.ip); v != tt.in {
+			t.Errorf("[%d] %s: want %t got %t", i, tt.ip, tt.in, v)
+		}
+	}
+	if (Range4{}).Contains(net.ParseIP("192.168.1.50")) {
+		t.Errorf("empty range should not contain any address")
+	}
+}
+
+var range4ContainsNetTests = []struct {
+	xnet string
+	in   bool
+}{
+	{"192.168.1.16/28", true},
+	{"192.168.1.64/27", true},
+	{"192.168.1.10/32", true},
+	{"192.168.1.99/32", true},
+	{"192.168.1.0/28", false},  // partial overlap, low end
+	{"192.168.1.96/28", false}, // partial overlap, high end
+	{"192.168.1.0/24", false},  // encloses the range
+	{"192.168.2.0/24", false},
+}
+
+func TestRange4_ContainsNet(t *testing.T) {
+	r, _ := NewRange4(net.ParseIP("192.168.1.10"), net.ParseIP("192.168.1.99"))
+	for i, tt := range range4ContainsNetTests {
+		if v := r.ContainsNet(Net4FromStr(tt.xnet)); v != tt.in {
+			t.Errorf("[%d] %s: want %t got %t", i, tt.xnet, tt.in, v)
+		}
+	}
+	if r.ContainsNet(Net4{}) {
+		t.Errorf("range should not contain an empty network")
+	}
+}
+
+var range4IntersectTests = []struct {
+	a     [2]string
+	b     [2]string
+	inter string
+	ok    bool
+}{
+	{ // partial overlap
+		[2]string{"10.0.0.0", "10.0.0.100"}, [2]string{"10.0.0.50", "10.0.0.200"},
+		"10.0.0.50-10.0.0.100", true,
+	},
+	{ // b inside a
+		[2]string{"10.0.0.0", "10.0.0.255"}, [2]string{"10.0.0.16", "10.0.0.31"},
+		"10.0.0.16-10.0.0.31", true,
+	},
+	{ // share a single address
+		[2]string{"10.0.0.0", "10.0.0.10"}, [2]string{"10.0.0.10", "10.0.0.20"},
+		"10.0.0.10-10.0.0.10", true,
+	},
+	{ // adjacent, not overlapping
+		[2]string{"10.0.0.0", "10.0.0.9"}, [2]string{"10.0.0.10", "10.0.0.19"},
+		"<nil>", false,
+	},
+	{ // disjoint
+		[2]string{"10.0.0.20", "10.0.0.29"}, [2]string{"10.0.0.0", "10.0.0.9"},
+		"<nil>", false,
+	},
+}
+
+func TestRange4_Intersect(t *testing.T) {
+	for i, tt := range range4IntersectTests {
+		a, _ := NewRange4(net.ParseIP(tt.a[0]), net.ParseIP(tt.a[1]))
+		b, _ := NewRange4(net.ParseIP(tt.b[0]), net.ParseIP(tt.b[1]))
+		for _, pair := range [][2]Range4{{a, b}, {b, a}} {
+			inter, ok := pair[0].Intersect(pair[1])
+			if ok != tt.ok {
+				t.Errorf("[%d] %s & %s: want %t got %t", i, pair[0], pair[1], tt.ok, ok)
+			}
+			if inter.String() != tt.inter {
+				t.Errorf("[%d] %s & %s: want %s got %s", i, pair[0], pair[1], tt.inter, inter)
+			}
+		}
+	}
+}
+
+var range4UnionTests = []struct {
+	a     [2]string
+	b     [2]string
+	union string
+	ok    bool
+}{
+	{
+		[2]string{"10.0.0.0", "10.0.0.100"}, [2]string{"10.0.0.50", "10.0.0.200"},
+		"10.0.0.0-10.0.0.200", true,
+	},
+	{
+		[2]string{"10.0.0.0", "10.0.0.255"}, [2]string{"10.0.0.16", "10.0.0.31"},
+		"10.0.0.0-10.0.0.255", true,
+	},
+	{
+		[2]string{"10.0.0.0", "10.0.0.9"}, [2]string{"10.0.0.10", "10.0.0.19"},
+		"10.0.0.0-10.0.0.19", true,
+	},
+	{
+		[2]string{"10.0.0.20", "10.0.0.29"}, [2]string{"10.0.0.0", "10.0.0.9"},
+		"<nil>", false,
+	},
+	{
+		[2]string{"0.0.0.0", "0.0.0.0"}, [2]string{"255.255.255.255", "255.255.255.255"},
+		"<nil>", false,
+	},
+}
+
+func TestRange4_Union(t *testing.T) {
+	for i, tt := range range4UnionTests {
+		a, _ := NewRange4(net.ParseIP(tt.a[0]), net.ParseIP(tt.a[1]))
+		b, _ := NewRange4(net.ParseIP(tt.b[0]), net.ParseIP(tt.b[1]))
+		for _, pair := range [][2]Range4{{a, b}, {b, a}} {
+			union, ok := pair[0].Union(pair[1])
+			if ok != tt.ok {
+				t.Errorf("[%d] %s | %s: want %t got %t", i, pair[0], pair[1], tt.ok, ok)
+			}
+			if union.String() != tt.union {
+				t.Errorf("[%d] %s | %s: want %s got %s", i, pair[0], pair[1], tt.union, union)
+			}
+		}
+	}
+}
+
+var range4CIDRsTests = []struct {
+	first   string
+	last    string
+	maxmask int
+	nets    []string
+}{
+	{
+		"192.168.0.0", "192.168.3.255", 0,
+		[]string{"192.168.0.0/22"},
+	},
+	{
+		"192.168.0.0", "192.168.3.255", 24,
+		[]string{"192.168.0.0/24", "192.168.1.0/24", "192.168.2.0/24", "192.168.3.0/24"},
+	},
+	{
+		"192.168.0.0", "192.168.3.255", 23,
+		[]string{"192.168.0.0/23", "192.168.2.0/23"},
+	},
+	{
+		"192.168.0.255", "192.168.2.0", 0,
+		[]string{"192.168.0.255/32", "192.168.1.0/24", "192.168.2.0/32"},
+	},
+	{
+		"192.168.0.255", "192.168.2.0", 25,
+		[]string{"192.168.0.255/32", "192.168.1.0/25", "192.168.1.128/25", "192.168.2.0/32"},
+	},
+	{
+		"10.0.0.1", "10.0.0.6", 0,
+		[]string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"},
+	},
+	{
+		"0.0.0.0", "255.255.255.255", 0,
+		[]string{"0.0.0.0/0"},
+	},
+	{
+		"0.0.0.0", "255.255.255.255", 1,
+		[]string{"0.0.0.0/1", "128.0.0.0/1"},
+	},
+	{
+		"10.0.0.0", "10.0.0.255", 33,
+		[]string{},
+	},
+}
+
+func TestRange4_CIDRsMaxSize(t *testing.T) {
+	for i, tt := range range4CIDRsTests {
+		r, _ := NewRange4(net.ParseIP(tt.first), net.ParseIP(tt.last))
+		nets := r.CIDRsMaxSize(tt.maxmask)
+		if v := compareNet4ArraysToStringRepresentation(nets, tt.nets); v == false {
+			t.Errorf("[%d] want %v got %v", i, tt.nets, nets)
+		}
+	}
+}
+
+func TestRange4_CIDRs(t *testing.T) {
+	for i, tt := range range4CIDRsTests {
+		if tt.maxmask != 0 {
+			continue
+		}
+		r, _ := NewRange4(net.ParseIP(tt.first), net.ParseIP(tt.last))
+		nets := r.CIDRs()
+		if v := compareNet4ArraysToStringRepresentation(nets, tt.nets); v == false {
+			t.Errorf("[%d] want %v got %v", i, tt.nets, nets)
+		}
+	}
+}
+
+var range4MidpointTests = []struct {
+	first    string
+	last     string
+	midpoint net.IP
+}{
+	{"10.0.0.0", "10.0.0.255", net.ParseIP("10.0.0.128")},
+	{"10.0.0.10", "10.0.0.20", net.ParseIP("10.0.0.15")},
+	{"10.0.0.10", "10.0.0.11", net.ParseIP("10.0.0.11")},
+	{"10.0.0.10", "10.0.0.10", net.ParseIP("10.0.0.10")},
+	{"0.0.0.0", "255.255.255.255", net.ParseIP("128.0.0.0")},
+	{"255.255.255.254", "255.255.255.255", net.ParseIP("255.255.255.255")},
+}
+
+func TestRange4_Midpoint(t *testing.T) {
+	for i, tt := range range4MidpointTests {
+		r, _ := NewRange4(net.ParseIP(tt.first), net.ParseIP(tt.last))
+		if addr := r.Midpoint(); !tt.midpoint.Equal(addr) {
+			t.Errorf("[%d] want %s got %s", i, tt.midpoint, addr)
+		}
+	}
+}
+
+var range4SubdivideToTests = []struct {
+	first   string
+	last    string
+	masklen int
+	nets    []string
+	err     error
+}{
+	{
+		"10.0.0.10", "10.0.1.5", 24,
+		[]string{"10.0.0.0/24", "10.0.1.0/24"}, nil,
+	},
+	{
+		"10.0.0.0", "10.0.2.255", 24,
+		[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24"}, nil,
+	},
+	{
+		"10.0.0.200", "10.0.0.200", 24,
+		[]string{"10.0.0.0/24"}, nil,
+	},
+	{
+		"10.0.0.100", "10.0.0.140", 26,
+		[]string{"10.0.0.64/26", "10.0.0.128/26"}, nil,
+	},
+	{
+		"10.0.0.0", "10.0.255.255", 8,
+		[]string{"10.0.0.0/8"}, nil,
+	},
+	{
+		"255.255.255.0", "255.255.255.255", 25,
+		[]string{"255.255.255.0/25", "255.255.255.128/25"}, nil,
+	},
+	{
+		"10.0.0.0", "10.0.0.255", 33,
+		nil, ErrBadMaskLength,
+	},
+}
+
+func TestRange4_SubdivideTo(t *testing.T) {
+	for i, tt := range range4SubdivideToTests {
+		r, _ := NewRange4(net.ParseIP(tt.first), net.ParseIP(tt.last))
+		nets, err := r.SubdivideTo(tt.masklen)
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err == nil && !compareNet4ArraysToStringRepresentation(nets, tt.nets) {
+			t.Errorf("[%d] want %v got %v", i, tt.nets, nets)
+		}
+	}
+	if _, err := (Range4{}).SubdivideTo(24); err != ErrNoValidRange {
+		t.Errorf("want %v for an empty range got %v", ErrNoValidRange, err)
+	}
+}
+
+func TestRange4_ToIPSet(t *testing.T) {
+	r, _ := NewRange4(net.ParseIP("10.0.0.10"), net.ParseIP("10.0.1.5"))
+	s := r.ToIPSet()
+
+	for _, ip := range []string{"10.0.0.10", "10.0.0.255", "10.0.1.5"} {
+		if !s.Contains(net.ParseIP(ip)) {
+			t.Errorf("want %s in set", ip)
+		}
+	}
+	for _, ip := range []string{"10.0.0.9", "10.0.1.6"} {
+		if s.Contains(net.ParseIP(ip)) {
+			t.Errorf("want %s not in set", ip)
+		}
+	}
+
+	ranges := s.Ranges()
+	if len(ranges) != 1 || ranges[0].String() != r.String() {
+		t.Errorf("round trip: want [%s] got %v", r, ranges)
+	}
+
+	if n := (Range4{}).ToIPSet().Nets(); len(n) != 0 {
+		t.Errorf("empty range: want empty set got %v", n)
+	}
+}
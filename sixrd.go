@@ -0,0 +1,39 @@
+package iplib
+
+import (
+	"net"
+
+	"lukechampine.com/uint128"
+)
+
+// Make6rdAddr returns the RFC5969 6rd delegated prefix for the customer edge
+// address v4. The delegated prefix is formed from the 6rd prefix followed by
+// the v4 address with its first prefixV4len bits removed, those being common
+// to every customer edge in the deployment and so not worth carrying. The
+// result is the network address of the delegated prefix, which is
+// prefix-length plus 32 minus prefixV4len bits long: 10.100.200.1 under a 6rd
+// prefix of 2001:db8::/32 with a prefixV4len of 8 yields 2001:db8:64c8:100::,
+// a /56. Any bits of prefix beyond its mask are ignored.
+//
+// If v4 is not an IPv4 address or prefix is empty ErrNoValidRange is
+// returned. If prefixV4len is outside of 0-32, or the delegated prefix would
+// be longer than 128 bits, ErrBadMaskLength is returned
+func Make6rdAddr(prefix Net6, prefixV4len int, v4 net.IP) (net.IP, error) {
+	if prefix.IP() == nil || EffectiveVersion(v4) != IP4Version {
+		return nil, ErrNoValidRange
+	}
+	if prefixV4len < 0 || prefixV4len > 32 {
+		return nil, ErrBadMaskLength
+	}
+
+	ones, _ := prefix.Mask().Size()
+	carried := 32 - prefixV4len
+	if ones+carried > 128 {
+		return nil, ErrBadMaskLength
+	}
+
+	suffix := uint128.From64(uint64(IP4ToUint32(v4))).And(hostSpan(carried))
+	z := IP6ToUint128(prefix.IP()).And(uint128.Max.Xor(hostSpan(128 - ones)))
+	z = z.Or(suffix.Lsh(uint(128 - ones - carried)))
+	return Uint128ToIP6(z), nil
+}
@@ -0,0 +1,45 @@
+package iplib
+
+import (
+	"net"
+	"testing"
+)
+
+var make6rdAddrTests = []struct {
+	prefix      string
+	prefixV4len int
+	v4          string
+	out         string
+	err         error
+}{
+	{"2001:db8::/32", 0, "192.0.2.1", "2001:db8:c000:201::", nil},
+	{"2001:db8::/32", 8, "10.100.200.1", "2001:db8:64c8:100::", nil},
+	{"2001:db8::/32", 16, "10.100.200.1", "2001:db8:c801::", nil},
+	{"2001:db8:ff00::/40", 24, "203.0.113.5", "2001:db8:ff05::", nil},
+	{"2001:db8::/32", 32, "10.100.200.1", "2001:db8::", nil},
+	{"2001:db8::/28", 12, "192.168.10.20", "2001:db8:a14::", nil},
+	{"2001:db8:ffff::/32", 0, "192.0.2.1", "2001:db8:c000:201::", nil},
+	{"2001:db8::/32", 8, "::ffff:10.100.200.1", "2001:db8:64c8:100::", nil},
+	{"2001:db8:1:2::/100", 0, "10.0.0.1", "", ErrBadMaskLength},
+	{"2001:db8::/32", 33, "10.0.0.1", "", ErrBadMaskLength},
+	{"2001:db8::/32", -1, "10.0.0.1", "", ErrBadMaskLength},
+	{"2001:db8::/32", 8, "2001:db8::1", "", ErrNoValidRange},
+}
+
+func TestMake6rdAddr(t *testing.T) {
+	for i, tt := range make6rdAddrTests {
+		_, prefix, _ := ParseCIDR(tt.prefix)
+		xip, err := Make6rdAddr(prefix.(Net6), tt.prefixV4len, net.ParseIP(tt.v4))
+		if e := compareErrors(err, tt.err); len(e) > 0 {
+			t.Errorf("[%d] %s", i, e)
+			continue
+		}
+		if tt.err == nil && !xip.Equal(net.ParseIP(tt.out)) {
+			t.Errorf("[%d] want %s got %s", i, tt.out, xip)
+		}
+	}
+
+	if _, err := Make6rdAddr(Net6{}, 8, net.ParseIP("10.0.0.1")); err != ErrNoValidRange {
+		t.Errorf("empty prefix: want ErrNoValidRange got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package iplib
+
+import (
+	"crypto/rand"
+	"io"
+	"net"
+)
+
+// GenerateULA returns a new RFC4193 Unique Local IPv6 Unicast /48, made up of
+// the locally-assigned fd00::/8 prefix (fc00::/7 with the L bit set) followed
+// by a 40-bit Global ID read from r. RFC4193 suggests deriving the Global ID
+// from a hash of the time and an EUI-64, but any source of randomness
+// satisfies its requirements. If r is nil crypto/rand.Reader is used, and if
+// r cannot supply 5 bytes its error is returned
+func GenerateULA(r io.Reader) (Net6, error) {
+	if r == nil {
+		r = rand.Reader
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	ip[0] = 0xfd
+	if _, err := io.ReadFull(r, ip[1:6]); err != nil {
+		return Net6{}, err
+	}
+	return NewNet6(ip, 48, 0), nil
+}
+
+// IsULA returns true if ip is an RFC4193 Unique Local IPv6 Unicast address,
+// that is if it falls within fc00::/7. IPv4 addresses, including those in
+// IPv4-mapped form, always return false
+func IsULA(ip net.IP) bool {
+	if EffectiveVersion(ip) != IP6Version || ip.To16() == nil {
+		return false
+	}
+	return ip.To16()[0]&0xfe == 0xfc
+}
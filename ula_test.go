@@ -0,0 +1,63 @@
+package iplib
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestGenerateULA(t *testing.T) {
+	ula := Net6FromStr("fc00::/7")
+	for i := 0; i < 100; i++ {
+		n, err := GenerateULA(nil)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v", i, err)
+		}
+		if ones, _ := n.Mask().Size(); ones != 48 {
+			t.Errorf("[%d] want a /48 got %s", i, n)
+		}
+		if !ula.ContainsNet(n) {
+			t.Errorf("[%d] %s is not within fc00::/7", i, n)
+		}
+		if n.IP()[0]&0x01 != 0x01 {
+			t.Errorf("[%d] %s does not have the L bit set", i, n)
+		}
+		if !IsULA(n.IP()) {
+			t.Errorf("[%d] IsULA(%s) returned false", i, n.IP())
+		}
+	}
+
+	r := bytes.NewReader([]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc})
+	n, err := GenerateULA(r)
+	if err != nil || n.String() != "fd12:3456:789a::/48" {
+		t.Errorf("want fd12:3456:789a::/48 got %s, %v", n, err)
+	}
+
+	if _, err := GenerateULA(bytes.NewReader([]byte{0x12})); err == nil {
+		t.Errorf("expected an error from a short reader")
+	}
+}
+
+var isULATests = []struct {
+	ip  net.IP
+	ula bool
+}{
+	{net.ParseIP("fc00::"), true},
+	{net.ParseIP("fd12:3456:789a::1"), true},
+	{net.ParseIP("fdff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), true},
+	{net.ParseIP("fe80::1"), false},
+	{net.ParseIP("fbff:ffff::"), false},
+	{net.ParseIP("2001:db8::"), false},
+	{net.ParseIP("252.0.0.1"), false},
+	{net.IP{0xfc, 0, 0, 1}, false},
+	{net.IP{}, false},
+	{nil, false},
+}
+
+func TestIsULA(t *testing.T) {
+	for i, tt := range isULATests {
+		if v := IsULA(tt.ip); v != tt.ula {
+			t.Errorf("[%d] IsULA(%s): want %t got %t", i, tt.ip, tt.ula, v)
+		}
+	}
+}